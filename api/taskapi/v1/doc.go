@@ -0,0 +1,5 @@
+// Package taskapiv1 holds the messages and gRPC service generated from
+// tasks.proto. Run go generate after changing it.
+package taskapiv1
+
+//go:generate protoc -I ../../.. --go_out=../../.. --go_opt=paths=source_relative --go-grpc_out=../../.. --go-grpc_opt=paths=source_relative api/taskapi/v1/tasks.proto
@@ -0,0 +1,194 @@
+// Package certs serves TLS certificates from files, picking up new ones when
+// the files change on disk, and identifies clients by their certificates.
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ClientAuth modes accepted by Options.
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// ReloadInterval is how often, at most, the files are checked for changes.
+// Checks happen during handshakes, so an idle server does no work.
+var ReloadInterval = 10 * time.Second
+
+// Options names the files a server config is built from.
+type Options struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is a PEM bundle of the CAs trusted to sign client
+	// certificates. It is required unless ClientAuth is none.
+	ClientCAFile string
+	// ClientAuth is none, optional (verify a certificate if one is sent)
+	// or require.
+	ClientAuth string
+}
+
+// NewServerConfig returns a TLS config serving the certificate in
+// opts.CertFile, negotiating HTTP/2, and verifying clients as opts asks.
+// The files are read now, so that mistakes fail at startup, and again
+// whenever their modification time changes; if a reload fails the previous
+// files stay in use.
+func NewServerConfig(opts Options) (*tls.Config, error) {
+	var auth tls.ClientAuthType
+	switch opts.ClientAuth {
+	case ClientAuthNone, "":
+		auth = tls.NoClientCert
+	case ClientAuthOptional:
+		auth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		auth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("unknown client auth mode %q", opts.ClientAuth)
+	}
+	if auth != tls.NoClientCert && opts.ClientCAFile == "" {
+		return nil, errors.New("client certificate verification needs a client CA file")
+	}
+
+	r := &reloader{opts: opts}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+		ClientAuth: auth,
+	}
+	return &tls.Config{
+		MinVersion: base.MinVersion,
+		NextProtos: base.NextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := r.current()
+			c := base.Clone()
+			c.Certificates = []tls.Certificate{*cert}
+			c.ClientCAs = pool
+			return c, nil
+		},
+	}, nil
+}
+
+// reloader holds the parsed files and when they were last modified.
+type reloader struct {
+	opts Options
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+	modTimes [3]time.Time
+	checked  time.Time
+}
+
+func (r *reloader) files() [3]string {
+	return [3]string{r.opts.CertFile, r.opts.KeyFile, r.opts.ClientCAFile}
+}
+
+// current returns the certificate and CA pool, reloading them first if the
+// files have changed since the last check.
+func (r *reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checked) >= ReloadInterval {
+		r.checked = time.Now()
+		if r.changed() {
+			if err := r.loadLocked(); err != nil {
+				slog.Error("keeping the previous TLS certificate", "error", err)
+			} else {
+				slog.Info("reloaded TLS certificate", "cert_file", r.opts.CertFile)
+			}
+		}
+	}
+	return r.cert, r.pool
+}
+
+func (r *reloader) changed() bool {
+	for i, name := range r.files() {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err == nil && !info.ModTime().Equal(r.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *reloader) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checked = time.Now()
+	return r.loadLocked()
+}
+
+func (r *reloader) loadLocked() error {
+	var modTimes [3]time.Time
+	for i, name := range r.files() {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%s: no PEM certificates found", r.opts.ClientCAFile)
+		}
+	}
+	r.cert, r.pool, r.modTimes = &cert, pool, modTimes
+	return nil
+}
+
+// Identity names the holder of a verified client certificate: its first
+// URI SAN (such as a SPIFFE ID), else its first email address, else its
+// subject common name.
+func Identity(cert *x509.Certificate) string {
+	switch {
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	}
+	return cert.Subject.CommonName
+}
+
+// LoadUsers reads a YAML mapping from client identities, as returned by
+// Identity, to user names.
+func LoadUsers(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	users := map[string]string{}
+	if err := yaml.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return users, nil
+}
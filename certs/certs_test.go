@@ -0,0 +1,243 @@
+package certs_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/certs"
+)
+
+// issuer is a certificate and key that can sign others.
+type issuer struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+var serial int64
+
+// issue creates a certificate from tmpl signed by parent, or self-signed if
+// parent is nil.
+func issue(t *testing.T, tmpl *x509.Certificate, parent *issuer) (*issuer, tls.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial++
+	tmpl.SerialNumber = big.NewInt(serial)
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	signer := &issuer{tmpl, key}
+	if parent != nil {
+		signer = parent
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer.cert, &key.PublicKey, signer.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &issuer{cert, key}, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}
+}
+
+func newCA(t *testing.T, name string) *issuer {
+	ca, _ := issue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	return ca
+}
+
+func serverCert(t *testing.T, ca *issuer, name string) *issuer {
+	c, _ := issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: name},
+		IPAddresses: []net.IP{net.IPv6loopback, net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	return c
+}
+
+func writePEM(t *testing.T, path string, c *issuer, withKey bool) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if withKey {
+		der, err := x509.MarshalECPrivateKey(c.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+		if err := os.WriteFile(path+".key", data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// serve starts an HTTPS server answering with the request protocol and the
+// client certificate identity, if any.
+func serve(t *testing.T, opts certs.Options) *httptest.Server {
+	t.Helper()
+	config, err := certs.NewServerConfig(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+		if len(r.TLS.VerifiedChains) > 0 {
+			io.WriteString(w, " "+certs.Identity(r.TLS.VerifiedChains[0][0]))
+		}
+	}))
+	srv.EnableHTTP2 = true
+	srv.TLS = config
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func client(ca *issuer, certs ...tls.Certificate) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool, Certificates: certs},
+		ForceAttemptHTTP2: true,
+	}}
+}
+
+func get(c *http.Client, url string) (string, error) {
+	resp, err := c.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestServeHTTP2AndReload(t *testing.T) {
+	old := certs.ReloadInterval
+	certs.ReloadInterval = 0
+	t.Cleanup(func() { certs.ReloadInterval = old })
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "server.pem")
+	first, second := newCA(t, "first"), newCA(t, "second")
+	writePEM(t, certFile, serverCert(t, first, "server"), true)
+	srv := serve(t, certs.Options{CertFile: certFile, KeyFile: certFile + ".key"})
+
+	if body, err := get(client(first), srv.URL); err != nil || body != "HTTP/2.0" {
+		t.Fatalf("expected HTTP/2, got %q (%v)", body, err)
+	}
+
+	writePEM(t, certFile, serverCert(t, second, "server"), true)
+	later := time.Now().Add(time.Minute)
+	for _, name := range []string{certFile, certFile + ".key"} {
+		if err := os.Chtimes(name, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := get(client(second), srv.URL); err != nil {
+		t.Fatalf("expected the new certificate to be served: %v", err)
+	}
+
+	// A broken file keeps the previous certificate.
+	os.WriteFile(certFile, []byte("garbage"), 0o600)
+	os.Chtimes(certFile, later.Add(time.Minute), later.Add(time.Minute))
+	if _, err := get(client(second), srv.URL); err != nil {
+		t.Fatalf("expected the previous certificate to be kept: %v", err)
+	}
+}
+
+func TestClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	certFile, caFile := filepath.Join(dir, "server.pem"), filepath.Join(dir, "ca.pem")
+	ca := newCA(t, "clients")
+	writePEM(t, certFile, serverCert(t, ca, "server"), true)
+	writePEM(t, caFile, ca, false)
+	_, alice := issue(t, &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "alice"},
+		EmailAddresses: []string{"alice@example.com"},
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	_, stranger := issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "stranger"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, newCA(t, "elsewhere"))
+
+	srv := serve(t, certs.Options{CertFile: certFile, KeyFile: certFile + ".key", ClientCAFile: caFile, ClientAuth: certs.ClientAuthRequire})
+	if body, err := get(client(ca, alice), srv.URL); err != nil || body != "HTTP/2.0 alice@example.com" {
+		t.Errorf("expected alice to be identified, got %q (%v)", body, err)
+	}
+	if _, err := get(client(ca), srv.URL); err == nil {
+		t.Error("expected a client without a certificate to be refused")
+	}
+	if _, err := get(client(ca, stranger), srv.URL); err == nil {
+		t.Error("expected a certificate from another CA to be refused")
+	}
+
+	srv = serve(t, certs.Options{CertFile: certFile, KeyFile: certFile + ".key", ClientCAFile: caFile, ClientAuth: certs.ClientAuthOptional})
+	if body, err := get(client(ca), srv.URL); err != nil || body != "HTTP/2.0" {
+		t.Errorf("expected an optional certificate to be optional, got %q (%v)", body, err)
+	}
+}
+
+func TestNewServerConfigErrors(t *testing.T) {
+	if _, err := certs.NewServerConfig(certs.Options{CertFile: "missing.pem", KeyFile: "missing.key"}); err == nil {
+		t.Error("expected missing files to fail")
+	}
+	if _, err := certs.NewServerConfig(certs.Options{ClientAuth: certs.ClientAuthRequire}); err == nil {
+		t.Error("expected require without a CA file to fail")
+	}
+	if _, err := certs.NewServerConfig(certs.Options{ClientAuth: "sometimes"}); err == nil {
+		t.Error("expected an unknown mode to fail")
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.com/worker")
+	for _, c := range []struct {
+		cert *x509.Certificate
+		want string
+	}{
+		{&x509.Certificate{Subject: pkix.Name{CommonName: "cn"}, EmailAddresses: []string{"a@example.com"}, URIs: []*url.URL{spiffe}}, "spiffe://example.com/worker"},
+		{&x509.Certificate{Subject: pkix.Name{CommonName: "cn"}, EmailAddresses: []string{"a@example.com"}}, "a@example.com"},
+		{&x509.Certificate{Subject: pkix.Name{CommonName: "cn"}}, "cn"},
+	} {
+		if got := certs.Identity(c.cert); got != c.want {
+			t.Errorf("expected %q, got %q", c.want, got)
+		}
+	}
+}
+
+func TestLoadUsers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.yaml")
+	os.WriteFile(path, []byte("alice@example.com: alice\n\"spiffe://example.com/worker\": worker\n"), 0o600)
+	users, err := certs.LoadUsers(path)
+	if err != nil || users["alice@example.com"] != "alice" || users["spiffe://example.com/worker"] != "worker" {
+		t.Errorf("unexpected users %v (%v)", users, err)
+	}
+	os.WriteFile(path, []byte("- alice\n"), 0o600)
+	if _, err := certs.LoadUsers(path); err == nil {
+		t.Error("expected a list to fail")
+	}
+}
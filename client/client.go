@@ -0,0 +1,290 @@
+// Package client is a typed Go client for the task API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/model"
+)
+
+// DefaultPageSize is the number of tasks ListTasks fetches per request when
+// the filter does not set one.
+const DefaultPageSize = 100
+
+// Client calls the task API at BaseURL. Idempotent calls (GET, PUT and
+// DELETE) are retried after network errors and 429, 502, 503 and 504
+// responses, backing off exponentially from BaseBackoff up to MaxBackoff
+// or for as long as the server's Retry-After asks.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// Token is sent as a bearer token when set.
+	Token       string
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// New returns a client for the API at baseURL with the default retry
+// policy.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:     strings.TrimSuffix(baseURL, "/"),
+		HTTPClient:  http.DefaultClient,
+		MaxRetries:  3,
+		BaseBackoff: 100 * time.Millisecond,
+		MaxBackoff:  5 * time.Second,
+	}
+}
+
+// UseClientCertificate makes the client present the certificate in
+// certFile, with its key in keyFile, to servers that verify clients. If
+// caFile is set, the server's certificate must be signed by a CA in that
+// PEM bundle instead of one the system trusts. It replaces HTTPClient with
+// a copy using its own transport.
+func (c *Client) UseClientCertificate(certFile, keyFile, caFile string) error {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("loading client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%s: no PEM certificates found", caFile)
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	hc := *c.HTTPClient
+	hc.Transport = transport
+	c.HTTPClient = &hc
+	return nil
+}
+
+// Filter selects the tasks returned by ListTasks. Like the API, it applies
+// at most one of Done, Priority and Project, in that order.
+type Filter struct {
+	Done     *bool
+	Priority *model.Priority
+	Project  string
+	PageSize int
+}
+
+func (f Filter) query() url.Values {
+	q := url.Values{}
+	if f.Done != nil {
+		q.Set("done", strconv.FormatBool(*f.Done))
+	}
+	if f.Priority != nil {
+		q.Set("priority", strconv.Itoa(int(*f.Priority)))
+	}
+	if f.Project != "" {
+		q.Set("project", f.Project)
+	}
+	size := f.PageSize
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	q.Set("limit", strconv.Itoa(size))
+	return q
+}
+
+// Stats are the task counts reported by the API.
+type Stats struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Pending   int `json:"pending"`
+}
+
+// ListTasks iterates over the tasks selected by f, fetching further pages
+// as the loop advances. An error ends the iteration.
+func (c *Client) ListTasks(ctx context.Context, f Filter) iter.Seq2[model.Task, error] {
+	return func(yield func(model.Task, error) bool) {
+		for page, err := range c.Pages(ctx, f) {
+			if err != nil {
+				yield(model.Task{}, err)
+				return
+			}
+			for _, t := range page {
+				if !yield(t, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Pages iterates over the pages of tasks selected by f, following the
+// server's next links.
+func (c *Client) Pages(ctx context.Context, f Filter) iter.Seq2[[]model.Task, error] {
+	return func(yield func([]model.Task, error) bool) {
+		next := "/tasks?" + f.query().Encode()
+		for next != "" {
+			var page []model.Task
+			header, err := c.do(ctx, http.MethodGet, next, nil, &page)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(page, nil) {
+				return
+			}
+			next = nextLink(header.Get("Link"))
+		}
+	}
+}
+
+func (c *Client) CreateTask(ctx context.Context, t model.Task) (model.Task, error) {
+	var created model.Task
+	_, err := c.do(ctx, http.MethodPost, "/tasks", t, &created)
+	return created, err
+}
+
+func (c *Client) GetTask(ctx context.Context, id string) (model.Task, error) {
+	var t model.Task
+	_, err := c.do(ctx, http.MethodGet, "/tasks/"+url.PathEscape(id), nil, &t)
+	return t, err
+}
+
+func (c *Client) UpdateTask(ctx context.Context, id string, t model.Task) (model.Task, error) {
+	var updated model.Task
+	_, err := c.do(ctx, http.MethodPut, "/tasks/"+url.PathEscape(id), t, &updated)
+	return updated, err
+}
+
+func (c *Client) DeleteTask(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, "/tasks/"+url.PathEscape(id), nil, nil)
+	return err
+}
+
+func (c *Client) Stats(ctx context.Context) (Stats, error) {
+	var s Stats
+	_, err := c.do(ctx, http.MethodGet, "/stats", nil, &s)
+	return s, err
+}
+
+// do sends a JSON request to path, retrying idempotent methods, and decodes
+// a successful response into out. Error responses are returned as *Error.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) (http.Header, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return nil, err
+		}
+	}
+	retries := 0
+	if method != http.MethodPost {
+		retries = c.MaxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, body)
+		var retryAfter time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, err
+			}
+		case resp.StatusCode >= 400:
+			apiErr := readError(resp)
+			if !retryable(resp.StatusCode) {
+				return nil, apiErr
+			}
+			err, retryAfter = apiErr, apiErr.RetryAfter
+		default:
+			defer resp.Body.Close()
+			if out != nil && resp.StatusCode != http.StatusNoContent {
+				if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+					return nil, fmt.Errorf("decoding %s %s response: %w", method, path, err)
+				}
+			}
+			return resp.Header, nil
+		}
+		if attempt >= retries {
+			return nil, err
+		}
+		if err := sleep(ctx, max(c.backoff(attempt), retryAfter)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	return c.HTTPClient.Do(req)
+}
+
+// backoff returns the delay before retry n+1.
+func (c *Client) backoff(n int) time.Duration {
+	d := c.BaseBackoff
+	for i := 0; i < n && d < c.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, c.MaxBackoff)
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// nextLink returns the target of the rel="next" link in a Link header.
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+		if ok && strings.Contains(params, `rel="next"`) {
+			return strings.Trim(strings.TrimSpace(target), "<>")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,267 @@
+package client_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/client"
+	"github.com/sawez-deepsource/demo-go/handler"
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+// newServer serves the API the way main does, with wrap applied in front of
+// the routes.
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) *client.Client {
+	t.Helper()
+	store.Clear()
+	mux := http.NewServeMux()
+	handler.Register(mux)
+	var h http.Handler = mux
+	if wrap != nil {
+		h = wrap(h)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	c := client.New(srv.URL)
+	c.BaseBackoff = time.Millisecond
+	c.MaxBackoff = 5 * time.Millisecond
+	return c
+}
+
+// failing answers the first n requests with status.
+func failing(n int32, status int, calls *atomic.Int32) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) <= n {
+				http.Error(w, "try again", status)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestTaskLifecycle(t *testing.T) {
+	c := newServer(t, nil)
+	ctx := context.Background()
+
+	created, err := c.CreateTask(ctx, model.Task{Title: "Write client", Priority: model.PriorityHigh})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" || created.Title != "Write client" {
+		t.Fatalf("unexpected task: %+v", created)
+	}
+
+	created.Done = true
+	updated, err := c.UpdateTask(ctx, created.ID, created)
+	if err != nil || !updated.Done {
+		t.Fatalf("expected the task to be done, got %+v, %v", updated, err)
+	}
+
+	got, err := c.GetTask(ctx, created.ID)
+	if err != nil || got.Title != "Write client" {
+		t.Fatalf("unexpected task: %+v, %v", got, err)
+	}
+
+	stats, err := c.Stats(ctx)
+	if err != nil || stats != (client.Stats{Total: 1, Completed: 1}) {
+		t.Fatalf("unexpected stats: %+v, %v", stats, err)
+	}
+
+	if err := c.DeleteTask(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetTask(ctx, created.ID); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	c := newServer(t, nil)
+	ctx := context.Background()
+
+	_, err := c.GetTask(ctx, "missing")
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound || apiErr.Detail != "task not found" {
+		t.Fatalf("expected a 404 problem, got %v", err)
+	}
+
+	_, err = c.CreateTask(ctx, model.Task{Priority: 7})
+	if !errors.Is(err, client.ErrValidation) || !errors.As(err, &apiErr) {
+		t.Fatalf("expected ErrValidation, got %v", err)
+	}
+	if len(apiErr.Errors) != 2 || apiErr.Errors[0].Pointer != "#/title" {
+		t.Fatalf("expected field errors for title and priority, got %+v", apiErr.Errors)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var calls atomic.Int32
+	c := newServer(t, failing(2, http.StatusServiceUnavailable, &calls))
+	if _, err := c.Stats(context.Background()); err != nil {
+		t.Fatalf("expected the call to succeed after retries, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls.Load())
+	}
+
+	calls.Store(0)
+	c.MaxRetries = 1
+	_, err := c.Stats(context.Background())
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusServiceUnavailable || calls.Load() != 2 {
+		t.Fatalf("expected a 503 after 2 attempts, got %v after %d", err, calls.Load())
+	}
+}
+
+func TestCreateIsNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	c := newServer(t, failing(1, http.StatusServiceUnavailable, &calls))
+	if _, err := c.CreateTask(context.Background(), model.Task{Title: "Once"}); err == nil {
+		t.Fatal("expected the 503 to be returned")
+	}
+	if calls.Load() != 1 || len(store.All()) != 0 {
+		t.Fatalf("expected a single attempt, got %d", calls.Load())
+	}
+}
+
+func TestRateLimitedHonoursRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	c := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := c.Stats(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected to still be waiting out Retry-After, got %v", err)
+	}
+}
+
+func TestListTasksPages(t *testing.T) {
+	var calls atomic.Int32
+	c := newServer(t, failing(0, 0, &calls))
+	ctx := context.Background()
+	for _, title := range []string{"A", "B", "C", "D", "E"} {
+		if _, err := c.CreateTask(ctx, model.Task{Title: title}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	calls.Store(0)
+
+	done := false
+	var titles string
+	for task, err := range c.ListTasks(ctx, client.Filter{Done: &done, PageSize: 2}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		titles += task.Title
+	}
+	if titles != "ABCDE" || calls.Load() != 3 {
+		t.Fatalf("expected ABCDE over 3 pages, got %q over %d", titles, calls.Load())
+	}
+
+	calls.Store(0)
+	for range c.ListTasks(ctx, client.Filter{PageSize: 2}) {
+		break
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected stopping early to fetch one page, got %d", calls.Load())
+	}
+}
+
+// writeCert writes a self-signed client certificate and its key to files
+// in a temporary directory.
+func writeCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "alice"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "alice"}}, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestUseClientCertificate(t *testing.T) {
+	store.Clear()
+	mux := http.NewServeMux()
+	handler.Register(mux)
+	srv := httptest.NewUnstartedServer(mux)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := client.New(srv.URL)
+	c.MaxRetries = 0
+	if err := c.UseClientCertificate("", "", caFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Stats(context.Background()); err == nil {
+		t.Fatal("expected the server to refuse a client without a certificate")
+	}
+
+	certFile, keyFile := writeCert(t)
+	if err := c.UseClientCertificate(certFile, keyFile, caFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Stats(context.Background()); err != nil {
+		t.Fatalf("expected the certificate to be accepted, got %v", err)
+	}
+	if http.DefaultClient.Transport != nil {
+		t.Fatal("expected the default client to be left alone")
+	}
+
+	if err := c.UseClientCertificate(certFile, "", ""); err == nil {
+		t.Fatal("expected a certificate without its key to fail")
+	}
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Errors that an *Error matches with errors.Is, by status code.
+var (
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+	ErrRateLimited  = errors.New("rate limited")
+)
+
+// Error is an RFC 9457 problem returned by the API.
+type Error struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail"`
+	Instance string       `json:"instance"`
+	Errors   []FieldError `json:"errors"`
+	// RetryAfter is the delay the server asked for on 429 and 503
+	// responses.
+	RetryAfter time.Duration `json:"-"`
+}
+
+// FieldError locates one problem in the request body by JSON pointer, or in
+// a parameter by name.
+type FieldError struct {
+	Pointer   string `json:"pointer"`
+	Parameter string `json:"parameter"`
+	Code      string `json:"code"`
+	Detail    string `json:"detail"`
+}
+
+func (e *Error) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("task api: %d %s", e.Status, e.Title)
+	}
+	return fmt.Sprintf("task api: %d %s: %s", e.Status, e.Title, e.Detail)
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrValidation:
+		return e.Status == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.Status == http.StatusUnauthorized
+	case ErrNotFound:
+		return e.Status == http.StatusNotFound
+	case ErrRateLimited:
+		return e.Status == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// readError turns an error response into an *Error, falling back to the
+// status line when the body is not a problem.
+func readError(resp *http.Response) *Error {
+	defer resp.Body.Close()
+	e := &Error{}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	json.Unmarshal(data, e)
+	e.Status = resp.StatusCode
+	if e.Title == "" {
+		e.Title = http.StatusText(resp.StatusCode)
+	}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		e.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return e
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/sawez-deepsource/demo-go/client"
+	"github.com/sawez-deepsource/demo-go/codec"
+	"github.com/sawez-deepsource/demo-go/model"
+)
+
+// priorityFlag is a -priority value given by name, as in "-priority high".
+type priorityFlag struct {
+	p   *model.Priority
+	set bool
+}
+
+func (f *priorityFlag) String() string {
+	if f.p == nil {
+		return ""
+	}
+	return f.p.String()
+}
+
+func (f *priorityFlag) Set(s string) error {
+	p, err := model.ParsePriority(s)
+	if err != nil {
+		return errors.New("priority must be low, medium or high")
+	}
+	*f.p, f.set = p, true
+	return nil
+}
+
+func (a *app) list(args []string) error {
+	var (
+		filter   client.Filter
+		priority model.Priority
+	)
+	fs := a.flags("list")
+	done := fs.Bool("done", false, "only done tasks")
+	pending := fs.Bool("pending", false, "only pending tasks")
+	prio := &priorityFlag{p: &priority}
+	fs.Var(prio, "priority", "only tasks with this `priority`")
+	fs.StringVar(&filter.Project, "project", "", "only tasks in this `project`")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	// The API applies only one filter at a time, so refuse to quietly
+	// ignore the others.
+	filters := 0
+	for _, set := range []bool{*done, *pending, prio.set, filter.Project != ""} {
+		if set {
+			filters++
+		}
+	}
+	if fs.NArg() > 0 || filters > 1 {
+		return errUsage
+	}
+	if *done || *pending {
+		filter.Done = done
+	}
+	if prio.set {
+		filter.Priority = &priority
+	}
+
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	var tasks []model.Task
+	for t, err := range c.ListTasks(a.ctx, filter) {
+		if err != nil {
+			return err
+		}
+		tasks = append(tasks, t)
+	}
+	return a.printTasks(tasks)
+}
+
+func (a *app) add(args []string) error {
+	var t model.Task
+	fs := a.flags("add")
+	fs.StringVar(&t.Description, "description", "", "task `description`")
+	fs.Var(&priorityFlag{p: &t.Priority}, "priority", "task `priority`: low, medium or high")
+	fs.StringVar(&t.Project, "project", "", "task `project`")
+	fs.StringVar(&t.Due, "due", "", "due `date`, as 2006-01-02 or an RFC 3339 timestamp")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errUsage
+	}
+	t.Title = strings.Join(fs.Args(), " ")
+
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	created, err := c.CreateTask(a.ctx, t)
+	if err != nil {
+		return err
+	}
+	return a.printTask(created)
+}
+
+func (a *app) show(args []string) error {
+	fs := a.flags("show")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	t, err := c.GetTask(a.ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return a.printTask(t)
+}
+
+// edit writes the task as YAML to a temporary file, opens it in $EDITOR
+// (or vi) and saves the result. An unchanged file is not sent.
+func (a *app) edit(args []string) error {
+	fs := a.flags("edit")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	t, err := c.GetTask(a.ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := (codec.YAML{}).Encode(&buf, t); err != nil {
+		return err
+	}
+	original := buf.Bytes()
+	f, err := os.CreateTemp("", "taskctl-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(original)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	editor := cmp.Or(os.Getenv("VISUAL"), os.Getenv("EDITOR"), "vi")
+	// Run through the shell so that editors configured with arguments,
+	// such as "code --wait", work.
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", f.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, a.stdout, a.stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running %s: %w", editor, err)
+	}
+
+	edited, err := os.ReadFile(f.Name())
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(edited, original) {
+		fmt.Fprintln(a.stderr, "no changes")
+		return nil
+	}
+	var updated model.Task
+	if err := (codec.YAML{}).Decode(bytes.NewReader(edited), &updated); err != nil {
+		return fmt.Errorf("reading edited task: %w", err)
+	}
+	saved, err := c.UpdateTask(a.ctx, t.ID, updated)
+	if err != nil {
+		return err
+	}
+	return a.printTask(saved)
+}
+
+func (a *app) done(args []string) error {
+	return a.eachID("done", args, func(c *client.Client, id string) error {
+		t, err := c.GetTask(a.ctx, id)
+		if err != nil {
+			return err
+		}
+		t.Done = true
+		_, err = c.UpdateTask(a.ctx, id, t)
+		return err
+	})
+}
+
+func (a *app) rm(args []string) error {
+	return a.eachID("rm", args, func(c *client.Client, id string) error {
+		return c.DeleteTask(a.ctx, id)
+	})
+}
+
+// eachID applies fn to every ID in args, carrying on past failures and
+// reporting them together.
+func (a *app) eachID(name string, args []string, fn func(*client.Client, string) error) error {
+	fs := a.flags(name)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errUsage
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, id := range fs.Args() {
+		if err := fn(c, id); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (a *app) stats(args []string) error {
+	fs := a.flags("stats")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errUsage
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	s, err := c.Stats(a.ctx)
+	if err != nil {
+		return err
+	}
+	return a.print(s, func(t *table) {
+		t.row("Total:", fmt.Sprint(s.Total))
+		t.row("Completed:", fmt.Sprint(s.Completed))
+		t.row("Pending:", fmt.Sprint(s.Pending))
+	})
+}
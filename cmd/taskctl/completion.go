@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Task IDs are completed by listing tasks, which costs a request per
+// completion but keeps the scripts free of any state.
+const bashCompletion = `# bash completion for taskctl. Load with: source <(taskctl completion bash)
+_taskctl() {
+	local cur=${COMP_WORDS[COMP_CWORD]} cmd="" i
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case ${COMP_WORDS[i]} in
+		-config|-profile|-server|-token|-cert|-key|-cacert|-o) ((i++)) ;;
+		-*) ;;
+		*) cmd=${COMP_WORDS[i]}; break ;;
+		esac
+	done
+	case ${COMP_WORDS[COMP_CWORD-1]} in
+	-o) COMPREPLY=($(compgen -W "table json yaml" -- "$cur")); return ;;
+	-priority) COMPREPLY=($(compgen -W "low medium high" -- "$cur")); return ;;
+	esac
+	case $cmd in
+	"") COMPREPLY=($(compgen -W "%[1]s" -- "$cur")) ;;
+	show|edit|done|rm) COMPREPLY=($(compgen -W "$(taskctl list 2>/dev/null | awk 'NR > 1 { print $1 }')" -- "$cur")) ;;
+	profile) COMPREPLY=($(compgen -W "list use set rm" -- "$cur")) ;;
+	completion) COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur")) ;;
+	list) COMPREPLY=($(compgen -W "-done -pending -priority -project -o" -- "$cur")) ;;
+	add) COMPREPLY=($(compgen -W "-description -priority -project -due -o" -- "$cur")) ;;
+	esac
+}
+complete -F _taskctl taskctl
+`
+
+const zshCompletion = `#compdef taskctl
+# zsh completion for taskctl. Load with: source <(taskctl completion zsh)
+_taskctl() {
+	local -a commands=(%[2]s)
+	if (( CURRENT == 2 )); then
+		_describe command commands
+		return
+	fi
+	case $words[2] in
+	show|edit|done|rm) compadd -- ${(f)"$(taskctl list 2>/dev/null | awk 'NR > 1 { print $1 }')"} ;;
+	profile) compadd list use set rm ;;
+	completion) compadd bash zsh fish ;;
+	list) compadd -- -done -pending -priority -project -o ;;
+	add) compadd -- -description -priority -project -due -o ;;
+	esac
+}
+compdef _taskctl taskctl
+`
+
+const fishCompletion = `# fish completion for taskctl. Load with: taskctl completion fish | source
+complete -c taskctl -f
+%[3]scomplete -c taskctl -n '__fish_seen_subcommand_from show edit done rm' -a '(taskctl list 2>/dev/null | awk "NR > 1 { print \$1 }")'
+complete -c taskctl -n '__fish_seen_subcommand_from profile' -a 'list use set rm'
+complete -c taskctl -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'
+complete -c taskctl -o o -x -a 'table json yaml'
+complete -c taskctl -o priority -x -a 'low medium high'
+`
+
+func (a *app) completion(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	var names, described, fish []string
+	for _, c := range commands {
+		names = append(names, c.name)
+		described = append(described, fmt.Sprintf("%q", c.name+":"+c.summary))
+		fish = append(fish, fmt.Sprintf("complete -c taskctl -n __fish_use_subcommand -a %s -d %q\n", c.name, c.summary))
+	}
+	scripts := map[string]string{"bash": bashCompletion, "zsh": zshCompletion, "fish": fishCompletion}
+	script, ok := scripts[args[0]]
+	if !ok {
+		return errUsage
+	}
+	_, err := fmt.Fprintf(a.stdout, script, strings.Join(names, " "), strings.Join(described, " "), strings.Join(fish, ""))
+	return err
+}
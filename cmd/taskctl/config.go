@@ -0,0 +1,189 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultServer = "http://localhost:8000"
+
+// config is the taskctl config file. Each profile names a server and the
+// token and TLS files to use with it; Current is used when -profile is not
+// given.
+type config struct {
+	Current  string             `yaml:"current,omitempty"`
+	Profiles map[string]profile `yaml:"profiles,omitempty"`
+}
+
+type profile struct {
+	Server string   `yaml:"server"`
+	Token  string   `yaml:"token,omitempty"`
+	TLS    tlsFiles `yaml:",inline"`
+}
+
+// tlsFiles are the client certificate and key to present to servers that
+// verify clients, and the CA bundle to verify the server with instead of
+// the system's.
+type tlsFiles struct {
+	Cert string `yaml:"cert,omitempty"`
+	Key  string `yaml:"key,omitempty"`
+	CA   string `yaml:"cacert,omitempty"`
+}
+
+// flags registers -cert, -key and -cacert on fs with the defaults in def.
+func (f *tlsFiles) flags(fs *flag.FlagSet, def tlsFiles, suffix string) {
+	fs.StringVar(&f.Cert, "cert", def.Cert, "client certificate `file`"+suffix)
+	fs.StringVar(&f.Key, "key", def.Key, "client key `file`"+suffix)
+	fs.StringVar(&f.CA, "cacert", def.CA, "CA bundle `file` to verify the server with"+suffix)
+}
+
+// missing reports whether any file is unset.
+func (f tlsFiles) missing() bool {
+	return f.Cert == "" || f.Key == "" || f.CA == ""
+}
+
+// or fills each unset file from def.
+func (f tlsFiles) or(def tlsFiles) tlsFiles {
+	return tlsFiles{cmp.Or(f.Cert, def.Cert), cmp.Or(f.Key, def.Key), cmp.Or(f.CA, def.CA)}
+}
+
+// configFile returns the -config path, or taskctl/config.yaml in the user's
+// config directory.
+func (a *app) configFile() string {
+	if a.configPath != "" {
+		return a.configPath
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "taskctl", "config.yaml")
+}
+
+// loadConfig reads the config file at path. A missing file is an empty
+// config.
+func loadConfig(path string) (config, error) {
+	var cfg config
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// saveConfig writes cfg to path, readable only by the user since profiles
+// hold tokens.
+func saveConfig(path string, cfg config) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+func (a *app) profile(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	path := a.configFile()
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]profile{}
+	}
+
+	switch args[0] {
+	case "list":
+		if len(args) != 1 {
+			return errUsage
+		}
+		return a.printProfiles(cfg)
+	case "use":
+		if len(args) != 2 {
+			return errUsage
+		}
+		if _, ok := cfg.Profiles[args[1]]; !ok {
+			return fmt.Errorf("no profile named %q", args[1])
+		}
+		cfg.Current = args[1]
+	case "set":
+		if len(args) < 2 || strings.HasPrefix(args[1], "-") {
+			return errUsage
+		}
+		name := args[1]
+		p := cfg.Profiles[name]
+		fs := a.flags("profile set")
+		fs.StringVar(&p.Server, "server", p.Server, "server `URL`")
+		fs.StringVar(&p.Token, "token", p.Token, "API token")
+		p.TLS.flags(fs, p.TLS, "")
+		if err := parseFlags(fs, args[2:]); err != nil {
+			return err
+		}
+		if fs.NArg() > 0 {
+			return errUsage
+		}
+		if p.Server == "" {
+			p.Server = defaultServer
+		}
+		cfg.Profiles[name] = p
+		if cfg.Current == "" {
+			cfg.Current = name
+		}
+	case "rm":
+		if len(args) != 2 {
+			return errUsage
+		}
+		if _, ok := cfg.Profiles[args[1]]; !ok {
+			return fmt.Errorf("no profile named %q", args[1])
+		}
+		delete(cfg.Profiles, args[1])
+		if cfg.Current == args[1] {
+			cfg.Current = ""
+		}
+	default:
+		return errUsage
+	}
+	return saveConfig(path, cfg)
+}
+
+func (a *app) printProfiles(cfg config) error {
+	type row struct {
+		Name    string `json:"name"`
+		Server  string `json:"server"`
+		Current bool   `json:"current"`
+	}
+	var rows []row
+	for _, name := range slices.Sorted(maps.Keys(cfg.Profiles)) {
+		rows = append(rows, row{name, cfg.Profiles[name].Server, name == cfg.Current})
+	}
+	return a.print(rows, func(t *table) {
+		t.row("", "NAME", "SERVER")
+		for _, r := range rows {
+			mark := ""
+			if r.Current {
+				mark = "*"
+			}
+			t.row(mark, r.Name, r.Server)
+		}
+	})
+}
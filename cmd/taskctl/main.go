@@ -0,0 +1,206 @@
+// Command taskctl manages tasks on a task API server from the terminal.
+//
+// Usage:
+//
+//	taskctl [global flags] <command> [flags] [args]
+//
+// Run "taskctl help" for the list of commands.
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sawez-deepsource/demo-go/client"
+)
+
+// errUsage marks errors caused by how taskctl was invoked, which exit with
+// status 2 instead of 1. errFlags is the same for bad flags, which the flag
+// package has already reported.
+var (
+	errUsage = errors.New("usage")
+	errFlags = errors.New("invalid flags")
+)
+
+// command is a taskctl subcommand. run receives the arguments after the
+// command name.
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(a *app, args []string) error
+}
+
+var commands []command
+
+func init() {
+	// Assigned in init because help refers back to commands.
+	commands = []command{
+		{"list", "[-done | -pending | -priority P | -project NAME]", "List tasks", (*app).list},
+		{"add", "[-description TEXT] [-priority P] [-project NAME] [-due DATE] TITLE...", "Create a task", (*app).add},
+		{"show", "ID", "Show a task", (*app).show},
+		{"edit", "ID", "Edit a task in your editor", (*app).edit},
+		{"done", "ID...", "Mark tasks as done", (*app).done},
+		{"rm", "ID...", "Delete tasks", (*app).rm},
+		{"stats", "", "Show task counts", (*app).stats},
+		{"profile", "list | use NAME | set NAME [-server URL] [-token TOKEN] [-cert FILE -key FILE] [-cacert FILE] | rm NAME", "Manage server profiles", (*app).profile},
+		{"completion", "bash | zsh | fish", "Print a shell completion script", (*app).completion},
+		{"help", "", "Show this help", (*app).help},
+	}
+}
+
+// app holds what every command needs: where to write, the selected
+// profile and the flags that override it.
+type app struct {
+	ctx    context.Context
+	stdout io.Writer
+	stderr io.Writer
+
+	configPath string
+	profileArg string
+	server     string
+	token      string
+	tls        tlsFiles
+	output     string
+}
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	a := &app{ctx: ctx, stdout: stdout, stderr: stderr}
+	fs := flag.NewFlagSet("taskctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { a.usage(stderr) }
+	fs.StringVar(&a.configPath, "config", os.Getenv("TASKCTL_CONFIG"), "config file `path`")
+	fs.StringVar(&a.profileArg, "profile", os.Getenv("TASKCTL_PROFILE"), "profile to use instead of the current one")
+	fs.StringVar(&a.server, "server", os.Getenv("TASKCTL_SERVER"), "server `URL`, overriding the profile")
+	fs.StringVar(&a.token, "token", os.Getenv("TASKCTL_TOKEN"), "API token, overriding the profile")
+	a.tls.flags(fs, tlsFiles{os.Getenv("TASKCTL_CERT"), os.Getenv("TASKCTL_KEY"), os.Getenv("TASKCTL_CACERT")}, ", overriding the profile")
+	a.outputFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		a.usage(stderr)
+		return 2
+	}
+
+	name, rest := fs.Arg(0), fs.Args()[1:]
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		err := c.run(a, rest)
+		switch {
+		case err == nil:
+			return 0
+		case errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.Is(err, errFlags):
+			return 2
+		case errors.Is(err, errUsage):
+			fmt.Fprintf(stderr, "usage: taskctl %s %s\n", c.name, c.args)
+			return 2
+		}
+		fmt.Fprintf(stderr, "taskctl: %s\n", describe(err))
+		return 1
+	}
+	fmt.Fprintf(stderr, "taskctl: unknown command %q\n", name)
+	a.usage(stderr)
+	return 2
+}
+
+// outputFlag registers -o on fs, so it is accepted both before and after
+// the command name.
+func (a *app) outputFlag(fs *flag.FlagSet) {
+	if a.output == "" {
+		a.output = "table"
+	}
+	fs.StringVar(&a.output, "o", a.output, "output `format`: table, json or yaml")
+}
+
+// flags returns a flag set for a command that reports errors to stderr.
+func (a *app) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("taskctl "+name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	a.outputFlag(fs)
+	return fs
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return errFlags
+	}
+	return err
+}
+
+// client returns an API client for the selected server, token and TLS
+// files.
+func (a *app) client() (*client.Client, error) {
+	server, token, files := a.server, a.token, a.tls
+	if server == "" || token == "" || files.missing() {
+		cfg, err := loadConfig(a.configFile())
+		if err != nil {
+			return nil, err
+		}
+		name := cmp.Or(a.profileArg, cfg.Current)
+		p, ok := cfg.Profiles[name]
+		if a.profileArg != "" && !ok {
+			return nil, fmt.Errorf("no profile named %q", name)
+		}
+		server, token = cmp.Or(server, p.Server), cmp.Or(token, p.Token)
+		files = files.or(p.TLS)
+	}
+	c := client.New(cmp.Or(server, defaultServer))
+	c.Token = token
+	if files != (tlsFiles{}) {
+		if err := c.UseClientCertificate(files.Cert, files.Key, files.CA); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (a *app) usage(w io.Writer) {
+	fmt.Fprint(w, "usage: taskctl [-config path] [-profile name] [-server URL] [-token token] [-cert file -key file] [-cacert file] [-o format] <command>\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n", c.name, c.summary)
+	}
+}
+
+func (a *app) help(args []string) error {
+	for _, c := range commands {
+		if len(args) == 1 && c.name == args[0] {
+			fmt.Fprintf(a.stdout, "usage: taskctl %s %s\n\n%s.\n", c.name, c.args, c.summary)
+			return nil
+		}
+	}
+	a.usage(a.stdout)
+	return nil
+}
+
+// describe formats err for the terminal, listing each field of a
+// validation problem on its own line.
+func describe(err error) string {
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || len(apiErr.Errors) == 0 {
+		return err.Error()
+	}
+	lines := []string{apiErr.Title}
+	for _, fe := range apiErr.Errors {
+		lines = append(lines, "  "+fe.Detail)
+	}
+	return strings.Join(lines, "\n")
+}
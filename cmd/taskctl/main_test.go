@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/handler"
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+// setup starts an API server and returns a function running taskctl
+// against it with a private config file.
+func setup(t *testing.T) func(args ...string) (string, string, int) {
+	t.Helper()
+	store.Clear()
+	mux := http.NewServeMux()
+	handler.Register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("TASKCTL_CONFIG", config)
+	t.Setenv("TASKCTL_PROFILE", "")
+	t.Setenv("TASKCTL_SERVER", "")
+	t.Setenv("TASKCTL_TOKEN", "")
+	t.Setenv("TASKCTL_CERT", "")
+	t.Setenv("TASKCTL_KEY", "")
+	t.Setenv("TASKCTL_CACERT", "")
+	if code := run(context.Background(), []string{"profile", "set", "test", "-server", srv.URL}, os.Stdout, os.Stderr); code != 0 {
+		t.Fatalf("setting up profile: exit %d", code)
+	}
+
+	return func(args ...string) (string, string, int) {
+		var stdout, stderr bytes.Buffer
+		code := run(context.Background(), args, &stdout, &stderr)
+		return stdout.String(), stderr.String(), code
+	}
+}
+
+func TestAddListShow(t *testing.T) {
+	taskctl := setup(t)
+
+	out, errOut, code := taskctl("add", "-priority", "High", "-project", "home", "Buy", "milk")
+	if code != 0 {
+		t.Fatalf("add failed with %d: %s", code, errOut)
+	}
+	if !strings.Contains(out, "Buy milk") || !strings.Contains(out, "high") {
+		t.Fatalf("expected the created task, got %q", out)
+	}
+	taskctl("add", "Walk dog")
+
+	out, _, _ = taskctl("list", "-priority", "high")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[1], "Buy milk") {
+		t.Fatalf("expected a header and one row, got %q", out)
+	}
+
+	out, _, _ = taskctl("-o", "json", "list")
+	var tasks []model.Task
+	if err := json.Unmarshal([]byte(out), &tasks); err != nil || len(tasks) != 2 {
+		t.Fatalf("expected 2 tasks as JSON, got %q (%v)", out, err)
+	}
+
+	out, _, _ = taskctl("show", "-o", "yaml", tasks[0].ID)
+	if !strings.Contains(out, "title: Buy milk") || !strings.Contains(out, "project: home") {
+		t.Fatalf("expected the task as YAML, got %q", out)
+	}
+}
+
+func TestDoneRmStats(t *testing.T) {
+	taskctl := setup(t)
+	taskctl("add", "One")
+	taskctl("add", "Two")
+
+	if _, errOut, code := taskctl("done", "1"); code != 0 {
+		t.Fatalf("done failed with %d: %s", code, errOut)
+	}
+	out, _, _ := taskctl("stats")
+	if !strings.Contains(out, "Completed:  1") || !strings.Contains(out, "Pending:    1") {
+		t.Fatalf("unexpected stats: %q", out)
+	}
+
+	_, errOut, code := taskctl("rm", "2", "99")
+	if code != 1 || !strings.Contains(errOut, "99: task api: 404") {
+		t.Fatalf("expected the missing ID to be reported, got %d: %s", code, errOut)
+	}
+	out, _, _ = taskctl("-o", "json", "stats")
+	if strings.TrimSpace(out) != "{\n  \"total\": 1,\n  \"completed\": 1,\n  \"pending\": 0\n}" {
+		t.Fatalf("expected the other task to be deleted, got %q", out)
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	taskctl := setup(t)
+
+	_, errOut, code := taskctl("add", "-priority", "urgent", "Task")
+	if code != 2 || !strings.Contains(errOut, "priority must be low, medium or high") {
+		t.Fatalf("expected a usage error, got %d: %s", code, errOut)
+	}
+
+	_, errOut, code = taskctl("add", "-project", "two words", "Task")
+	if code != 1 || !strings.Contains(errOut, "Validation failed\n  project") {
+		t.Fatalf("expected the field error, got %d: %s", code, errOut)
+	}
+
+	_, errOut, code = taskctl("list", "-done", "-priority", "high")
+	if code != 2 || !strings.Contains(errOut, "usage: taskctl list") {
+		t.Fatalf("expected filters the API cannot combine to be a usage error, got %d: %s", code, errOut)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	taskctl := setup(t)
+	taskctl("add", "Old title")
+
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "sed -i -e 's/Old title/New title/' -e 's/done: false/done: true/'")
+	out, errOut, code := taskctl("edit", "1")
+	if code != 0 {
+		t.Fatalf("edit failed with %d: %s", code, errOut)
+	}
+	task, _ := store.Get("1")
+	if task.Title != "New title" || !task.Done || !strings.Contains(out, "New title") {
+		t.Fatalf("expected the edit to be saved, got %+v", task)
+	}
+
+	t.Setenv("EDITOR", "true")
+	if _, errOut, _ := taskctl("edit", "1"); !strings.Contains(errOut, "no changes") {
+		t.Fatalf("expected an unchanged file to be skipped, got %q", errOut)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	taskctl := setup(t)
+
+	taskctl("profile", "set", "prod", "-server", "https://tasks.example.com", "-token", "secret")
+	out, _, _ := taskctl("profile", "list")
+	if !strings.Contains(out, "*  test") || strings.Contains(out, "secret") {
+		t.Fatalf("expected test to stay current and tokens hidden, got %q", out)
+	}
+
+	if _, _, code := taskctl("profile", "use", "prod"); code != 0 {
+		t.Fatal("expected to switch profiles")
+	}
+	a := &app{configPath: os.Getenv("TASKCTL_CONFIG")}
+	c, err := a.client()
+	if err != nil || c.BaseURL != "https://tasks.example.com" || c.Token != "secret" {
+		t.Fatalf("expected the prod profile, got %+v (%v)", c, err)
+	}
+
+	a = &app{configPath: os.Getenv("TASKCTL_CONFIG"), profileArg: "prod", token: "override"}
+	if c, _ := a.client(); c.Token != "override" {
+		t.Fatalf("expected -token to override the profile, got %q", c.Token)
+	}
+
+	missing := filepath.Join(t.TempDir(), "ca.pem")
+	taskctl("profile", "set", "prod", "-cacert", missing)
+	if _, err := a.client(); err == nil || !strings.Contains(err.Error(), missing) {
+		t.Fatalf("expected the profile's CA bundle to be loaded, got %v", err)
+	}
+
+	if _, errOut, code := taskctl("-profile", "missing", "list"); code != 1 || !strings.Contains(errOut, `no profile named "missing"`) {
+		t.Fatalf("expected an unknown profile to fail, got %d: %s", code, errOut)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	taskctl := setup(t)
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		out, _, code := taskctl("completion", shell)
+		if code != 0 || !strings.Contains(out, "taskctl") || !strings.Contains(out, "edit") || strings.Contains(out, "%!") {
+			t.Fatalf("unexpected %s completion: %q", shell, out)
+		}
+	}
+	if _, _, code := taskctl("completion", "powershell"); code != 2 {
+		t.Fatalf("expected an unknown shell to be a usage error, got %d", code)
+	}
+}
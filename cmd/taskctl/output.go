@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sawez-deepsource/demo-go/codec"
+	"github.com/sawez-deepsource/demo-go/model"
+)
+
+// table writes aligned columns.
+type table struct {
+	w *tabwriter.Writer
+}
+
+func (t *table) row(cells ...string) {
+	fmt.Fprintln(t.w, strings.Join(cells, "\t"))
+}
+
+// print writes v as JSON or YAML, or calls fill to lay it out as a table,
+// depending on -o.
+func (a *app) print(v any, fill func(*table)) error {
+	switch a.output {
+	case "json":
+		enc := json.NewEncoder(a.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		return codec.YAML{}.Encode(a.stdout, v)
+	case "table":
+		t := &table{tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)}
+		fill(t)
+		return t.w.Flush()
+	}
+	return fmt.Errorf("unknown output format %q; use table, json or yaml", a.output)
+}
+
+func (a *app) printTasks(tasks []model.Task) error {
+	if tasks == nil {
+		tasks = []model.Task{}
+	}
+	return a.print(tasks, func(t *table) {
+		t.row("ID", "DONE", "PRIORITY", "PROJECT", "DUE", "TITLE")
+		for _, task := range tasks {
+			done := ""
+			if task.Done {
+				done = "x"
+			}
+			t.row(task.ID, done, task.Priority.String(), task.Project, task.Due, task.Title)
+		}
+	})
+}
+
+func (a *app) printTask(task model.Task) error {
+	return a.print(task, func(t *table) {
+		t.row("ID:", task.ID)
+		t.row("Title:", task.Title)
+		t.row("Done:", fmt.Sprint(task.Done))
+		t.row("Priority:", task.Priority.String())
+		t.row("Project:", task.Project)
+		t.row("Due:", task.Due)
+		t.row("Created:", task.CreatedAt)
+		t.row("Updated:", task.UpdatedAt)
+		if task.Description != "" {
+			t.row("")
+			for _, line := range strings.Split(task.Description, "\n") {
+				t.row(line)
+			}
+		}
+	})
+}
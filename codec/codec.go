@@ -0,0 +1,161 @@
+// Package codec holds the registry of wire formats the API can speak and
+// picks one for each request from its Accept and Content-Type headers.
+package codec
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec encodes responses and decodes request bodies in one format.
+type Codec interface {
+	// Name is the short format name used in error messages, e.g. "json".
+	Name() string
+	// MediaTypes lists the media types served by the codec. The first is
+	// used as the response Content-Type.
+	MediaTypes() []string
+	Encode(w io.Writer, v any) error
+	Decode(r io.Reader, v any) error
+}
+
+// ErrUnsupported is returned by Decode when a codec cannot represent the
+// requested type, such as a webhook subscription sent as CSV.
+var ErrUnsupported = errors.New("type not supported by this format")
+
+var (
+	mu      sync.RWMutex
+	codecs  []Codec
+	byMedia = map[string]Codec{}
+)
+
+// Default is used when a request names no format at all.
+var Default Codec = JSON{}
+
+func init() {
+	Register(JSON{})
+	Register(YAML{})
+	Register(MessagePack{})
+	Register(CSV{})
+}
+
+// Register adds a codec, or replaces the ones already serving any of its
+// media types, taking the place of the first of them in negotiation.
+func Register(c Codec) {
+	mu.Lock()
+	defer mu.Unlock()
+	at := -1
+	kept := codecs[:0]
+	for _, old := range codecs {
+		if !slices.ContainsFunc(old.MediaTypes(), func(mt string) bool { return slices.Contains(c.MediaTypes(), mt) }) {
+			kept = append(kept, old)
+			continue
+		}
+		if at < 0 {
+			at = len(kept)
+		}
+		for _, mt := range old.MediaTypes() {
+			delete(byMedia, mt)
+		}
+	}
+	if at < 0 {
+		at = len(kept)
+	}
+	codecs = slices.Insert(kept, at, c)
+	for _, mt := range c.MediaTypes() {
+		byMedia[mt] = c
+	}
+}
+
+// ForContentType returns the codec for a request body. An empty Content-Type
+// means the default format, so clients that never set one keep working.
+func ForContentType(contentType string) (Codec, bool) {
+	if contentType == "" {
+		return Default, true
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := byMedia[mt]
+	return c, ok
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+	order     int
+}
+
+// Negotiate picks the codec that best matches an Accept header, honouring
+// q-values and wildcards. It reports false when nothing acceptable is
+// registered.
+func Negotiate(accept string) (Codec, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return Default, true
+	}
+	var ranges []acceptRange
+	for i, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, acceptRange{mediaType: mt, q: q, order: i})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return specificity(ranges[i].mediaType) > specificity(ranges[j].mediaType)
+	})
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, r := range ranges {
+		switch {
+		case r.mediaType == "*/*":
+			return Default, true
+		case strings.HasSuffix(r.mediaType, "/*"):
+			prefix := strings.TrimSuffix(r.mediaType, "*")
+			if strings.HasPrefix(Default.MediaTypes()[0], prefix) {
+				return Default, true
+			}
+			for _, c := range codecs {
+				if strings.HasPrefix(c.MediaTypes()[0], prefix) {
+					return c, true
+				}
+			}
+		default:
+			if c, ok := byMedia[r.mediaType]; ok {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func specificity(mediaType string) int {
+	switch {
+	case mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(mediaType, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
@@ -0,0 +1,100 @@
+package codec_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/codec"
+	"github.com/sawez-deepsource/demo-go/model"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "json"},
+		{"*/*", "json"},
+		{"application/yaml", "yaml"},
+		{"text/html, application/x-msgpack;q=0.5", "msgpack"},
+		{"application/json;q=0.4, text/csv", "csv"},
+		{"text/*", "csv"},
+		{"application/*;q=0.9, text/csv;q=0.1", "json"},
+	}
+	for _, tt := range tests {
+		c, ok := codec.Negotiate(tt.accept)
+		if !ok || c.Name() != tt.want {
+			t.Errorf("Negotiate(%q): expected %s, got %v", tt.accept, tt.want, c)
+		}
+	}
+	if _, ok := codec.Negotiate("text/html, image/png"); ok {
+		t.Error("expected no codec for unsupported types")
+	}
+	if _, ok := codec.Negotiate("application/json;q=0"); ok {
+		t.Error("expected q=0 to exclude a type")
+	}
+}
+
+func TestRoundTripAllCodecs(t *testing.T) {
+	task := model.Task{ID: "1", Title: "Ship, it", Done: true, Priority: model.PriorityHigh, Project: "launch"}
+	for _, name := range []string{"application/json", "application/yaml", "application/msgpack", "text/csv"} {
+		c, ok := codec.ForContentType(name)
+		if !ok {
+			t.Fatalf("no codec for %s", name)
+		}
+		var buf bytes.Buffer
+		if err := c.Encode(&buf, task); err != nil {
+			t.Fatalf("%s: encoding: %v", name, err)
+		}
+		var got model.Task
+		if err := c.Decode(&buf, &got); err != nil {
+			t.Fatalf("%s: decoding: %v", name, err)
+		}
+		if got != task {
+			t.Fatalf("%s: expected %+v, got %+v", name, task, got)
+		}
+	}
+}
+
+func TestYAMLKeepsFieldOrder(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (codec.YAML{}).Encode(&buf, model.Task{ID: "1", Title: "1.5"}); err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+	want := "id: \"1\"\ntitle: \"1.5\"\ndescription: \"\"\ndone: false\n"
+	if !strings.HasPrefix(buf.String(), want) {
+		t.Fatalf("expected output to start with %q, got %q", want, buf.String())
+	}
+}
+
+func TestCSVFlattensOtherValues(t *testing.T) {
+	var buf bytes.Buffer
+	v := []map[string]any{{"total": 3, "tags": []string{"a"}}}
+	if err := (codec.CSV{}).Encode(&buf, v); err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+	if buf.String() != "tags,total\n\"[\"\"a\"\"]\",3\n" {
+		t.Fatalf("unexpected csv %q", buf.String())
+	}
+
+	var out map[string]any
+	if err := (codec.CSV{}).Decode(strings.NewReader("total\n3\n"), &out); err != codec.ErrUnsupported {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+// tsv stands in for a replacement of the built-in CSV codec.
+type tsv struct{ codec.CSV }
+
+func (tsv) Name() string { return "tsv" }
+
+func TestRegisterReplaces(t *testing.T) {
+	codec.Register(tsv{})
+	t.Cleanup(func() { codec.Register(codec.CSV{}) })
+	for _, accept := range []string{"text/csv", "text/*"} {
+		if c, ok := codec.Negotiate(accept); !ok || c.Name() != "tsv" {
+			t.Errorf("Negotiate(%q): expected the replacement, got %v", accept, c)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/taskcsv"
+)
+
+// CSV writes tasks with the taskcsv column layout. Any other value is
+// flattened through its JSON form: an object becomes a single row and an
+// array of objects one row each, with nested values left as JSON. Only tasks
+// can be decoded.
+type CSV struct{}
+
+func (CSV) Name() string { return "csv" }
+
+func (CSV) MediaTypes() []string { return []string{"text/csv"} }
+
+func (CSV) Encode(w io.Writer, v any) error {
+	switch v := v.(type) {
+	case model.Task:
+		return writeTasks(w, []model.Task{v})
+	case []model.Task:
+		return writeTasks(w, v)
+	}
+	return writeFlattened(w, v)
+}
+
+func writeTasks(w io.Writer, tasks []model.Task) error {
+	tw := taskcsv.NewWriter(w)
+	for _, t := range tasks {
+		if err := tw.Write(t); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+func writeFlattened(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var rows []json.RawMessage
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return err
+		}
+	} else {
+		rows = []json.RawMessage{data}
+	}
+
+	cw := csv.NewWriter(w)
+	var header []string
+	for i, raw := range rows {
+		keys, values, err := objectFields(raw)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			header = keys
+			if err := cw.Write(header); err != nil {
+				return err
+			}
+		}
+		record := make([]string, len(header))
+		for j, k := range header {
+			record[j] = values[k]
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// objectFields returns the keys of a JSON object in document order and each
+// value as a cell: strings unquoted, everything else as raw JSON.
+func objectFields(raw json.RawMessage) ([]string, map[string]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil, fmt.Errorf("csv can only represent objects")
+	}
+	var keys []string
+	values := map[string]string{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key := tok.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		var s string
+		if err := json.Unmarshal(value, &s); err == nil {
+			values[key] = s
+		} else {
+			values[key] = strings.TrimSpace(string(value))
+		}
+		keys = append(keys, key)
+	}
+	return keys, values, nil
+}
+
+func (CSV) Decode(r io.Reader, v any) error {
+	var tasks []model.Task
+	switch v.(type) {
+	case *model.Task, *[]model.Task:
+	default:
+		return ErrUnsupported
+	}
+	rows, err := taskcsv.Decode(r, nil)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			e := row.Errors[0]
+			return fmt.Errorf("line %d: %s: %s", row.Line, e.Column, e.Message)
+		}
+		tasks = append(tasks, row.Task)
+	}
+	switch v := v.(type) {
+	case *model.Task:
+		if len(tasks) != 1 {
+			return fmt.Errorf("expected exactly one task row, got %d", len(tasks))
+		}
+		*v = tasks[0]
+	case *[]model.Task:
+		*v = tasks
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package codec
+
+import (
+	"encoding/json"
+	"io"
+)
+
+type JSON struct{}
+
+func (JSON) Name() string { return "json" }
+
+func (JSON) MediaTypes() []string {
+	return []string{"application/json", "application/problem+json"}
+}
+
+func (JSON) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (JSON) Decode(r io.Reader, v any) error {
+	return json.NewDecoder(r).Decode(v)
+}
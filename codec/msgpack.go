@@ -0,0 +1,30 @@
+package codec
+
+import (
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MessagePack uses the json struct tags so that field names match the other
+// formats.
+type MessagePack struct{}
+
+func (MessagePack) Name() string { return "msgpack" }
+
+func (MessagePack) MediaTypes() []string {
+	return []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}
+}
+
+func (MessagePack) Encode(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	return enc.Encode(v)
+}
+
+func (MessagePack) Decode(r io.Reader, v any) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
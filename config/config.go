@@ -0,0 +1,328 @@
+// Package config loads the server configuration from defaults, a YAML or
+// TOML file, environment variables and command-line flags, in increasing
+// order of precedence.
+//
+// Every setting has a dotted key such as server.addr. The same key names the
+// setting in the file (as nested tables or mappings), the flag (-server.addr)
+// and, unless the field says otherwise, the environment variable
+// (TASKAPI_SERVER_ADDR).
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/model"
+)
+
+// Config is the effective configuration of the server. Field tags set the
+// key where the field name does not spell it (key), the environment variable
+// (env), describe the flag (help), mask the value when printed (secret) and
+// mark settings that SIGHUP may change without a restart (reload); reload on
+// a struct applies to all of its fields.
+type Config struct {
+	Server    Server
+	GRPC      GRPC
+	TLS       TLS
+	Auth      Auth
+	Log       Log
+	Tracing   Tracing
+	RateLimit RateLimit
+	Limits    model.Limits `reload:"true"`
+	GraphQL   GraphQL      `key:"graphql" reload:"true"`
+	Webhooks  Webhooks
+	Calendar  Calendar
+}
+
+type Server struct {
+	Addr            string        `help:"host:port to listen on"`
+	ReadTimeout     time.Duration `help:"maximum time to read a request, 0 for none"`
+	WriteTimeout    time.Duration `help:"maximum time to write a response, 0 for none"`
+	IdleTimeout     time.Duration `help:"how long idle keep-alive connections stay open"`
+	ShutdownTimeout time.Duration `reload:"true" help:"how long shutdown waits for requests, workers and flushes before abandoning them"`
+	DrainDelay      time.Duration `reload:"true" help:"how long readiness fails before shutdown starts"`
+	ProbeAddr       string        `help:"host:port serving only the health probes over plain HTTP, empty to disable; probes need it when tls.client_auth is require"`
+}
+
+// GRPC serves the task API over gRPC, with the same TLS settings as HTTP.
+// It is off unless an address is set.
+type GRPC struct {
+	Addr string `help:"host:port for the gRPC API, e.g. :9090; empty disables it"`
+}
+
+// TLS settings are read at startup; the certificate files themselves are
+// reloaded whenever they change.
+type TLS struct {
+	CertFile     string `help:"PEM certificate chain; serves HTTPS when set"`
+	KeyFile      string `help:"PEM private key for tls.cert_file"`
+	ClientCAFile string `help:"PEM bundle of CAs trusted to sign client certificates"`
+	ClientAuth   string `help:"none, optional or require a client certificate"`
+	UsersFile    string `help:"YAML map from client certificate identity to user; others get 403"`
+}
+
+// Auth identifies API clients by bearer token, as TLS does by certificate.
+type Auth struct {
+	TokensFile string `help:"YAML map from the SHA-256 hex digest of each API token to its user; other bearer tokens get 401"`
+}
+
+// RateLimit has the fields of handler.RateLimitConfig, which it converts
+// to; config does not import the packages it configures.
+type RateLimit struct {
+	ReadRate         float64 `help:"reads per second allowed to each client"`
+	ReadBurst        int     `help:"reads a client may make at once"`
+	WriteRate        float64 `help:"writes per second allowed to each client"`
+	WriteBurst       int     `help:"writes a client may make at once"`
+	DailyCreateQuota int     `help:"tasks each client may create per UTC day"`
+}
+
+// GraphQL has the fields of handler.GraphQLConfig, which it converts to.
+type GraphQL struct {
+	MaxDepth      int `help:"deepest field nesting an operation may have"`
+	MaxComplexity int `help:"most fields an operation may resolve, counting list items"`
+}
+
+type Log struct {
+	Level  string `env:"LOG_LEVEL" reload:"true" help:"debug, info, warn or error"`
+	Format string `env:"LOG_FORMAT" help:"json or text"`
+}
+
+type Tracing struct {
+	Exporter string `env:"OTEL_TRACES_EXPORTER" help:"none, stdout or otlp"`
+}
+
+type Webhooks struct {
+	StateFile string `env:"WEBHOOK_STATE_FILE" help:"file persisting webhooks, empty to keep them in memory"`
+}
+
+type Calendar struct {
+	FeedSecret string `env:"CALENDAR_FEED_SECRET" secret:"true" help:"key signing calendar feed URLs, random if empty"`
+	StateFile  string `env:"CALENDAR_STATE_FILE" help:"file persisting calendar subscriptions, empty to keep them in memory"`
+}
+
+// Default returns the configuration used when nothing is set.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:            ":8000",
+			ReadTimeout:     5 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     30 * time.Second,
+			ShutdownTimeout: 10 * time.Second,
+			DrainDelay:      5 * time.Second,
+		},
+		TLS:     TLS{ClientAuth: "none"},
+		Log:     Log{Level: "info", Format: "json"},
+		Tracing: Tracing{Exporter: "none"},
+		// As handler.DefaultRateLimitConfig.
+		RateLimit: RateLimit{
+			ReadRate:         20,
+			ReadBurst:        40,
+			WriteRate:        5,
+			WriteBurst:       10,
+			DailyCreateQuota: 10000,
+		},
+		Limits: model.DefaultLimits,
+		// As handler.DefaultGraphQLConfig.
+		GraphQL: GraphQL{MaxDepth: 10, MaxComplexity: 5000},
+	}
+}
+
+// Validate reports every invalid setting, one per line.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(key string, ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+		}
+	}
+
+	_, _, err := net.SplitHostPort(c.Server.Addr)
+	check("server.addr", err == nil, "%q is not host:port", c.Server.Addr)
+	check("server.read_timeout", c.Server.ReadTimeout >= 0, "must not be negative")
+	check("server.write_timeout", c.Server.WriteTimeout >= 0, "must not be negative")
+	check("server.idle_timeout", c.Server.IdleTimeout >= 0, "must not be negative")
+	check("server.shutdown_timeout", c.Server.ShutdownTimeout > 0, "must be positive")
+	check("server.drain_delay", c.Server.DrainDelay >= 0, "must not be negative")
+	if c.Server.ProbeAddr != "" {
+		_, _, err := net.SplitHostPort(c.Server.ProbeAddr)
+		check("server.probe_addr", err == nil, "%q is not host:port", c.Server.ProbeAddr)
+		check("server.probe_addr", c.Server.ProbeAddr != c.Server.Addr, "must differ from server.addr")
+	}
+	if c.GRPC.Addr != "" {
+		_, _, err := net.SplitHostPort(c.GRPC.Addr)
+		check("grpc.addr", err == nil, "%q is not host:port", c.GRPC.Addr)
+		check("grpc.addr", c.GRPC.Addr != c.Server.Addr, "must differ from server.addr")
+	}
+
+	tls := c.TLS
+	check("tls.key_file", (tls.CertFile == "") == (tls.KeyFile == ""), "must be set together with tls.cert_file")
+	switch tls.ClientAuth {
+	case "none":
+	case "optional", "require":
+		check("tls.client_ca_file", tls.ClientCAFile != "", "must be set to verify client certificates")
+		check("tls.client_auth", tls.CertFile != "", "needs tls.cert_file")
+	default:
+		check("tls.client_auth", false, "%q is not none, optional or require", tls.ClientAuth)
+	}
+	check("tls.users_file", tls.UsersFile == "" || tls.ClientAuth != "none", "needs tls.client_auth to be optional or require")
+
+	var level slog.Level
+	check("log.level", level.UnmarshalText([]byte(c.Log.Level)) == nil, "%q is not debug, info, warn or error", c.Log.Level)
+	check("log.format", c.Log.Format == "json" || c.Log.Format == "text", "%q is not json or text", c.Log.Format)
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		check("tracing.exporter", false, "%q is not none, stdout or otlp", c.Tracing.Exporter)
+	}
+
+	check("rate_limit.read_rate", c.RateLimit.ReadRate > 0, "must be positive")
+	check("rate_limit.read_burst", c.RateLimit.ReadBurst > 0, "must be positive")
+	check("rate_limit.write_rate", c.RateLimit.WriteRate > 0, "must be positive")
+	check("rate_limit.write_burst", c.RateLimit.WriteBurst > 0, "must be positive")
+	check("rate_limit.daily_create_quota", c.RateLimit.DailyCreateQuota > 0, "must be positive")
+	check("limits.max_title_length", c.Limits.MaxTitleLength > 0, "must be positive")
+	check("limits.max_description_size", c.Limits.MaxDescriptionSize > 0, "must be positive")
+	check("limits.max_project_length", c.Limits.MaxProjectLength > 0, "must be positive")
+	check("graphql.max_depth", c.GraphQL.MaxDepth > 0, "must be positive")
+	check("graphql.max_complexity", c.GraphQL.MaxComplexity > 0, "must be positive")
+	return errors.Join(errs...)
+}
+
+// Reload returns current with the reloadable settings taken from next, and
+// the keys of settings that differ in next but only take effect on restart.
+func Reload(current, next Config) (Config, []string) {
+	var restart []string
+	cur, nxt := fields(&current), fields(&next)
+	for i, f := range cur {
+		if f.value.Equal(nxt[i].value) {
+			continue
+		}
+		if f.reload {
+			f.value.Set(nxt[i].value)
+		} else {
+			restart = append(restart, f.key)
+		}
+	}
+	return current, restart
+}
+
+// field is one setting of a Config.
+type field struct {
+	key    string // dotted path, e.g. server.addr
+	env    string
+	help   string
+	secret bool
+	reload bool
+	value  reflect.Value
+}
+
+// fields lists the settings of c in declaration order. Values are
+// addressable, so setting them changes c.
+func fields(c *Config) []field {
+	var out []field
+	var walk func(v reflect.Value, prefix string, reload bool)
+	walk = func(v reflect.Value, prefix string, reload bool) {
+		t := v.Type()
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			name := snake(sf.Name)
+			if key := sf.Tag.Get("key"); key != "" {
+				name = key
+			}
+			f := field{
+				key:    prefix + name,
+				env:    sf.Tag.Get("env"),
+				help:   sf.Tag.Get("help"),
+				secret: sf.Tag.Get("secret") == "true",
+				reload: reload || sf.Tag.Get("reload") == "true",
+				value:  v.Field(i),
+			}
+			if sf.Type.Kind() == reflect.Struct {
+				walk(f.value, f.key+".", f.reload)
+				continue
+			}
+			if f.env == "" {
+				f.env = "TASKAPI_" + strings.ToUpper(strings.ReplaceAll(f.key, ".", "_"))
+			}
+			out = append(out, f)
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "", false)
+	return out
+}
+
+// snake converts a Go field name such as MaxTitleLength or ClientCAFile to
+// max_title_length or client_ca_file.
+func snake(name string) string {
+	var b strings.Builder
+	upper := func(c byte) bool { return c >= 'A' && c <= 'Z' }
+	for i := range len(name) {
+		c := name[i]
+		if upper(c) {
+			// A word starts at an upper case letter after a lower case one,
+			// or at the last letter of an acronym followed by lower case.
+			if i > 0 && (!upper(name[i-1]) || i+1 < len(name) && !upper(name[i+1])) {
+				b.WriteByte('_')
+			}
+			c += 'a' - 'A'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// set parses s into v according to v's type.
+func set(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration like 5s or 1m30s", s)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", s)
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", s)
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", s)
+		}
+		v.SetBool(b)
+	default:
+		panic("config: unsupported field type " + v.Type().String())
+	}
+	return nil
+}
+
+// format is the inverse of set.
+func format(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Kind() == reflect.Float64 {
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	}
+	return fmt.Sprint(v.Interface())
+}
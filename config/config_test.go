@@ -0,0 +1,200 @@
+package config_test
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/config"
+	"github.com/sawez-deepsource/demo-go/handler"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func load(t *testing.T, args ...string) (config.Config, error) {
+	t.Helper()
+	l, err := config.Parse("taskapi", args, io.Discard)
+	if err != nil {
+		t.Fatalf("parsing %v: %v", args, err)
+	}
+	return l.Load()
+}
+
+func TestPrecedence(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+server:
+  addr: ":9000"
+  read_timeout: 1s
+  write_timeout: 2s
+log:
+  level: debug
+rate_limit:
+  read_rate: 2.5
+`)
+	t.Setenv("TASKAPI_SERVER_WRITE_TIMEOUT", "3s")
+	t.Setenv("LOG_LEVEL", "warn")
+
+	t.Setenv("TASKAPI_GRAPHQL_MAX_DEPTH", "6")
+
+	cfg, err := load(t, "-config", path, "-log.level", "error", "-limits.max_title_length=20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Addr != ":9000" || cfg.Server.ReadTimeout != time.Second || cfg.RateLimit.ReadRate != 2.5 {
+		t.Errorf("expected the file to override defaults, got %+v", cfg)
+	}
+	if cfg.Server.WriteTimeout != 3*time.Second {
+		t.Errorf("expected the environment to override the file, got %s", cfg.Server.WriteTimeout)
+	}
+	if cfg.Log.Level != "error" || cfg.Limits.MaxTitleLength != 20 {
+		t.Errorf("expected flags to override everything, got %+v", cfg)
+	}
+	if cfg.GraphQL.MaxDepth != 6 {
+		t.Errorf("expected the graphql key to name the variable, got %+v", cfg.GraphQL)
+	}
+	if cfg.Server.IdleTimeout != config.Default().Server.IdleTimeout {
+		t.Errorf("expected unset settings to keep their defaults, got %s", cfg.Server.IdleTimeout)
+	}
+}
+
+func TestEmptyVariablesApply(t *testing.T) {
+	path := writeFile(t, "config.yaml", "grpc:\n  addr: \":9090\"\n")
+	cfg, err := load(t, "-config", path)
+	if err != nil || cfg.GRPC.Addr != ":9090" {
+		t.Fatalf("expected the file to enable gRPC, got %+v (%v)", cfg.GRPC, err)
+	}
+	t.Setenv("TASKAPI_GRPC_ADDR", "")
+	if cfg, err := load(t, "-config", path); err != nil || cfg.GRPC.Addr != "" {
+		t.Errorf("expected an empty variable to disable gRPC, got %+v (%v)", cfg.GRPC, err)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	cfg := config.Default()
+	if cfg.GRPC.Addr != "" {
+		t.Errorf("expected gRPC to be off by default, got %q", cfg.GRPC.Addr)
+	}
+	if handler.RateLimitConfig(cfg.RateLimit) != handler.DefaultRateLimitConfig || handler.GraphQLConfig(cfg.GraphQL) != handler.DefaultGraphQLConfig {
+		t.Errorf("expected the handler's defaults, got %+v and %+v", cfg.RateLimit, cfg.GraphQL)
+	}
+}
+
+func TestTOML(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+[server]
+drain_delay = "0s"
+
+[webhooks]
+state_file = "/var/lib/taskapi/webhooks.json"
+`)
+	t.Setenv("TASKAPI_CONFIG", path)
+	cfg, err := load(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.DrainDelay != 0 || cfg.Webhooks.StateFile != "/var/lib/taskapi/webhooks.json" {
+		t.Errorf("expected the TOML file to be applied, got %+v", cfg)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	path := writeFile(t, "config.yaml", "server:\n  adress: \":9000\"\n  read_timeout: 5\n")
+	_, err := load(t, "-config", path)
+	if err == nil || !strings.Contains(err.Error(), "unknown setting server.adress") || !strings.Contains(err.Error(), `server.read_timeout: "5" is not a duration`) {
+		t.Errorf("expected every file error to be reported, got %v", err)
+	}
+
+	_, err = load(t, "-config", writeFile(t, "config.json", "{}"))
+	if err == nil || !strings.Contains(err.Error(), `unknown configuration format ".json"`) {
+		t.Errorf("expected an unknown format to fail, got %v", err)
+	}
+
+	t.Setenv("TASKAPI_RATE_LIMIT_READ_BURST", "many")
+	if _, err := load(t); err == nil || !strings.Contains(err.Error(), `TASKAPI_RATE_LIMIT_READ_BURST: "many" is not an integer`) {
+		t.Errorf("expected the variable to be named, got %v", err)
+	}
+	os.Unsetenv("TASKAPI_RATE_LIMIT_READ_BURST")
+
+	_, err = load(t, "-server.addr", "localhost", "-grpc.addr", "localhost", "-log.format", "xml", "-limits.max_project_length", "0", "-graphql.max_complexity", "0")
+	for _, want := range []string{`server.addr: "localhost" is not host:port`, `grpc.addr: "localhost" is not host:port`, `log.format: "xml" is not json or text`, "limits.max_project_length: must be positive", "graphql.max_complexity: must be positive"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+
+	_, err = load(t, "-tls.cert_file", "server.pem", "-tls.client_auth", "require", "-tls.users_file", "users.yaml")
+	for _, want := range []string{"tls.key_file: must be set together with tls.cert_file", "tls.client_ca_file: must be set to verify client certificates"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+	if _, err := load(t, "-grpc.addr", ":8000"); err == nil || !strings.Contains(err.Error(), "grpc.addr: must differ from server.addr") {
+		t.Errorf("expected a shared port to fail, got %v", err)
+	}
+	if _, err := load(t, "-tls.users_file", "users.yaml"); err == nil || !strings.Contains(err.Error(), "tls.users_file: needs tls.client_auth") {
+		t.Errorf("expected users without client auth to fail, got %v", err)
+	}
+
+	if _, err := config.Parse("taskapi", []string{"-server.idle_timeout", "soon"}, io.Discard); err == nil {
+		t.Error("expected a bad flag value to fail parsing")
+	}
+	if _, err := config.Parse("taskapi", []string{"-help"}, io.Discard); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("expected flag.ErrHelp, got %v", err)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	t.Setenv("CALENDAR_FEED_SECRET", "hunter2hunter2")
+	cfg, err := load(t, "-print-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := cfg.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "hunter2") || !strings.Contains(out, "feed_secret: '********'") {
+		t.Errorf("expected the secret to be masked, got:\n%s", out)
+	}
+	if !strings.Contains(out, "server:\n  addr: :8000\n  read_timeout: 5s\n") {
+		t.Errorf("expected file layout, got:\n%s", out)
+	}
+
+	// The output is a valid configuration file.
+	t.Setenv("CALENDAR_FEED_SECRET", "")
+	l, _ := config.Parse("taskapi", []string{"-config", writeFile(t, "printed.yaml", strings.Replace(out, "'********'", `""`, 1))}, io.Discard)
+	if printed, err := l.Load(); err != nil || printed != config.Default() {
+		t.Errorf("expected the printed defaults to load back, got %+v (%v)", printed, err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	current := config.Default()
+	next := current
+	next.Server.Addr = ":9000"
+	next.Server.DrainDelay = time.Minute
+	next.Log.Level = "debug"
+	next.Limits.MaxTitleLength = 10
+
+	got, restart := config.Reload(current, next)
+	if got.Server.Addr != ":8000" || got.Server.DrainDelay != time.Minute || got.Log.Level != "debug" || got.Limits.MaxTitleLength != 10 {
+		t.Errorf("expected only reloadable settings to change, got %+v", got)
+	}
+	if len(restart) != 1 || restart[0] != "server.addr" {
+		t.Errorf("expected server.addr to need a restart, got %v", restart)
+	}
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Loader remembers the command line so that the configuration can be
+// loaded again, for example on SIGHUP, with the same flags.
+type Loader struct {
+	// File is the configuration file from -config or TASKAPI_CONFIG, if
+	// any. Its extension selects the format: .yaml, .yml or .toml.
+	File string
+	// PrintConfig is set by -print-config.
+	PrintConfig bool
+
+	flags map[string]string // flag values by key, in the order given
+	order []string
+}
+
+// Parse reads the command-line flags in args, which excludes the program
+// name. Setting flags are only checked for syntax here; Load applies them.
+// Usage goes to output; -help returns flag.ErrHelp.
+func Parse(name string, args []string, output io.Writer) (*Loader, error) {
+	l := &Loader{File: os.Getenv("TASKAPI_CONFIG"), flags: map[string]string{}}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&l.File, "config", l.File, "YAML or TOML configuration `file` (env TASKAPI_CONFIG)")
+	fs.BoolVar(&l.PrintConfig, "print-config", false, "print the effective configuration, with secrets masked, and exit")
+
+	defaults := Default()
+	for _, f := range fields(&defaults) {
+		usage := fmt.Sprintf("(env %s)", f.env)
+		if def := format(f.value); def != "" {
+			usage = fmt.Sprintf("(env %s, default %s)", f.env, def)
+		}
+		if f.help != "" {
+			usage = f.help + " " + usage
+		}
+		fs.Func(f.key, usage, func(s string) error {
+			if err := set(f.value, s); err != nil {
+				return err
+			}
+			if _, ok := l.flags[f.key]; !ok {
+				l.order = append(l.order, f.key)
+			}
+			l.flags[f.key] = s
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return l, nil
+}
+
+// Load builds the configuration from the defaults, the file, the
+// environment and the flags, each overriding the ones before, and
+// validates it.
+func (l *Loader) Load() (Config, error) {
+	c := Default()
+	byKey := map[string]field{}
+	all := fields(&c)
+	for _, f := range all {
+		byKey[f.key] = f
+	}
+
+	if l.File != "" {
+		values, err := readFile(l.File)
+		if err != nil {
+			return Config{}, err
+		}
+		var errs []error
+		for _, kv := range values {
+			f, ok := byKey[kv.key]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown setting %s", l.File, kv.key))
+				continue
+			}
+			if err := set(f.value, kv.value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: %w", l.File, kv.key, err))
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, f := range all {
+		// A variable set to the empty string sets the setting to it, e.g.
+		// TASKAPI_GRPC_ADDR= disables gRPC whatever the file says.
+		if s, ok := os.LookupEnv(f.env); ok {
+			if err := set(f.value, s); err != nil {
+				return Config{}, fmt.Errorf("%s: %w", f.env, err)
+			}
+		}
+	}
+
+	for _, key := range l.order {
+		// Flags were checked by Parse.
+		set(byKey[key].value, l.flags[key])
+	}
+
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+type keyValue struct {
+	key, value string
+}
+
+// readFile decodes a YAML or TOML file into its settings, flattening nested
+// sections into dotted keys.
+func readFile(path string) ([]keyValue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("%s: unknown configuration format %q; use .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var out []keyValue
+	flatten(doc, "", &out)
+	return out, nil
+}
+
+func flatten(m map[string]any, prefix string, out *[]keyValue) {
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		switch v := m[k].(type) {
+		case map[string]any:
+			flatten(v, prefix+k+".", out)
+		case nil:
+			*out = append(*out, keyValue{prefix + k, ""})
+		default:
+			*out = append(*out, keyValue{prefix + k, fmt.Sprint(v)})
+		}
+	}
+}
+
+// Print writes c as YAML in the layout of a configuration file, with
+// secrets masked.
+func (c Config) Print(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	sections := map[string]*yaml.Node{}
+	for _, f := range fields(&c) {
+		parent := root
+		keys := strings.Split(f.key, ".")
+		for i := range keys[:len(keys)-1] {
+			path := strings.Join(keys[:i+1], ".")
+			node, ok := sections[path]
+			if !ok {
+				node = &yaml.Node{Kind: yaml.MappingNode}
+				sections[path] = node
+				parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: keys[i]}, node)
+			}
+			parent = node
+		}
+		value := format(f.value)
+		if f.secret && value != "" {
+			value = "********"
+		}
+		scalar := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+		if f.value.Kind() == reflect.String || f.value.Type() == durationType {
+			scalar.Tag = "!!str"
+		}
+		parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: keys[len(keys)-1]}, scalar)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,160 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+// BufferSize is the number of past events kept for clients that resume with
+// a Last-Event-ID.
+const BufferSize = 1024
+
+// subscriberBuffer is how many events may queue up for a subscriber before it
+// is considered too slow and dropped.
+const subscriberBuffer = 64
+
+// Removed is the type of the events Filter.Select makes of updates that
+// take a task out of the filter. Publish never sends it.
+const Removed store.ChangeType = "removed"
+
+type Event struct {
+	ID   uint64           `json:"id"`
+	Type store.ChangeType `json:"type"`
+	Task model.Task       `json:"task"`
+	// Previous is the task before an update, or nil.
+	Previous *model.Task `json:"-"`
+}
+
+// Subscription receives every event published after it was created. C is
+// closed when the subscription is cancelled or falls too far behind; a
+// dropped client should resubscribe with the last ID it saw.
+type Subscription struct {
+	C <-chan Event
+	// Missed is set when events after the ID passed to Subscribe are no
+	// longer buffered, or that ID is from before a restart, so the backlog
+	// is incomplete. Clients keeping a copy of the tasks should reload them
+	// and follow the subscription from Since instead.
+	Missed bool
+	// Since is the ID of the last event published before the subscription.
+	Since uint64
+	ch    chan Event
+}
+
+var (
+	mu     sync.Mutex
+	ring   = make([]Event, 0, BufferSize)
+	start  int
+	lastID uint64
+	subs   = map[*Subscription]struct{}{}
+)
+
+func init() {
+	store.Subscribe(func(c store.Change) {
+		e := Event{Type: c.Type, Task: c.Task}
+		if c.Type == store.Updated {
+			e.Previous = &c.Previous
+		}
+		publish(e)
+	})
+}
+
+// Publish records an event and fans it out to all subscribers.
+func Publish(typ store.ChangeType, t model.Task) Event {
+	return publish(Event{Type: typ, Task: t})
+}
+
+func publish(e Event) Event {
+	mu.Lock()
+	defer mu.Unlock()
+	lastID++
+	e.ID = lastID
+	if len(ring) < BufferSize {
+		ring = append(ring, e)
+	} else {
+		ring[start] = e
+		start = (start + 1) % BufferSize
+	}
+	for s := range subs {
+		select {
+		case s.ch <- e:
+		default:
+			delete(subs, s)
+			close(s.ch)
+		}
+	}
+	return e
+}
+
+// Subscribe returns the buffered events with an ID greater than after,
+// oldest first, together with a subscription for everything that follows.
+// If some of those events are no longer buffered it sets the subscription's
+// Missed.
+func Subscribe(after uint64) ([]Event, *Subscription) {
+	mu.Lock()
+	defer mu.Unlock()
+	ch := make(chan Event, subscriberBuffer)
+	s := &Subscription{C: ch, Since: lastID, ch: ch}
+	subs[s] = struct{}{}
+	s.Missed = after > lastID || len(ring) > 0 && after+1 < ring[start].ID
+	var backlog []Event
+	for i := range ring {
+		e := ring[(start+i)%len(ring)]
+		if e.ID > after {
+			backlog = append(backlog, e)
+		}
+	}
+	return backlog, s
+}
+
+// Cancel stops delivery to s and closes its channel.
+func Cancel(s *Subscription) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := subs[s]; ok {
+		delete(subs, s)
+		close(s.ch)
+	}
+}
+
+// LastID returns the ID of the most recently published event.
+func LastID() uint64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return lastID
+}
+
+// Filter selects tasks, and the events about them, by their properties.
+// Nil and empty fields match everything.
+type Filter struct {
+	Done     *bool           `json:"done,omitempty"`
+	Priority *model.Priority `json:"priority,omitempty"`
+	Project  string          `json:"project,omitempty"`
+}
+
+// Select returns the event a client watching f should get for e: e itself
+// if its task matches, or a Removed event if it is an update that took the
+// task out of f. It reports false if the client should get nothing.
+func (f Filter) Select(e Event) (Event, bool) {
+	if f.Match(e.Task) {
+		return e, true
+	}
+	if e.Previous != nil && f.Match(*e.Previous) {
+		return Event{ID: e.ID, Type: Removed, Task: e.Task}, true
+	}
+	return Event{}, false
+}
+
+func (f Filter) Match(t model.Task) bool {
+	if f.Done != nil && t.Done != *f.Done {
+		return false
+	}
+	if f.Priority != nil && t.Priority != *f.Priority {
+		return false
+	}
+	if f.Project != "" && t.Project != f.Project {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/events"
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+func TestSubscribeReplaysBoundedBacklog(t *testing.T) {
+	first := events.LastID()
+	for i := 0; i < events.BufferSize+10; i++ {
+		events.Publish(store.Created, model.NewTask("Task", "desc", model.PriorityLow))
+	}
+
+	backlog, sub := events.Subscribe(first)
+	defer events.Cancel(sub)
+
+	if len(backlog) != events.BufferSize {
+		t.Fatalf("expected %d buffered events, got %d", events.BufferSize, len(backlog))
+	}
+	if backlog[0].ID != first+11 {
+		t.Fatalf("expected oldest buffered event %d, got %d", first+11, backlog[0].ID)
+	}
+	if last := backlog[len(backlog)-1].ID; last != events.LastID() {
+		t.Fatalf("expected newest buffered event %d, got %d", events.LastID(), last)
+	}
+	if !sub.Missed || sub.Since != events.LastID() {
+		t.Fatalf("expected the evicted events to be reported, got %+v", sub)
+	}
+
+	for after, missed := range map[uint64]bool{first + 10: false, events.LastID(): false, events.LastID() + 5: true} {
+		_, sub := events.Subscribe(after)
+		events.Cancel(sub)
+		if sub.Missed != missed {
+			t.Errorf("Subscribe(%d): expected Missed %v", after, missed)
+		}
+	}
+}
+
+func TestFilterSelect(t *testing.T) {
+	store.Clear()
+	_, sub := events.Subscribe(events.LastID())
+	defer events.Cancel(sub)
+	task := store.Add(model.NewTask("Watched", "desc", model.PriorityLow))
+	task.MarkDone()
+	store.Update(task.ID, task)
+	task.Title = "Still done"
+	store.Update(task.ID, task)
+
+	open := false
+	f := events.Filter{Done: &open}
+	var got []store.ChangeType
+	for range 3 {
+		if e, ok := f.Select(<-sub.C); ok {
+			got = append(got, e.Type)
+		}
+	}
+	if len(got) != 2 || got[0] != store.Created || got[1] != events.Removed {
+		t.Fatalf("expected created then removed, got %v", got)
+	}
+}
+
+func TestStoreMutationsArePublished(t *testing.T) {
+	store.Clear()
+	_, sub := events.Subscribe(events.LastID())
+	defer events.Cancel(sub)
+
+	task := store.Add(model.NewTask("Watched", "desc", model.PriorityLow))
+	task.MarkDone()
+	store.Update(task.ID, task)
+	store.Delete(task.ID)
+
+	for _, want := range []store.ChangeType{store.Created, store.Updated, store.Deleted} {
+		e := <-sub.C
+		if e.Type != want || e.Task.ID != task.ID {
+			t.Fatalf("expected %s event for task %s, got %s for %s", want, task.ID, e.Type, e.Task.ID)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package grpcapi_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	taskapiv1 "github.com/sawez-deepsource/demo-go/api/taskapi/v1"
+	"github.com/sawez-deepsource/demo-go/grpcapi"
+	"github.com/sawez-deepsource/demo-go/handler"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+type testServer struct {
+	svc    *grpcapi.Service
+	srv    *grpc.Server
+	client taskapiv1.TaskServiceClient
+}
+
+// serve starts the service on an in-memory listener and returns a client
+// connected to it.
+func serve(t *testing.T) testServer {
+	t.Helper()
+	return serveLimited(t, handler.RateLimitConfig{
+		ReadRate: 1000, ReadBurst: 1000,
+		WriteRate: 1000, WriteBurst: 1000,
+		DailyCreateQuota: 1000,
+	})
+}
+
+func serveLimited(t *testing.T, limits handler.RateLimitConfig) testServer {
+	t.Helper()
+	return serveWithTokens(t, limits, nil)
+}
+
+func serveWithTokens(t *testing.T, limits handler.RateLimitConfig, tokens map[string]string) testServer {
+	t.Helper()
+	store.Clear()
+	lis := bufconn.Listen(1 << 20)
+	svc := grpcapi.NewService()
+	srv := grpcapi.NewServer(svc, nil, tokens, handler.NewRateLimiter(limits))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return testServer{svc, srv, taskapiv1.NewTaskServiceClient(conn)}
+}
+
+func newTask(title string, priority taskapiv1.Priority) *taskapiv1.Task {
+	return &taskapiv1.Task{Title: title, Description: "desc", Priority: priority}
+}
+
+func TestCRUD(t *testing.T) {
+	ctx := context.Background()
+	c := serve(t).client
+
+	created, err := c.CreateTask(ctx, &taskapiv1.CreateTaskRequest{Task: newTask("Write docs", taskapiv1.Priority_PRIORITY_HIGH)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Id == "" || created.CreatedAt == "" || created.Priority != taskapiv1.Priority_PRIORITY_HIGH {
+		t.Fatalf("unexpected created task %v", created)
+	}
+	if stored, ok := store.Get(created.Id); !ok || stored.Title != "Write docs" {
+		t.Fatalf("expected the task in the shared store, got %+v", stored)
+	}
+
+	got, err := c.GetTask(ctx, &taskapiv1.GetTaskRequest{Id: created.Id})
+	if err != nil || !proto.Equal(got, created) {
+		t.Fatalf("expected %v, got %v (%v)", created, got, err)
+	}
+
+	update := newTask("Write more docs", taskapiv1.Priority_PRIORITY_LOW)
+	update.Done = true
+	updated, err := c.UpdateTask(ctx, &taskapiv1.UpdateTaskRequest{Id: created.Id, Task: update})
+	if err != nil || !updated.Done || updated.Title != "Write more docs" || updated.CreatedAt != created.CreatedAt {
+		t.Fatalf("unexpected update %v (%v)", updated, err)
+	}
+
+	stats, err := c.GetTaskStats(ctx, &taskapiv1.GetTaskStatsRequest{})
+	if err != nil || stats.Total != 1 || stats.Completed != 1 || stats.Pending != 0 {
+		t.Fatalf("unexpected stats %v (%v)", stats, err)
+	}
+
+	if _, err := c.DeleteTask(ctx, &taskapiv1.DeleteTaskRequest{Id: created.Id}); err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range []error{
+		func() error { _, err := c.GetTask(ctx, &taskapiv1.GetTaskRequest{Id: created.Id}); return err }(),
+		func() error { _, err := c.DeleteTask(ctx, &taskapiv1.DeleteTaskRequest{Id: created.Id}); return err }(),
+		func() error {
+			_, err := c.UpdateTask(ctx, &taskapiv1.UpdateTaskRequest{Id: created.Id, Task: update})
+			return err
+		}(),
+	} {
+		if status.Code(err) != codes.NotFound {
+			t.Errorf("expected NotFound, got %v", err)
+		}
+	}
+}
+
+func TestValidation(t *testing.T) {
+	c := serve(t).client
+	_, err := c.CreateTask(context.Background(), &taskapiv1.CreateTaskRequest{Task: &taskapiv1.Task{Priority: 5, Due: "soon"}})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+	var fields []string
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.FieldViolations {
+				fields = append(fields, v.Field)
+			}
+		}
+	}
+	want := []string{"task.title", "task.priority", "task.due"}
+	if len(fields) != len(want) || fields[0] != want[0] || fields[1] != want[1] || fields[2] != want[2] {
+		t.Errorf("expected violations for %v, got %v", want, fields)
+	}
+	if store.Count() != 0 {
+		t.Error("expected nothing to be stored")
+	}
+}
+
+func TestListTasks(t *testing.T) {
+	ctx := context.Background()
+	c := serve(t).client
+	for _, title := range []string{"a", "b", "c", "d"} {
+		c.CreateTask(ctx, &taskapiv1.CreateTaskRequest{Task: newTask(title, taskapiv1.Priority_PRIORITY_HIGH)})
+	}
+	c.CreateTask(ctx, &taskapiv1.CreateTaskRequest{Task: newTask("low", taskapiv1.Priority_PRIORITY_LOW)})
+
+	high := taskapiv1.Priority_PRIORITY_HIGH
+	filter := &taskapiv1.TaskFilter{Priority: &high}
+	var titles []string
+	token := ""
+	for pages := 0; ; pages++ {
+		resp, err := c.ListTasks(ctx, &taskapiv1.ListTasksRequest{Filter: filter, PageSize: 3, PageToken: token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, task := range resp.Tasks {
+			titles = append(titles, task.Title)
+		}
+		if token = resp.NextPageToken; token == "" {
+			if pages != 1 {
+				t.Errorf("expected 2 pages, got %d", pages+1)
+			}
+			break
+		}
+	}
+	if len(titles) != 4 || titles[0] != "a" || titles[3] != "d" {
+		t.Errorf("expected the high priority tasks in order, got %v", titles)
+	}
+
+	done := false
+	resp, err := c.ListTasks(ctx, &taskapiv1.ListTasksRequest{Filter: &taskapiv1.TaskFilter{Done: &done}})
+	if err != nil || len(resp.Tasks) != 5 {
+		t.Errorf("expected done=false to match every task, got %v (%v)", resp, err)
+	}
+
+	if _, err := c.ListTasks(ctx, &taskapiv1.ListTasksRequest{PageSize: 1001}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected an oversized page to fail, got %v", err)
+	}
+	bad := taskapiv1.Priority(7)
+	if _, err := c.ListTasks(ctx, &taskapiv1.ListTasksRequest{Filter: &taskapiv1.TaskFilter{Priority: &bad}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected an invalid priority to fail, got %v", err)
+	}
+}
+
+func TestWatchTasks(t *testing.T) {
+	ts := serve(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	high := taskapiv1.Priority_PRIORITY_HIGH
+	stream, err := ts.client.WatchTasks(ctx, &taskapiv1.WatchTasksRequest{Filter: &taskapiv1.TaskFilter{Priority: &high}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The subscription starts when the server receives the call; wait for
+	// its response headers before changing tasks.
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+	created, _ := ts.client.CreateTask(ctx, &taskapiv1.CreateTaskRequest{Task: newTask("Low", taskapiv1.Priority_PRIORITY_LOW)})
+	created, _ = ts.client.CreateTask(ctx, &taskapiv1.CreateTaskRequest{Task: newTask("High", high)})
+	ts.client.DeleteTask(ctx, &taskapiv1.DeleteTaskRequest{Id: created.Id})
+	moved, _ := ts.client.CreateTask(ctx, &taskapiv1.CreateTaskRequest{Task: newTask("Moved", high)})
+	ts.client.UpdateTask(ctx, &taskapiv1.UpdateTaskRequest{Id: moved.Id, Task: newTask("Moved", taskapiv1.Priority_PRIORITY_LOW)})
+
+	e, err := stream.Recv()
+	if err != nil || e.Type != taskapiv1.EventType_EVENT_TYPE_CREATED || e.Task.Title != "High" {
+		t.Fatalf("expected the high priority task to be created, got %v (%v)", e, err)
+	}
+	e, err = stream.Recv()
+	if err != nil || e.Type != taskapiv1.EventType_EVENT_TYPE_DELETED || e.Task.Id != created.Id {
+		t.Fatalf("expected the high priority task to be deleted, got %v (%v)", e, err)
+	}
+	stream.Recv() // Moved is created.
+	if r, err := stream.Recv(); err != nil || r.Type != taskapiv1.EventType_EVENT_TYPE_REMOVED || r.Task.Id != moved.Id {
+		t.Fatalf("expected the moved task to leave the filter, got %v (%v)", r, err)
+	}
+
+	// Resuming replays the events after the one given.
+	replay, err := ts.client.WatchTasks(ctx, &taskapiv1.WatchTasksRequest{AfterEventId: e.Id - 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, err := replay.Recv(); err != nil || r.Id != e.Id {
+		t.Fatalf("expected event %d to be replayed, got %v (%v)", e.Id, r, err)
+	}
+	expired, err := ts.client.WatchTasks(ctx, &taskapiv1.WatchTasksRequest{AfterEventId: e.Id + 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := expired.Recv(); status.Code(err) != codes.OutOfRange {
+		t.Fatalf("expected an unknown event ID to be refused, got %v", err)
+	}
+
+	if err := ts.svc.Shutdown(ctx, ts.srv); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected the stream to end with Unavailable, got %v", err)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	c := serve(t).client
+	var header metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "abc-123")
+	if _, err := c.GetTaskStats(ctx, &taskapiv1.GetTaskStatsRequest{}, grpc.Header(&header)); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("x-request-id"); len(got) != 1 || got[0] != "abc-123" {
+		t.Errorf("expected the request ID to be echoed, got %v", got)
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	ctx := context.Background()
+	c := serveLimited(t, handler.RateLimitConfig{
+		ReadRate: 0, ReadBurst: 1,
+		WriteRate: 100, WriteBurst: 100,
+		DailyCreateQuota: 1,
+	}).client
+
+	if _, err := c.CreateTask(ctx, &taskapiv1.CreateTaskRequest{Task: newTask("First", taskapiv1.Priority_PRIORITY_LOW)}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.CreateTask(ctx, &taskapiv1.CreateTaskRequest{Task: newTask("Second", taskapiv1.Priority_PRIORITY_LOW)})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected the daily create quota to apply, got %v", err)
+	}
+
+	if _, err := c.GetTaskStats(ctx, &taskapiv1.GetTaskStatsRequest{}); err != nil {
+		t.Fatalf("expected reads to use their own budget, got %v", err)
+	}
+	if _, err := c.GetTaskStats(ctx, &taskapiv1.GetTaskStatsRequest{}); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected the read budget to be exhausted, got %v", err)
+	}
+}
+
+func TestTokensIdentifyClients(t *testing.T) {
+	sum := sha256.Sum256([]byte("s3cret"))
+	c := serveWithTokens(t, handler.RateLimitConfig{
+		ReadRate: 0, ReadBurst: 1,
+		WriteRate: 100, WriteBurst: 100,
+		DailyCreateQuota: 100,
+	}, map[string]string{hex.EncodeToString(sum[:]): "ci"}).client
+
+	bad := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer guessed")
+	if _, err := c.GetTaskStats(bad, &taskapiv1.GetTaskStatsRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected an unknown token to be refused, got %v", err)
+	}
+
+	// The token's user has a budget of its own, apart from its address.
+	good := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer s3cret")
+	if _, err := c.GetTaskStats(context.Background(), &taskapiv1.GetTaskStatsRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetTaskStats(good, &taskapiv1.GetTaskStatsRequest{}); err != nil {
+		t.Fatalf("expected the token to have its own budget, got %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	taskapiv1 "github.com/sawez-deepsource/demo-go/api/taskapi/v1"
+	"github.com/sawez-deepsource/demo-go/certs"
+	"github.com/sawez-deepsource/demo-go/handler"
+	"github.com/sawez-deepsource/demo-go/logging"
+)
+
+// requestIDKey is the metadata key carrying the request ID, the gRPC
+// counterpart of logging.RequestIDHeader.
+const requestIDKey = "x-request-id"
+
+// httpCodes maps the HTTP statuses the REST API answers with to the gRPC
+// code for the same failure.
+var httpCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusConflict:              codes.Aborted,
+	http.StatusPreconditionFailed:    codes.FailedPrecondition,
+	http.StatusRequestEntityTooLarge: codes.ResourceExhausted,
+	http.StatusUnprocessableEntity:   codes.InvalidArgument,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusInternalServerError:   codes.Internal,
+	http.StatusNotImplemented:        codes.Unimplemented,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+	http.StatusGatewayTimeout:        codes.DeadlineExceeded,
+}
+
+// codeForHTTP returns the gRPC code for an HTTP status, so that both APIs
+// report a failure the same way. Unlisted statuses map to Unknown.
+func codeForHTTP(status int) codes.Code {
+	if c, ok := httpCodes[status]; ok {
+		return c
+	}
+	return codes.Unknown
+}
+
+// httpError returns the gRPC error for the REST API's error response with
+// the given status and message.
+func httpError(code int, message string) error {
+	return status.Error(codeForHTTP(code), message)
+}
+
+// NewServer returns a gRPC server offering svc. Like the HTTP server, it
+// gives every call a request ID (from x-request-id metadata if valid), logs
+// it, refuses client certificates whose identity is not mapped to a user
+// when users is not nil and bearer tokens in authorization metadata that are
+// not in tokens (see handler.TokenUser), and charges the call to the
+// client's budgets in limiter. Streams are charged once, when they start.
+func NewServer(svc *Service, users, tokens map[string]string, limiter *handler.RateLimiter, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, h grpc.UnaryHandler) (any, error) {
+			begin := time.Now()
+			ctx, err := start(ctx, info.FullMethod, users, tokens, limiter)
+			var resp any
+			if err == nil {
+				resp, err = h(ctx, req)
+			}
+			logCall(ctx, info.FullMethod, begin, err)
+			return resp, err
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+			begin := time.Now()
+			ctx, err := start(ss.Context(), info.FullMethod, users, tokens, limiter)
+			if err == nil {
+				err = h(srv, &contextStream{ServerStream: ss, ctx: ctx})
+			}
+			logCall(ctx, info.FullMethod, begin, err)
+			return err
+		}),
+	)
+	srv := grpc.NewServer(opts...)
+	taskapiv1.RegisterTaskServiceServer(srv, svc)
+	return srv
+}
+
+// start adds the request ID to ctx and echoes it to the client, checks the
+// client certificate against users and the bearer token against tokens, and
+// charges the call to the client.
+func start(ctx context.Context, method string, users, tokens map[string]string, limiter *handler.RateLimiter) (context.Context, error) {
+	var id, authorization string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(requestIDKey); len(v) > 0 {
+			id = v[0]
+		}
+		if v := md.Get("authorization"); len(v) > 0 {
+			authorization = v[0]
+		}
+	}
+	id = logging.AcceptRequestID(id)
+	ctx = logging.WithRequestID(ctx, id)
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx, nil
+	}
+	var user string
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+		identity := certs.Identity(info.State.VerifiedChains[0][0])
+		user = identity
+		if users != nil {
+			if user, ok = users[identity]; !ok {
+				return ctx, httpError(http.StatusForbidden, "client certificate "+identity+" is not mapped to a user")
+			}
+		}
+	}
+
+	tokenUser, err := handler.TokenUser(tokens, authorization)
+	if err != nil {
+		return ctx, httpError(http.StatusUnauthorized, err.Error())
+	}
+	if user == "" {
+		user = tokenUser
+	}
+
+	key := handler.RateLimitKey(user, p.Addr.String())
+	if res := limiter.Allow(key, isWrite(method)); !res.Allowed {
+		grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds())))))
+		return ctx, httpError(http.StatusTooManyRequests, "rate limit exceeded")
+	}
+	return limiter.WithClient(ctx, key), nil
+}
+
+// isWrite reports whether the full method name is one that changes tasks.
+func isWrite(method string) bool {
+	name := method[strings.LastIndex(method, "/")+1:]
+	for _, prefix := range []string{"Create", "Update", "Delete"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// logCall logs one line per call, like logging.AccessLog: server errors at
+// level error, client errors at warn.
+func logCall(ctx context.Context, method string, begin time.Time, err error) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.OK, codes.Canceled:
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unimplemented, codes.Unavailable, codes.DeadlineExceeded:
+		level = slog.LevelError
+	default:
+		level = slog.LevelWarn
+	}
+	attrs := []any{"method", method, "code", code.String(), "duration", time.Since(begin)}
+	if p, ok := peer.FromContext(ctx); ok {
+		attrs = append(attrs, "remote_addr", p.Addr.String())
+	}
+	slog.Log(ctx, level, "rpc", attrs...)
+}
+
+// contextStream replaces the context of a server stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// Shutdown ends WatchTasks streams with UNAVAILABLE and stops srv once the
+// other calls in progress finish. If ctx is done first, the calls left are
+// cancelled and an error says so.
+func (s *Service) Shutdown(ctx context.Context, srv *grpc.Server) error {
+	s.stopOnce.Do(func() { close(s.stopping) })
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		srv.Stop()
+		return fmt.Errorf("cancelled the gRPC calls in progress: %w", ctx.Err())
+	}
+}
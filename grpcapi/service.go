@@ -0,0 +1,250 @@
+// Package grpcapi serves the task API over gRPC, as described in
+// api/taskapi/v1/tasks.proto. It works on the same store, validation rules
+// and change feed as the REST handlers, and maps their HTTP errors to gRPC
+// codes with codeForHTTP.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	taskapiv1 "github.com/sawez-deepsource/demo-go/api/taskapi/v1"
+	"github.com/sawez-deepsource/demo-go/events"
+	"github.com/sawez-deepsource/demo-go/handler"
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+// Service implements taskapiv1.TaskServiceServer.
+type Service struct {
+	taskapiv1.UnimplementedTaskServiceServer
+
+	// stopping is closed by Shutdown to end WatchTasks streams.
+	stopping chan struct{}
+	stopOnce sync.Once
+}
+
+func NewService() *Service {
+	return &Service{stopping: make(chan struct{})}
+}
+
+func (s *Service) CreateTask(ctx context.Context, req *taskapiv1.CreateTaskRequest) (*taskapiv1.Task, error) {
+	t := toModel(req.GetTask())
+	if err := validate(t); err != nil {
+		return nil, err
+	}
+	if !handler.ChargeCreates(ctx, 1).Allowed {
+		return nil, httpError(http.StatusTooManyRequests, "daily task creation quota exceeded")
+	}
+	created := store.AddContext(ctx, t)
+	slog.InfoContext(ctx, "task created", "id", created.ID, "title", created.Title)
+	return fromModel(created), nil
+}
+
+func (s *Service) GetTask(ctx context.Context, req *taskapiv1.GetTaskRequest) (*taskapiv1.Task, error) {
+	t, ok := store.GetContext(ctx, req.GetId())
+	if !ok {
+		return nil, httpError(http.StatusNotFound, "task not found")
+	}
+	return fromModel(t), nil
+}
+
+func (s *Service) ListTasks(ctx context.Context, req *taskapiv1.ListTasksRequest) (*taskapiv1.ListTasksResponse, error) {
+	size := int(req.GetPageSize())
+	if size < 0 || size > handler.MaxPageSize {
+		return nil, httpError(http.StatusBadRequest, fmt.Sprintf("page_size must be between 0 and %d", handler.MaxPageSize))
+	}
+	filter, err := toFilter(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	all := store.AllContext(ctx)
+	// Pages are keyed by the last ID seen, as in the REST API.
+	if after := req.GetPageToken(); after != "" {
+		i, found := slices.BinarySearchFunc(all, after, func(t model.Task, id string) int {
+			return strings.Compare(t.ID, id)
+		})
+		if found {
+			i++
+		}
+		all = all[i:]
+	}
+	resp := &taskapiv1.ListTasksResponse{}
+	for _, t := range all {
+		if !filter.Match(t) {
+			continue
+		}
+		if size > 0 && len(resp.Tasks) == size {
+			resp.NextPageToken = resp.Tasks[size-1].Id
+			break
+		}
+		resp.Tasks = append(resp.Tasks, fromModel(t))
+	}
+	return resp, nil
+}
+
+func (s *Service) UpdateTask(ctx context.Context, req *taskapiv1.UpdateTaskRequest) (*taskapiv1.Task, error) {
+	t := toModel(req.GetTask())
+	if err := validate(t); err != nil {
+		return nil, err
+	}
+	updated, ok := store.UpdateContext(ctx, req.GetId(), t)
+	if !ok {
+		return nil, httpError(http.StatusNotFound, "task not found")
+	}
+	slog.InfoContext(ctx, "task updated", "id", updated.ID, "title", updated.Title)
+	return fromModel(updated), nil
+}
+
+func (s *Service) DeleteTask(ctx context.Context, req *taskapiv1.DeleteTaskRequest) (*taskapiv1.DeleteTaskResponse, error) {
+	if !store.DeleteContext(ctx, req.GetId()) {
+		return nil, httpError(http.StatusNotFound, "task not found")
+	}
+	slog.InfoContext(ctx, "task deleted", "id", req.GetId())
+	return &taskapiv1.DeleteTaskResponse{}, nil
+}
+
+func (s *Service) GetTaskStats(ctx context.Context, _ *taskapiv1.GetTaskStatsRequest) (*taskapiv1.TaskStats, error) {
+	all := store.AllContext(ctx)
+	completed := store.FilterByDoneContext(ctx, true)
+	return &taskapiv1.TaskStats{
+		Total:     int32(len(all)),
+		Completed: int32(len(completed)),
+		Pending:   int32(len(all) - len(completed)),
+	}, nil
+}
+
+// WatchTasks streams events like GET /events, including removed events for
+// tasks an update takes out of the filter. Response headers are sent once
+// the subscription is in place, so that a client receiving them knows it
+// will see every later change. Resuming after events that are no longer
+// buffered fails with OUT_OF_RANGE.
+func (s *Service) WatchTasks(req *taskapiv1.WatchTasksRequest, stream grpc.ServerStreamingServer[taskapiv1.TaskEvent]) error {
+	filter, err := toFilter(req.GetFilter())
+	if err != nil {
+		return err
+	}
+	after := req.GetAfterEventId()
+	if after == 0 {
+		after = events.LastID()
+	}
+	backlog, sub := events.Subscribe(after)
+	defer events.Cancel(sub)
+	if sub.Missed {
+		return status.Error(codes.OutOfRange, "the events after after_event_id are no longer buffered; list the tasks again and watch without it")
+	}
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	send := func(e events.Event) error {
+		e, ok := filter.Select(e)
+		if !ok {
+			return nil
+		}
+		return stream.Send(&taskapiv1.TaskEvent{Id: e.ID, Type: eventTypes[e.Type], Task: fromModel(e.Task)})
+	}
+	for _, e := range backlog {
+		if err := send(e); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.stopping:
+			return httpError(http.StatusServiceUnavailable, "server shutting down; resume with after_event_id")
+		case e, ok := <-sub.C:
+			if !ok {
+				return httpError(http.StatusServiceUnavailable, "too slow to keep up with events; resume with after_event_id")
+			}
+			if err := send(e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+var eventTypes = map[store.ChangeType]taskapiv1.EventType{
+	store.Created:  taskapiv1.EventType_EVENT_TYPE_CREATED,
+	store.Updated:  taskapiv1.EventType_EVENT_TYPE_UPDATED,
+	store.Deleted:  taskapiv1.EventType_EVENT_TYPE_DELETED,
+	events.Removed: taskapiv1.EventType_EVENT_TYPE_REMOVED,
+}
+
+// validate applies the model's rules, reporting failures as INVALID_ARGUMENT
+// with a BadRequest detail whose fields are paths in the request.
+func validate(t model.Task) error {
+	var errs model.ValidationError
+	if !errors.As(model.Validate(t), &errs) {
+		return nil
+	}
+	detail := &errdetails.BadRequest{}
+	for _, e := range errs {
+		detail.FieldViolations = append(detail.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       "task." + e.Field,
+			Description: e.Message,
+		})
+	}
+	st, err := status.New(codeForHTTP(http.StatusBadRequest), "the request has invalid fields").WithDetails(detail)
+	if err != nil {
+		return httpError(http.StatusBadRequest, errs.Error())
+	}
+	return st.Err()
+}
+
+// toFilter converts f, which may be nil to match everything.
+func toFilter(f *taskapiv1.TaskFilter) (events.Filter, error) {
+	if f == nil {
+		return events.Filter{}, nil
+	}
+	filter := events.Filter{Done: f.Done, Project: f.Project}
+	if f.Priority != nil {
+		p := model.Priority(*f.Priority)
+		if !model.ValidatePriority(p) {
+			return filter, httpError(http.StatusBadRequest, "invalid priority filter")
+		}
+		filter.Priority = &p
+	}
+	return filter, nil
+}
+
+func toModel(t *taskapiv1.Task) model.Task {
+	return model.Task{
+		ID:          t.GetId(),
+		Title:       t.GetTitle(),
+		Description: t.GetDescription(),
+		Done:        t.GetDone(),
+		Priority:    model.Priority(t.GetPriority()),
+		Project:     t.GetProject(),
+		Due:         t.GetDue(),
+		CreatedAt:   t.GetCreatedAt(),
+		UpdatedAt:   t.GetUpdatedAt(),
+	}
+}
+
+func fromModel(t model.Task) *taskapiv1.Task {
+	return &taskapiv1.Task{
+		Id:          t.ID,
+		Title:       t.Title,
+		Description: t.Description,
+		Done:        t.Done,
+		Priority:    taskapiv1.Priority(t.Priority),
+		Project:     t.Project,
+		Due:         t.Due,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+	}
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+// MaxBatchSize caps the number of operations in one batch request.
+const MaxBatchSize = 10000
+
+type batchOperation struct {
+	Op   store.OpKind `json:"op"`
+	ID   string       `json:"id"`
+	Task *model.Task  `json:"task"`
+}
+
+type batchRequest struct {
+	Atomic     bool             `json:"atomic"`
+	Operations []batchOperation `json:"operations"`
+}
+
+type batchResult struct {
+	Index  int            `json:"index"`
+	Op     store.OpKind   `json:"op"`
+	Status int            `json:"status"`
+	Task   *model.Task    `json:"task,omitempty"`
+	Error  *errorResponse `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Atomic    bool          `json:"atomic"`
+	Committed bool          `json:"committed"`
+	Results   []batchResult `json:"results"`
+}
+
+// BatchTasks applies a list of create, update and delete operations and
+// answers 207 Multi-Status with one result per operation. Atomic batches
+// are all-or-nothing: if any operation fails, nothing is applied and the
+// operations that would have succeeded report 424 Failed Dependency. Every
+// create counts against the daily create quota, and a batch whose creates
+// do not all fit is refused with 429; a rolled back batch gives them back.
+func BatchTasks(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if len(req.Operations) == 0 {
+		writeError(w, r, http.StatusBadRequest, "operations are required")
+		return
+	}
+	if len(req.Operations) > MaxBatchSize {
+		writeError(w, r, http.StatusBadRequest, fmt.Sprintf("at most %d operations are allowed per batch", MaxBatchSize))
+		return
+	}
+
+	results := make([]batchResult, len(req.Operations))
+	ops := make([]store.Op, 0, len(req.Operations))
+	indexes := make([]int, 0, len(req.Operations))
+	invalid := false
+	for i, op := range req.Operations {
+		results[i] = batchResult{Index: i, Op: op.Op}
+		if err := validateBatchOperation(op); err != nil {
+			problem := validationProblem(err, fmt.Sprintf("/operations/%d", i))
+			results[i].Status = http.StatusBadRequest
+			results[i].Error = &problem
+			invalid = true
+			continue
+		}
+		var t model.Task
+		if op.Task != nil {
+			t = *op.Task
+		}
+		ops = append(ops, store.Op{Kind: op.Op, ID: op.ID, Task: t})
+		indexes = append(indexes, i)
+	}
+
+	resp := batchResponse{Atomic: req.Atomic, Results: results}
+	if req.Atomic && invalid {
+		failDependents(results)
+		writeResponse(w, r, http.StatusMultiStatus, resp)
+		return
+	}
+
+	if !chargeCreates(w, r, countCreates(ops)) {
+		return
+	}
+	applied, committed := store.ApplyContext(r.Context(), ops, req.Atomic)
+	if !committed {
+		refundCreates(r.Context(), countCreates(ops))
+	}
+	for j, res := range applied {
+		result := &results[indexes[j]]
+		if !res.OK {
+			result.Status = http.StatusNotFound
+			result.Error = batchError(http.StatusNotFound, "task not found")
+			continue
+		}
+		switch result.Op {
+		case store.OpCreate:
+			result.Status = http.StatusCreated
+		case store.OpUpdate:
+			result.Status = http.StatusOK
+		case store.OpDelete:
+			result.Status = http.StatusNoContent
+			continue
+		}
+		task := res.Task
+		result.Task = &task
+	}
+	if !committed {
+		failDependents(results)
+	}
+	resp.Committed = committed
+	slog.InfoContext(r.Context(), "task batch applied", "operations", len(req.Operations), "atomic", req.Atomic, "committed", committed)
+	writeResponse(w, r, http.StatusMultiStatus, resp)
+}
+
+func validateBatchOperation(op batchOperation) error {
+	switch op.Op {
+	case store.OpCreate:
+	case store.OpUpdate, store.OpDelete:
+		if op.ID == "" {
+			return validationErrors{{Pointer: "#/id", Code: "required", Detail: fmt.Sprintf("id is required for %s", op.Op)}}
+		}
+	default:
+		return validationErrors{{Pointer: "#/op", Code: "invalid_value", Detail: "op must be create, update or delete"}}
+	}
+	if op.Op == store.OpDelete {
+		return nil
+	}
+	if op.Task == nil {
+		return validationErrors{{Pointer: "#/task", Code: "required", Detail: fmt.Sprintf("task is required for %s", op.Op)}}
+	}
+	var verrs validationErrors
+	if errors.As(validateTask(*op.Task), &verrs) {
+		return verrs.within("/task")
+	}
+	return nil
+}
+
+// failDependents marks every operation of a rolled back batch that did not
+// fail on its own as 424 Failed Dependency.
+func failDependents(results []batchResult) {
+	for i := range results {
+		if results[i].Error != nil {
+			continue
+		}
+		results[i].Status = http.StatusFailedDependency
+		results[i].Task = nil
+		results[i].Error = batchError(http.StatusFailedDependency, "batch was not applied")
+	}
+}
+
+func batchError(status int, message string) *errorResponse {
+	p := newProblem(status, message)
+	return &p
+}
+
+func countCreates(ops []store.Op) int {
+	n := 0
+	for _, op := range ops {
+		if op.Kind == store.OpCreate {
+			n++
+		}
+	}
+	return n
+}
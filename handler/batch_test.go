@@ -0,0 +1,119 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+type batchResponse struct {
+	Committed bool `json:"committed"`
+	Results   []struct {
+		Index  int         `json:"index"`
+		Status int         `json:"status"`
+		Task   *model.Task `json:"task"`
+	} `json:"results"`
+}
+
+func postBatch(t *testing.T, body string) batchResponse {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/tasks:batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	setupMux().ServeHTTP(w, req)
+
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("expected 207, got %d", w.Code)
+	}
+	var resp batchResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	return resp
+}
+
+func statuses(resp batchResponse) []int {
+	out := make([]int, len(resp.Results))
+	for i, r := range resp.Results {
+		out[i] = r.Status
+	}
+	return out
+}
+
+func TestBatchBestEffort(t *testing.T) {
+	store.Clear()
+	existing := store.Add(model.NewTask("Existing", "desc", model.PriorityLow))
+
+	resp := postBatch(t, `{"operations":[
+		{"op":"create","task":{"title":"New","priority":1}},
+		{"op":"create","task":{"title":""}},
+		{"op":"update","id":"`+existing.ID+`","task":{"title":"Renamed","priority":2}},
+		{"op":"delete","id":"999"}
+	]}`)
+
+	want := []int{http.StatusCreated, http.StatusBadRequest, http.StatusOK, http.StatusNotFound}
+	for i, got := range statuses(resp) {
+		if got != want[i] {
+			t.Fatalf("expected statuses %v, got %v", want, statuses(resp))
+		}
+	}
+	if !resp.Committed {
+		t.Fatal("expected best-effort batch to commit")
+	}
+	if store.Count() != 2 {
+		t.Fatalf("expected 2 tasks, got %d", store.Count())
+	}
+	if got, _ := store.Get(existing.ID); got.Title != "Renamed" {
+		t.Fatalf("expected update to apply, got %q", got.Title)
+	}
+}
+
+func TestBatchAtomicRollsBack(t *testing.T) {
+	store.Clear()
+	existing := store.Add(model.NewTask("Existing", "desc", model.PriorityLow))
+
+	resp := postBatch(t, `{"atomic":true,"operations":[
+		{"op":"create","task":{"title":"New"}},
+		{"op":"delete","id":"`+existing.ID+`"},
+		{"op":"update","id":"999","task":{"title":"Ghost"}}
+	]}`)
+
+	want := []int{http.StatusFailedDependency, http.StatusFailedDependency, http.StatusNotFound}
+	for i, got := range statuses(resp) {
+		if got != want[i] {
+			t.Fatalf("expected statuses %v, got %v", want, statuses(resp))
+		}
+	}
+	if resp.Committed {
+		t.Fatal("expected atomic batch not to commit")
+	}
+	if store.Count() != 1 {
+		t.Fatalf("expected store to be unchanged, got %d tasks", store.Count())
+	}
+	if _, ok := store.Get(existing.ID); !ok {
+		t.Fatal("expected delete to be rolled back")
+	}
+
+	created := store.Add(model.NewTask("After", "desc", model.PriorityLow))
+	if created.ID != "2" {
+		t.Fatalf("expected IDs from the rolled back batch to be reused, got %s", created.ID)
+	}
+}
+
+func TestBatchAtomicValidation(t *testing.T) {
+	store.Clear()
+
+	resp := postBatch(t, `{"atomic":true,"operations":[
+		{"op":"create","task":{"title":"Fine"}},
+		{"op":"create","task":{"title":"Bad","priority":9}}
+	]}`)
+
+	if statuses(resp)[0] != http.StatusFailedDependency || statuses(resp)[1] != http.StatusBadRequest {
+		t.Fatalf("unexpected statuses %v", statuses(resp))
+	}
+	if store.Count() != 0 {
+		t.Fatalf("expected no tasks, got %d", store.Count())
+	}
+}
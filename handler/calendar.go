@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// calendarSecret signs calendar subscription tokens. Calendar clients cannot
+// send an Authorization header, so the feed URL itself carries the token.
+var calendarSecret = randomCalendarSecret()
+
+func randomCalendarSecret() []byte {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// SetCalendarSecret replaces the key used to sign calendar subscription
+// tokens. Without it a random key is used and every subscription URL stops
+// working when the server restarts; with it, URLs survive restarts as long
+// as the subscriptions are persisted with LoadCalendarSubscriptions.
+// Changing the key revokes all existing subscriptions.
+func SetCalendarSecret(secret string) {
+	calendarSecret = []byte(secret)
+}
+
+func calendarToken(id string) string {
+	mac := hmac.New(sha256.New, calendarSecret)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// calendarSubscription is an issued feed URL. Deleting it revokes the URL.
+// IDs are random, so a revoked ID, and the token signed for it, is never
+// issued again.
+type calendarSubscription struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Owner     string `json:"owner,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+var (
+	calendarMu   sync.Mutex
+	calendarPath string
+	calendarSubs = map[string]calendarSubscription{}
+)
+
+func newCalendarID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// LoadCalendarSubscriptions restores calendar subscriptions from the file at
+// p and persists every later change there. An empty p keeps them in memory
+// only.
+func LoadCalendarSubscriptions(p string) error {
+	calendarMu.Lock()
+	defer calendarMu.Unlock()
+	calendarPath = p
+	calendarSubs = map[string]calendarSubscription{}
+	if p == "" {
+		return nil
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &calendarSubs); err != nil {
+		return fmt.Errorf("reading calendar subscriptions %s: %w", p, err)
+	}
+	return nil
+}
+
+// saveCalendarSubscriptions writes the subscriptions atomically. Callers
+// must hold calendarMu.
+func saveCalendarSubscriptions() error {
+	if calendarPath == "" {
+		return nil
+	}
+	data, err := json.Marshal(calendarSubs)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(calendarPath), ".calendar-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), calendarPath)
+}
+
+type calendarSubscriptionRequest struct {
+	Name string `json:"name"`
+}
+
+type calendarSubscriptionResponse struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Owner     string `json:"owner,omitempty"`
+	CreatedAt string `json:"created_at"`
+	Token     string `json:"token"`
+	URL       string `json:"url"`
+}
+
+// CreateCalendarSubscription issues a feed URL for a named subscriber, such
+// as a person or a shared calendar. When the caller presented a client
+// certificate, only that user can list and revoke the subscription.
+func CreateCalendarSubscription(w http.ResponseWriter, r *http.Request) {
+	var req calendarSubscriptionRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Name == "" {
+		writeError(w, r, http.StatusBadRequest, "name is required")
+		return
+	}
+	sub := calendarSubscription{
+		ID:        newCalendarID(),
+		Name:      req.Name,
+		Owner:     User(r.Context()),
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	calendarMu.Lock()
+	calendarSubs[sub.ID] = sub
+	err := saveCalendarSubscriptions()
+	if err != nil {
+		delete(calendarSubs, sub.ID)
+	}
+	calendarMu.Unlock()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to save calendar subscriptions", "error", err)
+		writeError(w, r, http.StatusInternalServerError, "failed to save the subscription")
+		return
+	}
+
+	token := calendarToken(sub.ID)
+	q := url.Values{"subscription": {sub.ID}, "token": {token}}
+	slog.InfoContext(r.Context(), "calendar subscription created", "id", sub.ID, "name", sub.Name, "owner", sub.Owner)
+	writeResponse(w, r, http.StatusCreated, calendarSubscriptionResponse{
+		ID:        sub.ID,
+		Name:      sub.Name,
+		Owner:     sub.Owner,
+		CreatedAt: sub.CreatedAt,
+		Token:     token,
+		URL:       "/tasks.ics?" + q.Encode(),
+	})
+}
+
+// ListCalendarSubscriptions lists the subscriptions the caller owns, oldest
+// first.
+func ListCalendarSubscriptions(w http.ResponseWriter, r *http.Request) {
+	owner := User(r.Context())
+	calendarMu.Lock()
+	subs := make([]calendarSubscription, 0)
+	for sub := range maps.Values(calendarSubs) {
+		if sub.Owner == owner {
+			subs = append(subs, sub)
+		}
+	}
+	calendarMu.Unlock()
+	slices.SortFunc(subs, func(a, b calendarSubscription) int {
+		return cmp.Or(cmp.Compare(a.CreatedAt, b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	writeResponse(w, r, http.StatusOK, subs)
+}
+
+// DeleteCalendarSubscription revokes one of the caller's subscriptions.
+func DeleteCalendarSubscription(w http.ResponseWriter, r *http.Request) {
+	owner := User(r.Context())
+	id := r.PathValue("id")
+	calendarMu.Lock()
+	sub, ok := calendarSubs[id]
+	ok = ok && sub.Owner == owner
+	var err error
+	if ok {
+		delete(calendarSubs, id)
+		if err = saveCalendarSubscriptions(); err != nil {
+			calendarSubs[id] = sub
+		}
+	}
+	calendarMu.Unlock()
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "calendar subscription not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to save calendar subscriptions", "error", err)
+		writeError(w, r, http.StatusInternalServerError, "failed to revoke the subscription")
+		return
+	}
+	slog.InfoContext(r.Context(), "calendar subscription revoked", "id", id, "name", sub.Name, "owner", owner)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CalendarFeed serves tasks as an iCalendar feed of VTODOs to clients
+// holding the token of a subscription that has not been revoked. It accepts
+// the same filters as ListTasks.
+func CalendarFeed(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("subscription")
+	token := r.URL.Query().Get("token")
+	calendarMu.Lock()
+	_, ok := calendarSubs[id]
+	calendarMu.Unlock()
+	if !ok || !hmac.Equal([]byte(token), []byte(calendarToken(id))) {
+		writeError(w, r, http.StatusUnauthorized, "invalid calendar subscription token")
+		return
+	}
+	tasks, err := filterTasks(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeICS(w, r, tasks)
+}
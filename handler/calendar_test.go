@@ -0,0 +1,178 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/handler"
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+func TestCalendarFeedRequiresToken(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks.ics?subscription=1&token=forged", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+}
+
+func createSubscription(t *testing.T, mux http.Handler, req *http.Request) (id, feed string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var sub struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}
+	json.NewDecoder(w.Body).Decode(&sub)
+	return sub.ID, sub.URL
+}
+
+func TestCalendarFeed(t *testing.T) {
+	store.Clear()
+	if err := handler.LoadCalendarSubscriptions(""); err != nil {
+		t.Fatal(err)
+	}
+	mux := setupMux()
+
+	task := model.NewTask("Due soon", "desc", model.PriorityHigh)
+	task.Due = "2024-03-01"
+	created := store.Add(task)
+
+	id, feed := createSubscription(t, mux, httptest.NewRequest(http.MethodPost, "/calendar/subscriptions", strings.NewReader(`{"name":"team"}`)))
+
+	req := httptest.NewRequest(http.MethodGet, feed, nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+		t.Fatalf("expected text/calendar, got %q", ct)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"BEGIN:VTODO", "UID:" + created.ID, "PRIORITY:1", "DUE;VALUE=DATE:20240301", "STATUS:NEEDS-ACTION"} {
+		if !strings.Contains(body, want+"\r\n") {
+			t.Fatalf("expected feed to contain %q, got:\n%s", want, body)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/calendar/subscriptions/"+id, nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, feed, nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the revoked feed to be refused, got %d", w.Code)
+	}
+
+	// A new subscription never gets the revoked ID, or its token, back.
+	if newID, _ := createSubscription(t, mux, httptest.NewRequest(http.MethodPost, "/calendar/subscriptions", strings.NewReader(`{"name":"team"}`))); newID == id {
+		t.Fatalf("expected a fresh ID, got the revoked %s again", id)
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, feed, nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the revoked feed to stay refused, got %d", w.Code)
+	}
+}
+
+func TestCalendarSubscriptionOwners(t *testing.T) {
+	if err := handler.LoadCalendarSubscriptions(""); err != nil {
+		t.Fatal(err)
+	}
+	mux := handler.ClientCertAuth(nil, setupMux())
+	req := httptest.NewRequest(http.MethodPost, "/calendar/subscriptions", strings.NewReader(`{"name":"team"}`))
+	id, _ := createSubscription(t, mux, withClientCert(req, "alice@example.com"))
+
+	// Only the owner sees and revokes a subscription.
+	for _, user := range []string{"bob@example.com", ""} {
+		req = httptest.NewRequest(http.MethodDelete, "/calendar/subscriptions/"+id, nil)
+		if user != "" {
+			req = withClientCert(req, user)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected %q not to see the subscription, got %d", user, w.Code)
+		}
+	}
+	req = httptest.NewRequest(http.MethodDelete, "/calendar/subscriptions/"+id, nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, withClientCert(req, "alice@example.com"))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+}
+
+func TestCalendarSubscriptionsSurviveRestart(t *testing.T) {
+	t.Cleanup(func() { handler.LoadCalendarSubscriptions("") })
+	path := filepath.Join(t.TempDir(), "calendar.json")
+	if err := handler.LoadCalendarSubscriptions(path); err != nil {
+		t.Fatal(err)
+	}
+	mux := setupMux()
+	_, feed := createSubscription(t, mux, httptest.NewRequest(http.MethodPost, "/calendar/subscriptions", strings.NewReader(`{"name":"team"}`)))
+
+	if err := handler.LoadCalendarSubscriptions(path); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, feed, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the feed to work after reloading, got %d", w.Code)
+	}
+}
+
+func TestImportCalendarByUID(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+
+	existing := store.Add(model.NewTask("Old", "desc", model.PriorityLow))
+	body := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VTODO\r\nUID:" + existing.ID + "\r\nSUMMARY:Renamed\r\nSTATUS:COMPLETED\r\nEND:VTODO\r\n" +
+		"BEGIN:VTODO\r\nUID:new@example.com\r\nSUMMARY:New\r\nDUE:20240301T100000Z\r\nEND:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+
+	req := httptest.NewRequest(http.MethodPost, "/tasks/import?format=ics", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var resp importResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || resp.Created != 1 || resp.Updated != 1 || len(resp.Errors) != 0 {
+		t.Fatalf("unexpected import report: %d %+v", w.Code, resp)
+	}
+	if got, _ := store.Get(existing.ID); got.Title != "Renamed" || !got.Done {
+		t.Fatalf("expected task %s to be updated, got %+v", existing.ID, got)
+	}
+
+	// Importing the same calendar again matches foreign UIDs to the tasks
+	// created for them.
+	req = httptest.NewRequest(http.MethodPost, "/tasks/import?format=ics", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	resp = importResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Created != 0 || resp.Updated != 2 || store.Count() != 2 {
+		t.Fatalf("expected the import to update both tasks, got %+v with %d tasks", resp, store.Count())
+	}
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sawez-deepsource/demo-go/certs"
+)
+
+type userKey struct{}
+
+// User returns the user that ClientCertAuth mapped the request's client
+// certificate to, or "" for requests without one.
+func User(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+// ClientCertAuth maps the verified client certificate of each request to a
+// user, available to later handlers through User. With users nil the
+// certificate identity (see certs.Identity) is the user name; otherwise
+// certificates whose identity is not in users are refused with 403.
+// Requests without a certificate pass through: whether they may connect at
+// all is up to the TLS client auth mode.
+func ClientCertAuth(users map[string]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		identity := certs.Identity(r.TLS.VerifiedChains[0][0])
+		user := identity
+		if users != nil {
+			var ok bool
+			if user, ok = users[identity]; !ok {
+				writeError(w, r, http.StatusForbidden, "client certificate "+identity+" is not mapped to a user")
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+	})
+}
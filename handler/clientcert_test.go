@@ -0,0 +1,51 @@
+package handler_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/handler"
+)
+
+func withClientCert(r *http.Request, email string) *http.Request {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client"}, EmailAddresses: []string{email}}
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	return r
+}
+
+func TestClientCertAuth(t *testing.T) {
+	whoami := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, handler.User(r.Context()))
+	})
+	h := handler.ClientCertAuth(map[string]string{"alice@example.com": "alice"}, whoami)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, withClientCert(httptest.NewRequest(http.MethodGet, "/tasks", nil), "alice@example.com"))
+	if w.Code != http.StatusOK || w.Body.String() != "alice" {
+		t.Errorf("expected alice, got %d %q", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, withClientCert(httptest.NewRequest(http.MethodGet, "/tasks", nil), "mallory@example.com"))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for an unmapped certificate, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "" {
+		t.Errorf("expected a request without a certificate to pass, got %d %q", w.Code, w.Body)
+	}
+
+	// Without a users map the identity is the user.
+	w = httptest.NewRecorder()
+	handler.ClientCertAuth(nil, whoami).ServeHTTP(w, withClientCert(httptest.NewRequest(http.MethodGet, "/tasks", nil), "bob@example.com"))
+	if w.Body.String() != "bob@example.com" {
+		t.Errorf("expected the identity as user, got %q", w.Body)
+	}
+}
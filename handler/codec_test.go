@@ -0,0 +1,128 @@
+package handler_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+func TestNegotiatesResponseFormat(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+	store.Add(model.NewTask("Negotiated", "desc", model.PriorityLow))
+
+	tests := []struct {
+		accept      string
+		contentType string
+		contains    string
+	}{
+		{"application/yaml", "application/yaml", "title: Negotiated"},
+		{"text/csv", "text/csv", "id,title,description"},
+		{"", "application/json", `"title":"Negotiated"`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+		req.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", tt.accept, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != tt.contentType {
+			t.Fatalf("%s: expected Content-Type %s, got %s", tt.accept, tt.contentType, ct)
+		}
+		if !strings.Contains(w.Body.String(), tt.contains) {
+			t.Fatalf("%s: expected body to contain %q, got %q", tt.accept, tt.contains, w.Body.String())
+		}
+	}
+}
+
+func TestDecodesRequestByContentType(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+
+	body, _ := msgpack.Marshal(map[string]any{"title": "Packed", "priority": 2})
+	req := httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/msgpack")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if tasks := store.FilterByPriority(model.PriorityHigh); len(tasks) != 1 || tasks[0].Title != "Packed" {
+		t.Fatalf("expected msgpack task to be stored, got %+v", tasks)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader("title: From YAML\npriority: 1\n"))
+	req.Header.Set("Content-Type", "application/yaml")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUnsupportedMediaTypes(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	req.Header.Set("Accept", "image/png")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotAcceptable {
+		t.Fatalf("expected 406, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader("<task/>"))
+	req.Header.Set("Content-Type", "application/xml")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader("url\nhttps://example.com\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415 for a webhook sent as csv, got %d", w.Code)
+	}
+}
+
+func TestLimitsBodiesInEveryFormat(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+	big := strings.Repeat("a", 11<<20)
+	packed, _ := msgpack.Marshal(map[string]any{"title": big})
+
+	bodies := map[string]string{
+		"application/json":    `{"title":"` + big + `"}`,
+		"application/yaml":    "title: " + big + "\n",
+		"application/msgpack": string(packed),
+		"text/csv":            "title\n" + big + "\n",
+	}
+	for contentType, body := range bodies {
+		req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: expected 413, got %d", contentType, w.Code)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/events"
+	"github.com/sawez-deepsource/demo-go/model"
+)
+
+// HeartbeatInterval is how often an idle event stream sends a comment line to
+// keep proxies from closing the connection.
+var HeartbeatInterval = 15 * time.Second
+
+// ShutdownRetry is the reconnection delay suggested to event stream clients
+// when the server shuts down, giving a replacement time to come up.
+var ShutdownRetry = time.Second
+
+var (
+	streamsMu sync.Mutex
+	// streams holds a stop channel for each open event stream, mapped to
+	// whether it has been closed.
+	streams = map[chan struct{}]bool{}
+)
+
+// StreamEvents serves task changes as Server-Sent Events. Clients may filter
+// by done, priority and project, and resume after a reconnect by sending the
+// Last-Event-ID header. Tasks an update takes out of the filter are sent as
+// removed events. If the events after Last-Event-ID are no longer buffered,
+// the stream starts with a reset event instead, telling the client to load
+// the tasks again.
+func StreamEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Without a Last-Event-ID only changes from now on are sent.
+	after := events.LastID()
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		after, err = strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid Last-Event-ID")
+			return
+		}
+	}
+
+	backlog, sub := events.Subscribe(after)
+	defer events.Cancel(sub)
+
+	rc, stop, ok := startStream(w, r)
+	if !ok {
+		return
+	}
+	defer endStream(stop)
+
+	if sub.Missed {
+		fmt.Fprintf(w, "id: %d\nevent: reset\ndata: {}\n\n", sub.Since)
+		backlog = nil
+	}
+	for _, e := range backlog {
+		if e, ok := filter.Select(e); ok {
+			writeEvent(w, e)
+		}
+	}
+	rc.Flush()
+
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-stop:
+			fmt.Fprintf(w, "retry: %d\nevent: shutdown\ndata: {}\n\n", ShutdownRetry.Milliseconds())
+			rc.Flush()
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if e, ok := filter.Select(e); ok {
+				writeEvent(w, e)
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// startStream sends the headers of an event stream that outlives the
+// server's write timeout, and registers it with ShutdownEventStreams, which
+// closes the returned channel. Callers must call endStream when the stream
+// ends. If w cannot stream, startStream answers 500 and returns false.
+func startStream(w http.ResponseWriter, r *http.Request) (*http.ResponseController, chan struct{}, bool) {
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+		writeError(w, r, http.StatusInternalServerError, "streaming unsupported")
+		return nil, nil, false
+	}
+
+	stop := make(chan struct{})
+	streamsMu.Lock()
+	streams[stop] = false
+	streamsMu.Unlock()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	return rc, stop, true
+}
+
+func endStream(stop chan struct{}) {
+	streamsMu.Lock()
+	delete(streams, stop)
+	streamsMu.Unlock()
+}
+
+// ShutdownEventStreams ends every open event stream, sending GET /events
+// clients a shutdown event and GraphQL clients a complete event, so that
+// http.Server.Shutdown does not wait for streams that would never finish.
+// It returns once no streams are left, or with an error counting those
+// still open when ctx is done.
+func ShutdownEventStreams(ctx context.Context) error {
+	return untilClosed(ctx, "event streams", func() int {
+		streamsMu.Lock()
+		defer streamsMu.Unlock()
+		for stop, stopped := range streams {
+			if !stopped {
+				close(stop)
+				streams[stop] = true
+			}
+		}
+		return len(streams)
+	})
+}
+
+// shutdownPollInterval is how often untilClosed checks for connections that
+// are still open.
+const shutdownPollInterval = 50 * time.Millisecond
+
+// untilClosed calls closeAll, which asks the open connections to close and
+// returns how many remain, until none remain or ctx is done. Connections
+// opened while waiting are asked in turn.
+func untilClosed(ctx context.Context, what string, closeAll func() int) error {
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		n := closeAll()
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d %s still open: %w", n, what, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e events.Event) {
+	data, err := json.Marshal(e.Task)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
+
+func parseEventFilter(r *http.Request) (events.Filter, error) {
+	var f events.Filter
+	q := r.URL.Query()
+	if v := q.Get("done"); v != "" {
+		done, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, errors.New("invalid done filter")
+		}
+		f.Done = &done
+	}
+	if v := q.Get("priority"); v != "" {
+		p, err := strconv.Atoi(v)
+		if err != nil || !model.ValidatePriority(model.Priority(p)) {
+			return f, errors.New("invalid priority filter")
+		}
+		priority := model.Priority(p)
+		f.Priority = &priority
+	}
+	f.Project = q.Get("project")
+	return f, nil
+}
@@ -0,0 +1,167 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/events"
+	"github.com/sawez-deepsource/demo-go/handler"
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+// readEvent reads lines up to the next blank line and returns the event
+// fields, skipping heartbeat comments.
+func readEvent(t *testing.T, r *bufio.Reader) map[string]string {
+	t.Helper()
+	fields := map[string]string{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if len(fields) > 0 {
+				return fields
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		k, v, _ := strings.Cut(line, ": ")
+		fields[k] = v
+	}
+}
+
+func openStream(t *testing.T, url string, lastEventID uint64) *bufio.Reader {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if lastEventID > 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(lastEventID, 10))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("opening event stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+func TestStreamEventsFiltersChanges(t *testing.T) {
+	store.Clear()
+	srv := httptest.NewServer(setupMux())
+	t.Cleanup(srv.Close)
+
+	stream := openStream(t, srv.URL+"/events?priority=2", 0)
+
+	store.Add(model.NewTask("Low", "desc", model.PriorityLow))
+	high := store.Add(model.NewTask("High", "desc", model.PriorityHigh))
+	store.Delete(high.ID)
+
+	e := readEvent(t, stream)
+	if e["event"] != "created" || !strings.Contains(e["data"], `"title":"High"`) {
+		t.Fatalf("expected created event for high task, got %v", e)
+	}
+	e = readEvent(t, stream)
+	if e["event"] != "deleted" || !strings.Contains(e["data"], `"id":"`+high.ID+`"`) {
+		t.Fatalf("expected deleted event for high task, got %v", e)
+	}
+}
+
+func TestStreamEventsResumesFromLastEventID(t *testing.T) {
+	store.Clear()
+	srv := httptest.NewServer(setupMux())
+	t.Cleanup(srv.Close)
+
+	store.Add(model.NewTask("Seen", "desc", model.PriorityLow))
+	seen := events.LastID()
+	store.Add(model.NewTask("Missed", "desc", model.PriorityLow))
+
+	stream := openStream(t, srv.URL+"/events", seen)
+
+	e := readEvent(t, stream)
+	if e["id"] != strconv.FormatUint(seen+1, 10) || !strings.Contains(e["data"], "Missed") {
+		t.Fatalf("expected replay of missed event, got %v", e)
+	}
+}
+
+func TestStreamEventsRemovesTasksLeavingFilter(t *testing.T) {
+	store.Clear()
+	srv := httptest.NewServer(setupMux())
+	t.Cleanup(srv.Close)
+
+	stream := openStream(t, srv.URL+"/events?priority=2", 0)
+
+	high := store.Add(model.NewTask("High", "desc", model.PriorityHigh))
+	high.Priority = model.PriorityLow
+	store.Update(high.ID, high)
+	store.Delete(high.ID)
+	store.Add(model.NewTask("Next", "desc", model.PriorityHigh))
+
+	for _, want := range []string{"created", "removed", "created"} {
+		if e := readEvent(t, stream); e["event"] != want {
+			t.Fatalf("expected %s event, got %v", want, e)
+		}
+	}
+}
+
+func TestStreamEventsResetsUnknownLastEventID(t *testing.T) {
+	store.Clear()
+	srv := httptest.NewServer(setupMux())
+	t.Cleanup(srv.Close)
+
+	// An ID the server has not reached is from before a restart.
+	stream := openStream(t, srv.URL+"/events", events.LastID()+100)
+	e := readEvent(t, stream)
+	if e["event"] != "reset" || e["id"] != strconv.FormatUint(events.LastID(), 10) {
+		t.Fatalf("expected a reset event, got %v", e)
+	}
+}
+
+func TestStreamEventsInvalidFilter(t *testing.T) {
+	mux := setupMux()
+
+	req := httptest.NewRequest(http.MethodGet, "/events?done=maybe", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestShutdownEventStreams(t *testing.T) {
+	srv := httptest.NewServer(setupMux())
+	t.Cleanup(srv.Close)
+	stream := openStream(t, srv.URL+"/events", 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := handler.ShutdownEventStreams(ctx); err != nil {
+		t.Fatal(err)
+	}
+	e := readEvent(t, stream)
+	if e["event"] != "shutdown" || e["retry"] != "1000" {
+		t.Fatalf("expected a shutdown event, got %v", e)
+	}
+	if _, err := stream.ReadString('\n'); err != io.EOF {
+		t.Fatalf("expected the stream to end, got %v", err)
+	}
+}
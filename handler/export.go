@@ -0,0 +1,317 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/ical"
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/plaintext"
+	"github.com/sawez-deepsource/demo-go/store"
+	"github.com/sawez-deepsource/demo-go/taskcsv"
+)
+
+// maxImportSize caps the size of an uploaded import file.
+const maxImportSize = 10 << 20
+
+// fileFormats are the values accepted by the format query parameter of
+// ExportTasks and ImportTasks.
+var fileFormats = []string{"csv", "ics", "todotxt", "markdown"}
+
+const fileFormatError = "format must be csv, ics, todotxt or markdown"
+
+type importError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+type importResponse struct {
+	DryRun  bool          `json:"dry_run"`
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Errors  []importError `json:"errors"`
+}
+
+// importRow is a decoded task together with its position in the uploaded
+// file and any problems found while decoding it. UID is set for VTODOs.
+// Fields lists the task fields, by JSON name, that the file carries; when
+// the row updates a task only those change. Nil means every field.
+type importRow struct {
+	Row    int
+	Task   model.Task
+	UID    string
+	Fields []string
+	Errors []importError
+}
+
+// Fields carried by the plain text formats.
+var (
+	todoTxtFields  = []string{"title", "done", "priority", "project", "due"}
+	markdownFields = []string{"title", "description", "done", "project"}
+)
+
+// mergeFields returns existing with the listed fields taken from t.
+func mergeFields(existing, t model.Task, fields []string) model.Task {
+	if fields == nil {
+		return t
+	}
+	for _, f := range fields {
+		switch f {
+		case "title":
+			existing.Title = t.Title
+		case "description":
+			existing.Description = t.Description
+		case "done":
+			existing.Done = t.Done
+		case "priority":
+			existing.Priority = t.Priority
+		case "project":
+			existing.Project = t.Project
+		case "due":
+			existing.Due = t.Due
+		}
+	}
+	return existing
+}
+
+var (
+	importedMu sync.Mutex
+	// importedUIDs maps the UIDs of imported VTODOs to the tasks created
+	// for them, so that importing a calendar again updates those tasks.
+	importedUIDs = map[string]string{}
+)
+
+// taskIDForUID returns the task an earlier import created for uid. Other
+// UIDs are taken as task IDs, as in the calendars ExportTasks writes.
+func taskIDForUID(uid string) string {
+	importedMu.Lock()
+	defer importedMu.Unlock()
+	if id, ok := importedUIDs[uid]; ok {
+		return id
+	}
+	return uid
+}
+
+// ExportTasks streams the tasks selected by the same filters as ListTasks
+// as a downloadable file in the requested format.
+func ExportTasks(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if !slices.Contains(fileFormats, format) {
+		writeError(w, r, http.StatusBadRequest, fileFormatError)
+		return
+	}
+	tasks, err := filterTasks(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Disposition", `attachment; filename="tasks.csv"`)
+		writeCSV(w, r, tasks)
+	case "ics":
+		w.Header().Set("Content-Disposition", `attachment; filename="tasks.ics"`)
+		writeICS(w, r, tasks)
+	case "todotxt":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="todo.txt"`)
+		w.WriteHeader(http.StatusOK)
+		if err := plaintext.EncodeTodoTxt(w, tasks); err != nil {
+			slog.ErrorContext(r.Context(), "failed to write export", "format", "todotxt", "error", err)
+		}
+	case "markdown":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="tasks.md"`)
+		w.WriteHeader(http.StatusOK)
+		if err := plaintext.EncodeMarkdown(w, tasks); err != nil {
+			slog.ErrorContext(r.Context(), "failed to write export", "format", "markdown", "error", err)
+		}
+	}
+}
+
+func writeCSV(w http.ResponseWriter, r *http.Request, tasks []model.Task) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	cw := taskcsv.NewWriter(w)
+	for _, t := range tasks {
+		if err := cw.Write(t); err != nil {
+			slog.ErrorContext(r.Context(), "failed to write export", "format", "csv", "error", err)
+			return
+		}
+	}
+	if err := cw.Flush(); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write export", "format", "csv", "error", err)
+	}
+}
+
+func writeICS(w http.ResponseWriter, r *http.Request, tasks []model.Task) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if err := ical.Encode(w, tasks, time.Now()); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write export", "format", "ics", "error", err)
+	}
+}
+
+// ImportTasks creates tasks from an uploaded file, or updates them when a
+// row carries the ID of an existing task (an id column, VTODO UID or todo.txt
+// id: tag). An update changes only the fields the format carries, or the
+// columns a CSV file has, so a re-imported todo.txt file keeps descriptions.
+// VTODOs from other calendars update the tasks created for their UID by an
+// earlier import. Rows that fail validation are reported and skipped; with
+// dry_run=true nothing is written. For CSV, the map query parameter renames
+// foreign headers, e.g. map=Summary:title,Urgency:priority.
+func ImportTasks(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	body := http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	var rows []importRow
+	switch format {
+	case "csv":
+		aliases, err := parseColumnMapping(r.URL.Query().Get("map"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		decoded, err := taskcsv.Decode(body, aliases)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, fmt.Sprintf("invalid csv: %v", err))
+			return
+		}
+		for _, d := range decoded {
+			row := importRow{Row: d.Line, Task: d.Task, Fields: d.Columns}
+			for _, fe := range d.Errors {
+				row.Errors = append(row.Errors, importError{Row: d.Line, Column: fe.Column, Message: fe.Message})
+			}
+			rows = append(rows, row)
+		}
+	case "ics":
+		decoded, err := ical.Decode(body)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, fmt.Sprintf("invalid calendar: %v", err))
+			return
+		}
+		for _, d := range decoded {
+			row := newImportRow(d.Line, d.Task, d.Errors)
+			row.UID = d.Task.ID
+			row.Task.ID = taskIDForUID(d.Task.ID)
+			rows = append(rows, row)
+		}
+	case "todotxt", "markdown":
+		decode, fields := plaintext.DecodeTodoTxt, todoTxtFields
+		if format == "markdown" {
+			decode, fields = plaintext.DecodeMarkdown, markdownFields
+		}
+		decoded, err := decode(body)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, fmt.Sprintf("invalid %s: %v", format, err))
+			return
+		}
+		for _, d := range decoded {
+			row := newImportRow(d.Line, d.Task, d.Errors)
+			row.Fields = fields
+			rows = append(rows, row)
+		}
+	default:
+		writeError(w, r, http.StatusBadRequest, fileFormatError)
+		return
+	}
+
+	importTasks(w, r, rows, r.URL.Query().Get("dry_run") == "true")
+}
+
+func newImportRow(line int, t model.Task, problems []string) importRow {
+	row := importRow{Row: line, Task: t}
+	for _, msg := range problems {
+		row.Errors = append(row.Errors, importError{Row: line, Message: msg})
+	}
+	return row
+}
+
+// importTasks validates rows and, unless dryRun is set, applies the valid
+// ones in a single best-effort batch, then answers with the report.
+func importTasks(w http.ResponseWriter, r *http.Request, rows []importRow, dryRun bool) {
+	ctx := r.Context()
+	resp := importResponse{DryRun: dryRun, Errors: []importError{}}
+	var ops []store.Op
+	var opRows []importRow
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			resp.Errors = append(resp.Errors, row.Errors...)
+			continue
+		}
+		op := store.Op{Kind: store.OpCreate, Task: row.Task}
+		if existing, ok := store.GetContext(ctx, row.Task.ID); ok && row.Task.ID != "" {
+			op = store.Op{Kind: store.OpUpdate, ID: row.Task.ID, Task: mergeFields(existing, row.Task, row.Fields)}
+		}
+		if err := validateTask(op.Task); err != nil {
+			resp.Errors = append(resp.Errors, importError{Row: row.Row, Message: err.Error()})
+			continue
+		}
+		ops = append(ops, op)
+		opRows = append(opRows, row)
+	}
+
+	if dryRun {
+		for _, op := range ops {
+			if op.Kind == store.OpCreate {
+				resp.Created++
+			} else {
+				resp.Updated++
+			}
+		}
+		writeResponse(w, r, http.StatusOK, resp)
+		return
+	}
+
+	if !chargeCreates(w, r, countCreates(ops)) {
+		return
+	}
+	results, _ := store.ApplyContext(ctx, ops, false)
+	for i, res := range results {
+		switch {
+		case !res.OK:
+			resp.Errors = append(resp.Errors, importError{Row: opRows[i].Row, Message: "task not found"})
+		case ops[i].Kind == store.OpCreate:
+			resp.Created++
+			if uid := opRows[i].UID; uid != "" {
+				importedMu.Lock()
+				importedUIDs[uid] = res.Task.ID
+				importedMu.Unlock()
+			}
+		default:
+			resp.Updated++
+		}
+	}
+	refundCreates(ctx, countCreates(ops)-resp.Created)
+	slog.InfoContext(ctx, "tasks imported", "created", resp.Created, "updated", resp.Updated, "errors", len(resp.Errors))
+	writeResponse(w, r, http.StatusOK, resp)
+}
+
+func parseColumnMapping(s string) (map[string]string, error) {
+	aliases := map[string]string{}
+	if s == "" {
+		return aliases, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		from, to, ok := strings.Cut(pair, ":")
+		if !ok || from == "" || to == "" {
+			return nil, errors.New("map must look like Header:column,Header:column")
+		}
+		aliases[from] = to
+	}
+	return aliases, nil
+}
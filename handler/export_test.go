@@ -0,0 +1,156 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+type importResponse struct {
+	DryRun  bool `json:"dry_run"`
+	Created int  `json:"created"`
+	Updated int  `json:"updated"`
+	Errors  []struct {
+		Row     int    `json:"row"`
+		Column  string `json:"column"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func TestExportTasksCSV(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+
+	store.Add(model.NewTask("Low", "desc", model.PriorityLow))
+	store.Add(model.NewTask("High", "desc", model.PriorityHigh))
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks/export?format=csv&priority=2", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Fatalf("expected text/csv, got %q", ct)
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "id,title,") || !strings.Contains(lines[1], "High") {
+		t.Fatalf("expected header and one high priority row, got %q", w.Body.String())
+	}
+}
+
+func TestImportTasksCSV(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+
+	existing := store.Add(model.NewTask("Old", "desc", model.PriorityLow))
+	body := "id,title,priority\n" +
+		existing.ID + ",Renamed,medium\n" +
+		",Fresh,2\n" +
+		",,low\n" +
+		",Bad,urgent\n"
+
+	req := httptest.NewRequest(http.MethodPost, "/tasks/import?dry_run=true", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var resp importResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || !resp.DryRun || resp.Created != 1 || resp.Updated != 1 || len(resp.Errors) != 2 {
+		t.Fatalf("unexpected dry run report: %d %+v", w.Code, resp)
+	}
+	if store.Count() != 1 {
+		t.Fatal("expected dry run to leave the store unchanged")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/tasks/import", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	resp = importResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Created != 1 || resp.Updated != 1 {
+		t.Fatalf("unexpected import report: %+v", resp)
+	}
+	if resp.Errors[0].Row != 4 || resp.Errors[1].Row != 5 || resp.Errors[1].Column != "priority" {
+		t.Fatalf("expected errors on rows 4 and 5, got %+v", resp.Errors)
+	}
+	// Columns missing from the file are left as they were.
+	if got, _ := store.Get(existing.ID); got.Title != "Renamed" || got.Priority != model.PriorityMedium || got.Description != "desc" {
+		t.Fatalf("expected existing task to be updated, got %+v", got)
+	}
+}
+
+func TestImportTasksRejectsBadHeader(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+
+	req := httptest.NewRequest(http.MethodPost, "/tasks/import", strings.NewReader("name\nx\n"))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestExportImportTodoTxt(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+
+	task := model.NewTask("Call Bob @phone", "About the venue", model.PriorityHigh)
+	task.Project = "launch"
+	created := store.Add(task)
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks/export?format=todotxt", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	line := strings.TrimSpace(w.Body.String())
+	if !strings.HasPrefix(line, "(A) ") || !strings.HasSuffix(line, "Call Bob @phone +launch id:"+created.ID) {
+		t.Fatalf("unexpected todo.txt line %q", line)
+	}
+
+	edited := "x " + strings.TrimPrefix(line, "(A) ") + " pri:A\n(B) New errand +home\n"
+	req = httptest.NewRequest(http.MethodPost, "/tasks/import?format=todotxt", strings.NewReader(edited))
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var resp importResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Created != 1 || resp.Updated != 1 || len(resp.Errors) != 0 {
+		t.Fatalf("unexpected import report: %+v", resp)
+	}
+	// Descriptions are not in todo.txt, so the update leaves them alone.
+	if got, _ := store.Get(created.ID); !got.Done || got.Title != "Call Bob @phone" || got.Description != "About the venue" {
+		t.Fatalf("expected task %s to be marked done and keep its description, got %+v", created.ID, got)
+	}
+}
+
+func TestImportMarkdownChecklist(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+
+	body := "## launch\n\n- [ ] Book venue\n- [x] Pick date\n- [ ]\n"
+	req := httptest.NewRequest(http.MethodPost, "/tasks/import?format=markdown", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var resp importResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Created != 2 || len(resp.Errors) != 1 || resp.Errors[0].Row != 5 {
+		t.Fatalf("unexpected import report: %+v", resp)
+	}
+	if len(store.FilterByProject("launch")) != 2 {
+		t.Fatal("expected imported tasks to belong to the launch project")
+	}
+}
@@ -0,0 +1,320 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/ast"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	qast "github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+
+	"github.com/sawez-deepsource/demo-go/codec"
+)
+
+// GraphQLConfig bounds the operations GraphQL runs. Depth counts nested
+// fields. Complexity counts a point per field, with the fields under a list
+// counted once per item it may hold: its first argument, or that of the
+// connection it belongs to.
+type GraphQLConfig struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+var DefaultGraphQLConfig = GraphQLConfig{
+	MaxDepth:      10,
+	MaxComplexity: 5000,
+}
+
+var graphQLLimits atomic.Pointer[GraphQLConfig]
+
+func init() {
+	SetGraphQLConfig(DefaultGraphQLConfig)
+}
+
+// SetGraphQLConfig changes the limits applied to operations received from
+// now on.
+func SetGraphQLConfig(cfg GraphQLConfig) {
+	graphQLLimits.Store(&cfg)
+}
+
+var graphQLSchema = graphql.MustParseSchema(graphQLSDL, &graphQLRoot{},
+	graphql.UseStringDescriptions(), graphql.UseFieldResolvers())
+
+// graphQLRequest is a GraphQL request, as a POST body or as GET parameters
+// with variables encoded as JSON.
+type graphQLRequest struct {
+	Query         string          `json:"query"`
+	OperationName *string         `json:"operationName"`
+	Variables     *map[string]any `json:"variables"`
+}
+
+// graphQLResponse is the result of an operation. Data is absent when the
+// operation could not run.
+type graphQLResponse struct {
+	Data   any                     `json:"data,omitempty"`
+	Errors []*gqlerrors.QueryError `json:"errors,omitempty"`
+}
+
+func newGraphQLResponse(res *graphql.Response) graphQLResponse {
+	out := graphQLResponse{Errors: res.Errors}
+	if len(res.Data) > 0 {
+		out.Data = res.Data
+	}
+	return out
+}
+
+// GraphQL runs the query, mutation or subscription in the request. Queries
+// may use GET or POST, mutations only POST. Results are JSON, or server-sent
+// events following the GraphQL over SSE protocol when the client accepts
+// text/event-stream, as subscriptions require.
+func GraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		if q.Has("operationName") {
+			name := q.Get("operationName")
+			req.OperationName = &name
+		}
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeError(w, r, http.StatusBadRequest, "variables must be a JSON object")
+				return
+			}
+		}
+	} else if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Query == "" {
+		writeError(w, r, http.StatusBadRequest, "query is required")
+		return
+	}
+	var name string
+	if req.OperationName != nil {
+		name = *req.OperationName
+	}
+	var vars map[string]any
+	if req.Variables != nil {
+		vars = *req.Variables
+	}
+
+	op, errs := checkGraphQL(req.Query, name, vars)
+	switch {
+	case errs != nil:
+		writeGraphQL(w, r, graphQLResponse{Errors: errs})
+	case op == qast.Mutation && r.Method != http.MethodPost:
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, r, http.StatusMethodNotAllowed, "mutations must be sent with POST")
+	case strings.Contains(r.Header.Get("Accept"), "text/event-stream"):
+		streamGraphQL(w, r, req.Query, name, vars)
+	case op == qast.Subscription:
+		writeError(w, r, http.StatusNotAcceptable, "subscriptions are only served as text/event-stream")
+	default:
+		writeGraphQL(w, r, newGraphQLResponse(graphQLSchema.Exec(r.Context(), req.Query, name, vars)))
+	}
+}
+
+func writeGraphQL(w http.ResponseWriter, r *http.Request, resp graphQLResponse) {
+	encode(w, r, codec.JSON{}, "application/json", http.StatusOK, resp)
+}
+
+// checkGraphQL validates the operation to run and measures it against the
+// limits, returning its type or the errors to answer with. graph-gophers
+// validates the operation; it keeps its query parser internal, so the
+// operation is measured on gqlparser's syntax tree, with field types looked
+// up in graph-gophers' schema.
+func checkGraphQL(query, name string, vars map[string]any) (qast.Operation, []*gqlerrors.QueryError) {
+	if errs := graphQLSchema.ValidateWithVariables(query, vars); len(errs) > 0 {
+		return "", errs
+	}
+	doc, err := parser.ParseQuery(&qast.Source{Name: "query.graphql", Input: query})
+	if err != nil {
+		return "", []*gqlerrors.QueryError{gqlerrors.Errorf("%s", err)}
+	}
+	op := doc.Operations.ForName(name)
+	if op == nil {
+		if name == "" {
+			return "", []*gqlerrors.QueryError{gqlerrors.Errorf("operationName is required to choose between operations")}
+		}
+		return "", []*gqlerrors.QueryError{gqlerrors.Errorf("no operation named %q", name)}
+	}
+	withDefaults := map[string]any{}
+	for _, v := range op.VariableDefinitions {
+		if v.DefaultValue != nil {
+			withDefaults[v.Variable], _ = v.DefaultValue.Value(nil)
+		}
+	}
+	for k, v := range vars {
+		withDefaults[k] = v
+	}
+
+	limits := graphQLLimits.Load()
+	schema := graphQLSchema.AST()
+	root := schema.RootOperationTypes[string(op.Operation)]
+	depth, cost := graphQLCost(doc, schema, op.SelectionSet, root, withDefaults, 1)
+	if depth > limits.MaxDepth {
+		qerr := gqlerrors.Errorf("the operation is %d fields deep, more than the limit of %d", depth, limits.MaxDepth)
+		qerr.Extensions = map[string]any{"code": "TOO_DEEP", "depth": depth, "limit": limits.MaxDepth}
+		return "", []*gqlerrors.QueryError{qerr}
+	}
+	if cost > limits.MaxComplexity {
+		qerr := gqlerrors.Errorf("the operation has a complexity of %d, more than the limit of %d; request fewer fields or smaller pages", cost, limits.MaxComplexity)
+		qerr.Extensions = map[string]any{"code": "TOO_COMPLEX", "complexity": cost, "limit": limits.MaxComplexity}
+		return "", []*gqlerrors.QueryError{qerr}
+	}
+	return op.Operation, nil
+}
+
+// graphQLCost returns the depth and complexity of set, selected on typ, as
+// described for GraphQLConfig, where items is the number of items in the
+// lists it holds. Introspection is bounded by the schema and costs nothing.
+func graphQLCost(doc *qast.QueryDocument, schema *ast.Schema, set qast.SelectionSet, typ ast.Type, vars map[string]any, items int) (depth, cost int) {
+	for _, sel := range set {
+		var d, c int
+		switch sel := sel.(type) {
+		case *qast.Field:
+			if strings.HasPrefix(sel.Name, "__") {
+				continue
+			}
+			def := graphQLFields(typ).Get(sel.Name)
+			if def == nil {
+				continue
+			}
+			n := items
+			if first, ok := graphQLArgument(sel, def, "first", vars); ok {
+				n = max(first, 0)
+			}
+			d, c = graphQLCost(doc, schema, sel.SelectionSet, def.Type, vars, n)
+			if graphQLIsList(def.Type) && c > 0 {
+				c = min(c, math.MaxInt32/max(n, 1)) * n
+			}
+			d, c = d+1, c+1
+		case *qast.InlineFragment:
+			on := typ
+			if sel.TypeCondition != "" {
+				on = schema.Types[sel.TypeCondition]
+			}
+			d, c = graphQLCost(doc, schema, sel.SelectionSet, on, vars, items)
+		case *qast.FragmentSpread:
+			if frag := doc.Fragments.ForName(sel.Name); frag != nil {
+				d, c = graphQLCost(doc, schema, frag.SelectionSet, schema.Types[frag.TypeCondition], vars, items)
+			}
+		}
+		depth = max(depth, d)
+		cost = min(cost+c, math.MaxInt32)
+	}
+	return depth, cost
+}
+
+// graphQLArgument returns the Int argument named name of a field, or its
+// default.
+func graphQLArgument(field *qast.Field, def *ast.FieldDefinition, name string, vars map[string]any) (int, bool) {
+	if arg := field.Arguments.ForName(name); arg != nil {
+		if v, _ := arg.Value.Value(vars); v != nil {
+			return toInt(v), true
+		}
+	}
+	if arg := def.Arguments.Get(name); arg != nil && arg.Default != nil {
+		return toInt(arg.Default.Deserialize(nil)), true
+	}
+	return 0, false
+}
+
+func graphQLFields(typ ast.Type) ast.FieldsDefinition {
+	switch t := typ.(type) {
+	case *ast.NonNull:
+		return graphQLFields(t.OfType)
+	case *ast.List:
+		return graphQLFields(t.OfType)
+	case *ast.ObjectTypeDefinition:
+		return t.Fields
+	case *ast.InterfaceTypeDefinition:
+		return t.Fields
+	}
+	return nil
+}
+
+func graphQLIsList(typ ast.Type) bool {
+	if t, ok := typ.(*ast.NonNull); ok {
+		typ = t.OfType
+	}
+	_, ok := typ.(*ast.List)
+	return ok
+}
+
+// toInt converts an Int argument as decoded from the query, the variables
+// or the schema.
+func toInt(v any) int {
+	switch v := v.(type) {
+	case int:
+		return v
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case json.Number:
+		n, _ := v.Int64()
+		return int(n)
+	}
+	return 0
+}
+
+// streamGraphQL sends each result of the operation as a next event, then a
+// complete event. On shutdown the stream completes early after a retry
+// delay; subscribers resume by passing the last event ID they saw as after.
+func streamGraphQL(w http.ResponseWriter, r *http.Request, query, name string, vars map[string]any) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	// Subscriptions are in place once Subscribe returns, so clients that
+	// see the response headers will get every later change.
+	results, err := graphQLSchema.Subscribe(ctx, query, name, vars)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rc, stop, ok := startStream(w, r)
+	if !ok {
+		return
+	}
+	defer endStream(stop)
+	rc.Flush()
+
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-stop:
+			fmt.Fprintf(w, "retry: %d\nevent: complete\ndata:\n\n", ShutdownRetry.Milliseconds())
+			rc.Flush()
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case res, ok := <-results:
+			if !ok {
+				fmt.Fprint(w, "event: complete\ndata:\n\n")
+				rc.Flush()
+				return
+			}
+			data, err := json.Marshal(newGraphQLResponse(res.(*graphql.Response)))
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: next\ndata: %s\n\n", data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
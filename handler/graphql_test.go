@@ -0,0 +1,365 @@
+package handler_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/events"
+	"github.com/sawez-deepsource/demo-go/handler"
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+type graphQLResult struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+// postGraphQL runs query with vars and decodes the result into data,
+// failing on GraphQL errors.
+func postGraphQL(t *testing.T, mux http.Handler, query string, vars map[string]any, data any) {
+	t.Helper()
+	res := postGraphQLResult(t, mux, query, vars)
+	if len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors: %+v", res.Errors)
+	}
+	if err := json.Unmarshal(res.Data, data); err != nil {
+		t.Fatalf("decoding %s: %v", res.Data, err)
+	}
+}
+
+func postGraphQLResult(t *testing.T, mux http.Handler, query string, vars map[string]any) graphQLResult {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"query": query, "variables": vars})
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var res graphQLResult
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestGraphQLQueries(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+	for _, task := range []model.Task{
+		{Title: "b", Priority: model.PriorityHigh, Project: "home", Due: "2024-03-01"},
+		{Title: "a", Priority: model.PriorityHigh, Project: "home", Done: true},
+		{Title: "c", Priority: model.PriorityLow, Project: "work", Due: "2024-01-01"},
+		{Title: "d", Priority: model.PriorityHigh},
+	} {
+		store.Add(task)
+	}
+
+	const query = `query($after: String) {
+		tasks(filter: {priority: HIGH}, sort: {field: TITLE}, first: 2, after: $after) {
+			nodes { title due project { name stats { total completed } } }
+			pageInfo { endCursor hasNextPage }
+			totalCount
+		}
+	}`
+	type page struct {
+		Tasks struct {
+			Nodes []struct {
+				Title   string
+				Due     *string
+				Project *struct {
+					Name  string
+					Stats struct{ Total, Completed int }
+				}
+			}
+			PageInfo struct {
+				EndCursor   string
+				HasNextPage bool
+			}
+			TotalCount int
+		}
+	}
+	var first page
+	postGraphQL(t, mux, query, nil, &first)
+	got := first.Tasks
+	if len(got.Nodes) != 2 || got.Nodes[0].Title != "a" || got.Nodes[1].Title != "b" || !got.PageInfo.HasNextPage || got.TotalCount != 3 {
+		t.Fatalf("unexpected first page %+v", got)
+	}
+	if p := got.Nodes[0].Project; p == nil || p.Name != "home" || p.Stats.Total != 2 || p.Stats.Completed != 1 {
+		t.Errorf("expected the home project with its stats, got %+v", p)
+	}
+	if got.Nodes[0].Due != nil || *got.Nodes[1].Due != "2024-03-01" {
+		t.Errorf("expected due dates to be null when unset, got %+v", got.Nodes)
+	}
+
+	var second page
+	postGraphQL(t, mux, query, map[string]any{"after": got.PageInfo.EndCursor}, &second)
+	got = second.Tasks
+	if len(got.Nodes) != 1 || got.Nodes[0].Title != "d" || got.Nodes[0].Project != nil || got.PageInfo.HasNextPage || got.TotalCount != 3 {
+		t.Fatalf("unexpected last page %+v", got)
+	}
+
+	var overview struct {
+		Projects []struct {
+			Name  string
+			Tasks struct{ Nodes []struct{ Title string } }
+		}
+		Stats struct{ Total, Completed, Pending int }
+	}
+	postGraphQL(t, mux, `{
+		projects(first: 10) { name tasks(sort: {field: DUE, direction: DESC}, first: 20) { nodes { title } } }
+		stats { total completed pending }
+	}`, nil, &overview)
+	if len(overview.Projects) != 2 || overview.Projects[0].Name != "home" || overview.Projects[1].Name != "work" {
+		t.Fatalf("expected both projects by name, got %+v", overview.Projects)
+	}
+	if nodes := overview.Projects[0].Tasks.Nodes; len(nodes) != 2 || nodes[0].Title != "a" {
+		t.Errorf("expected tasks without a due date last, reversed, got %+v", nodes)
+	}
+	if s := overview.Stats; s.Total != 4 || s.Completed != 1 || s.Pending != 3 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+
+	// Queries may also be sent with GET.
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(`{ stats { total } }`), nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"total":4`) {
+		t.Errorf("expected the stats over GET, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestGraphQLCursorSurvivesChanges(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+	var ids []string
+	for _, title := range []string{"a", "b", "c", "d"} {
+		ids = append(ids, store.Add(model.NewTask(title, "", model.PriorityLow)).ID)
+	}
+
+	const query = `query($after: String) {
+		tasks(sort: {field: TITLE}, first: 2, after: $after) {
+			nodes { title }
+			pageInfo { endCursor }
+		}
+	}`
+	type page struct {
+		Tasks struct {
+			Nodes    []struct{ Title string }
+			PageInfo struct{ EndCursor string }
+		}
+	}
+	var first page
+	postGraphQL(t, mux, query, nil, &first)
+	cursor := first.Tasks.PageInfo.EndCursor
+
+	// The last task of the page moves to the end of the order, then goes.
+	store.Update(ids[1], model.Task{Title: "z"})
+	var second page
+	postGraphQL(t, mux, query, map[string]any{"after": cursor}, &second)
+	if n := second.Tasks.Nodes; len(n) != 2 || n[0].Title != "c" || n[1].Title != "d" {
+		t.Fatalf("expected c and d after a renamed cursor task, got %+v", n)
+	}
+	store.Delete(ids[1])
+	postGraphQL(t, mux, query, map[string]any{"after": cursor}, &second)
+	if n := second.Tasks.Nodes; len(n) != 2 || n[0].Title != "c" {
+		t.Fatalf("expected c and d after a deleted cursor task, got %+v", n)
+	}
+
+	res := postGraphQLResult(t, mux, `query($after: String) { tasks(after: $after) { totalCount } }`, map[string]any{"after": cursor})
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "BAD_USER_INPUT" {
+		t.Fatalf("expected a cursor from another sort to be refused, got %+v", res.Errors)
+	}
+}
+
+func TestGraphQLMutations(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+
+	res := postGraphQLResult(t, mux, `mutation { createTask(input: {title: "", due: "soon"}) { id } }`, nil)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "BAD_USER_INPUT" {
+		t.Fatalf("expected a validation error, got %+v", res)
+	}
+	fields, _ := res.Errors[0].Extensions["fields"].([]any)
+	if len(fields) != 2 || fields[0].(map[string]any)["field"] != "input.title" || fields[1].(map[string]any)["field"] != "input.due" {
+		t.Errorf("expected the title and due fields to be named, got %v", fields)
+	}
+
+	var created struct {
+		CreateTask struct{ ID, Title, Priority string }
+	}
+	postGraphQL(t, mux, `mutation($input: TaskInput!) { createTask(input: $input) { id title priority } }`,
+		map[string]any{"input": map[string]any{"title": "Ship it", "priority": "MEDIUM"}}, &created)
+	stored, ok := store.Get(created.CreateTask.ID)
+	if !ok || stored.Title != "Ship it" || stored.Priority != model.PriorityMedium {
+		t.Fatalf("expected the task in the store, got %+v", stored)
+	}
+
+	var updated struct {
+		UpdateTask struct{ Done bool }
+	}
+	postGraphQL(t, mux, `mutation($id: ID!) { updateTask(id: $id, input: {title: "Shipped", done: true}) { done } }`,
+		map[string]any{"id": stored.ID}, &updated)
+	if !updated.UpdateTask.Done {
+		t.Errorf("expected the task to be done, got %+v", updated)
+	}
+
+	var deleted struct{ DeleteTask string }
+	postGraphQL(t, mux, `mutation($id: ID!) { deleteTask(id: $id) }`, map[string]any{"id": stored.ID}, &deleted)
+	if deleted.DeleteTask != stored.ID || store.Count() != 0 {
+		t.Errorf("expected the task to be deleted, got %+v", deleted)
+	}
+	res = postGraphQLResult(t, mux, `mutation($id: ID!) { deleteTask(id: $id) }`, map[string]any{"id": stored.ID})
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "NOT_FOUND" {
+		t.Errorf("expected NOT_FOUND, got %+v", res)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(`mutation { deleteTask(id: "1") }`), nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("expected mutations over GET to be refused, got %d", w.Code)
+	}
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+	t.Cleanup(func() { handler.SetGraphQLConfig(handler.DefaultGraphQLConfig) })
+
+	// 100 projects with 100 tasks each cost more than the default limit.
+	nested := `{ projects { tasks { nodes { title } } } }`
+	res := postGraphQLResult(t, mux, nested, nil)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "TOO_COMPLEX" || res.Data != nil {
+		t.Fatalf("expected the operation to be refused before running, got %+v", res)
+	}
+	viaFragment := `query($n: Int = 100) { projects(first: $n) { ...P } } fragment P on Project { tasks { nodes { title } } }`
+	res = postGraphQLResult(t, mux, viaFragment, nil)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "TOO_COMPLEX" {
+		t.Fatalf("expected fragments and variable defaults to be measured, got %+v", res)
+	}
+	var small struct{ Projects []any }
+	postGraphQL(t, mux, `{ projects(first: 5) { tasks(first: 10) { nodes { title } } } }`, nil, &small)
+
+	handler.SetGraphQLConfig(handler.GraphQLConfig{MaxDepth: 3, MaxComplexity: 1000})
+	res = postGraphQLResult(t, mux, `{ tasks { nodes { project { name } } } }`, nil)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "TOO_DEEP" {
+		t.Fatalf("expected the operation to be too deep, got %+v", res)
+	}
+
+	// Introspection is not limited, so that tools can load the schema.
+	var schema struct {
+		Schema struct{ Types []struct{ Name string } } `json:"__schema"`
+	}
+	postGraphQL(t, mux, `{ __schema { types { name fields { name type { name ofType { name ofType { name } } } } } } }`, nil, &schema)
+	if len(schema.Schema.Types) == 0 {
+		t.Error("expected the schema's types")
+	}
+
+	res = postGraphQLResult(t, mux, `{ tasks { nodes { nope } } }`, nil)
+	if len(res.Errors) != 1 || !strings.Contains(res.Errors[0].Message, "nope") {
+		t.Errorf("expected an invalid field to be reported, got %+v", res)
+	}
+}
+
+func TestGraphQLSubscription(t *testing.T) {
+	store.Clear()
+	srv := httptest.NewServer(setupMux())
+	t.Cleanup(srv.Close)
+
+	subscription := `subscription { taskChanged(filter: {priority: HIGH}) { id type task { title } } }`
+	body, _ := json.Marshal(map[string]any{"query": subscription})
+	resp, err := http.Post(srv.URL+"/graphql", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotAcceptable {
+		t.Fatalf("expected subscriptions without an event stream to be refused, got %d", resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	stream := bufio.NewReader(resp.Body)
+
+	store.Add(model.NewTask("Low", "desc", model.PriorityLow))
+	high := store.Add(model.NewTask("High", "desc", model.PriorityHigh))
+	high.Priority = model.PriorityMedium
+	store.Update(high.ID, high)
+
+	e := readEvent(t, stream)
+	var next struct {
+		Data struct {
+			TaskChanged struct {
+				ID   string
+				Type string
+				Task struct{ Title string }
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(e["data"]), &next); err != nil || e["event"] != "next" {
+		t.Fatalf("expected a next event, got %v (%v)", e, err)
+	}
+	if c := next.Data.TaskChanged; c.Type != "CREATED" || c.Task.Title != "High" || c.ID == "" {
+		t.Errorf("expected the high priority task to be created, got %+v", c)
+	}
+	e = readEvent(t, stream)
+	if err := json.Unmarshal([]byte(e["data"]), &next); err != nil || next.Data.TaskChanged.Type != "REMOVED" {
+		t.Errorf("expected the task to leave the filter, got %v (%v)", e, err)
+	}
+
+	if err := handler.ShutdownEventStreams(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if e := readEvent(t, stream); e["event"] != "complete" || e["retry"] != "1000" {
+		t.Errorf("expected the stream to complete with a retry delay, got %v", e)
+	}
+}
+
+func TestGraphQLSubscriptionRefusesExpiredAfter(t *testing.T) {
+	srv := httptest.NewServer(setupMux())
+	t.Cleanup(srv.Close)
+
+	subscription := `subscription($after: ID) { taskChanged(after: $after) { id } }`
+	body, _ := json.Marshal(map[string]any{"query": subscription, "variables": map[string]any{"after": strconv.FormatUint(events.LastID()+100, 10)}})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	stream := bufio.NewReader(resp.Body)
+
+	e := readEvent(t, stream)
+	if e["event"] != "next" || !strings.Contains(e["data"], "no longer buffered") {
+		t.Fatalf("expected an error for the expired after, got %v", e)
+	}
+	if e := readEvent(t, stream); e["event"] != "complete" {
+		t.Fatalf("expected the stream to complete, got %v", e)
+	}
+}
@@ -0,0 +1,591 @@
+package handler
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"github.com/sawez-deepsource/demo-go/events"
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+// graphQLSDL is the schema served at /graphql. Lists of tasks are
+// connections paged with first and after; first also sizes lists when
+// checking the complexity of an operation.
+const graphQLSDL = `
+schema {
+  query: Query
+  mutation: Mutation
+  subscription: Subscription
+}
+
+type Query {
+  tasks(filter: TaskFilter, sort: TaskSort, first: Int = 100, after: String): TaskConnection!
+  "The task with this ID, or null if there is none."
+  task(id: ID!): Task
+  "Projects that have tasks, by name."
+  projects(first: Int = 100): [Project!]!
+  stats: Stats!
+}
+
+type Mutation {
+  createTask(input: TaskInput!): Task!
+  "Replaces the client-settable fields of a task, like PUT /tasks/{id}."
+  updateTask(id: ID!, input: TaskInput!): Task!
+  "Returns the ID of the deleted task."
+  deleteTask(id: ID!): ID!
+}
+
+type Subscription {
+  """
+  Task changes matching the filter. Without after only changes from now on
+  are sent; with it the buffered events after that event ID come first, and
+  it is an error if some are no longer buffered.
+  """
+  taskChanged(filter: TaskFilter, after: ID): TaskEvent!
+}
+
+type Task {
+  id: ID!
+  title: String!
+  description: String!
+  done: Boolean!
+  priority: Priority!
+  "Null for tasks without a project."
+  project: Project
+  "A date (2006-01-02) or an RFC 3339 timestamp, or null."
+  due: String
+  createdAt: String!
+  updatedAt: String!
+}
+
+"The REST API's priorities 0, 1 and 2."
+enum Priority {
+  LOW
+  MEDIUM
+  HIGH
+}
+
+type Project {
+  name: String!
+  tasks(filter: TaskFilter, sort: TaskSort, first: Int = 100, after: String): TaskConnection!
+  stats: Stats!
+}
+
+type Stats {
+  total: Int!
+  completed: Int!
+  pending: Int!
+}
+
+type TaskConnection {
+  nodes: [Task!]!
+  pageInfo: PageInfo!
+  "The number of tasks matching the filter, across all pages."
+  totalCount: Int!
+}
+
+type PageInfo {
+  "Pass as after to get the next page."
+  endCursor: String
+  hasNextPage: Boolean!
+}
+
+type TaskEvent {
+  "Pass as after to resume the subscription."
+  id: ID!
+  type: ChangeType!
+  "For deletions, the task as it was before it was removed."
+  task: Task!
+}
+
+enum ChangeType {
+  CREATED
+  UPDATED
+  DELETED
+  "The task was updated and no longer matches the filter."
+  REMOVED
+}
+
+"Unset fields match every task; set fields must all match."
+input TaskFilter {
+  done: Boolean
+  priority: Priority
+  project: String
+}
+
+"Ties are broken by ID. Tasks without a due date sort after the others."
+input TaskSort {
+  field: TaskSortField!
+  direction: SortDirection = ASC
+}
+
+enum TaskSortField {
+  ID
+  TITLE
+  PRIORITY
+  DUE
+  CREATED_AT
+  UPDATED_AT
+}
+
+enum SortDirection {
+  ASC
+  DESC
+}
+
+input TaskInput {
+  title: String!
+  description: String = ""
+  done: Boolean = false
+  priority: Priority = LOW
+  project: String = ""
+  due: String = ""
+}
+`
+
+// graphQLRoot resolves the fields of Query, Mutation and Subscription.
+type graphQLRoot struct{}
+
+// graphQLTasksArgs are the arguments of task connections.
+type graphQLTasksArgs struct {
+	Filter *graphQLFilter
+	Sort   *graphQLSort
+	First  int32
+	After  *string
+}
+
+func (graphQLRoot) Tasks(ctx context.Context, args graphQLTasksArgs) (*graphQLConnection, error) {
+	return graphQLTasks(ctx, args, "")
+}
+
+func (graphQLRoot) Task(ctx context.Context, args struct{ ID graphql.ID }) *graphQLTask {
+	t, ok := store.GetContext(ctx, string(args.ID))
+	if !ok {
+		return nil
+	}
+	return &graphQLTask{t}
+}
+
+func (graphQLRoot) Projects(ctx context.Context, args struct{ First int32 }) ([]*graphQLProject, error) {
+	if err := checkFirst(args.First); err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, t := range store.AllContext(ctx) {
+		if t.Project != "" {
+			names[t.Project] = true
+		}
+	}
+	var projects []*graphQLProject
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		if len(projects) == int(args.First) {
+			break
+		}
+		projects = append(projects, &graphQLProject{name})
+	}
+	return projects, nil
+}
+
+func (graphQLRoot) Stats(ctx context.Context) *graphQLStats {
+	return newGraphQLStats(store.AllContext(ctx))
+}
+
+func (graphQLRoot) CreateTask(ctx context.Context, args struct{ Input graphQLTaskInput }) (*graphQLTask, error) {
+	t := args.Input.task()
+	if err := validateGraphQLTask(t); err != nil {
+		return nil, err
+	}
+	if !ChargeCreates(ctx, 1).Allowed {
+		return nil, &graphQLError{code: "QUOTA_EXCEEDED", message: "daily task creation quota exceeded"}
+	}
+	created := store.AddContext(ctx, t)
+	slog.InfoContext(ctx, "task created", "id", created.ID, "title", created.Title)
+	return &graphQLTask{created}, nil
+}
+
+func (graphQLRoot) UpdateTask(ctx context.Context, args struct {
+	ID    graphql.ID
+	Input graphQLTaskInput
+}) (*graphQLTask, error) {
+	t := args.Input.task()
+	if err := validateGraphQLTask(t); err != nil {
+		return nil, err
+	}
+	updated, ok := store.UpdateContext(ctx, string(args.ID), t)
+	if !ok {
+		return nil, &graphQLError{code: "NOT_FOUND", message: "task not found"}
+	}
+	slog.InfoContext(ctx, "task updated", "id", updated.ID, "title", updated.Title)
+	return &graphQLTask{updated}, nil
+}
+
+func (graphQLRoot) DeleteTask(ctx context.Context, args struct{ ID graphql.ID }) (graphql.ID, error) {
+	if !store.DeleteContext(ctx, string(args.ID)) {
+		return "", &graphQLError{code: "NOT_FOUND", message: "task not found"}
+	}
+	slog.InfoContext(ctx, "task deleted", "id", args.ID)
+	return args.ID, nil
+}
+
+// TaskChanged subscribes to the change feed like GET /events. The channel
+// is closed when ctx is done, or when the subscriber falls too far behind
+// and should resume with after. An after whose following events are no
+// longer buffered is refused, since resuming from it would skip changes.
+func (graphQLRoot) TaskChanged(ctx context.Context, args struct {
+	Filter *graphQLFilter
+	After  *graphql.ID
+}) (<-chan *graphQLEvent, error) {
+	filter := args.Filter.filter()
+	after := events.LastID()
+	if args.After != nil {
+		id, err := strconv.ParseUint(string(*args.After), 10, 64)
+		if err != nil {
+			return nil, &graphQLError{code: "BAD_USER_INPUT", message: "after must be an event ID"}
+		}
+		after = id
+	}
+	backlog, sub := events.Subscribe(after)
+	if sub.Missed {
+		events.Cancel(sub)
+		return nil, &graphQLError{code: "BAD_USER_INPUT", message: "the events after after are no longer buffered; query the tasks again and subscribe without it"}
+	}
+	out := make(chan *graphQLEvent)
+	go func() {
+		defer close(out)
+		defer events.Cancel(sub)
+		send := func(e events.Event) bool {
+			e, ok := filter.Select(e)
+			if !ok {
+				return true
+			}
+			select {
+			case out <- &graphQLEvent{e}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for _, e := range backlog {
+			if !send(e) {
+				return
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-sub.C:
+				if !ok || !send(e) {
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// graphQLTasks resolves a task connection, limited to project if it is not
+// empty. Cursors hold the sort and the sort key and ID of the last task of
+// a page, so the next page starts in the right place even if that task has
+// changed or been deleted since.
+func graphQLTasks(ctx context.Context, args graphQLTasksArgs, project string) (*graphQLConnection, error) {
+	if err := checkFirst(args.First); err != nil {
+		return nil, err
+	}
+	filter := args.Filter.filter()
+	if project != "" {
+		filter.Project = project
+	}
+	var tasks []model.Task
+	for _, t := range store.AllContext(ctx) {
+		if filter.Match(t) {
+			tasks = append(tasks, t)
+		}
+	}
+	compare := args.Sort.compare()
+	slices.SortFunc(tasks, compare)
+	conn := &graphQLConnection{TotalCount: int32(len(tasks))}
+
+	if args.After != nil {
+		cursor, err := decodeCursor(*args.After, args.Sort.String())
+		if err != nil {
+			return nil, &graphQLError{code: "BAD_USER_INPUT", message: err.Error()}
+		}
+		i, found := slices.BinarySearchFunc(tasks, cursor, compare)
+		if found {
+			i++
+		}
+		tasks = tasks[i:]
+	}
+
+	if len(tasks) > int(args.First) {
+		tasks = tasks[:args.First]
+		conn.PageInfo.HasNextPage = true
+	}
+	conn.Nodes = make([]*graphQLTask, len(tasks))
+	for i, t := range tasks {
+		conn.Nodes[i] = &graphQLTask{t}
+	}
+	if len(tasks) > 0 {
+		cursor := encodeCursor(tasks[len(tasks)-1], args.Sort.String())
+		conn.PageInfo.EndCursor = &cursor
+	}
+	return conn, nil
+}
+
+// graphQLCursor is the content of an opaque page cursor: the sort it was
+// made for and the fields of the last task that sorts compare.
+type graphQLCursor struct {
+	Sort      string         `json:"s"`
+	ID        string         `json:"i"`
+	Title     string         `json:"t,omitempty"`
+	Priority  model.Priority `json:"p,omitempty"`
+	Due       string         `json:"d,omitempty"`
+	CreatedAt string         `json:"c,omitempty"`
+	UpdatedAt string         `json:"u,omitempty"`
+}
+
+func encodeCursor(t model.Task, sort string) string {
+	b, _ := json.Marshal(graphQLCursor{
+		Sort: sort, ID: t.ID, Title: t.Title, Priority: t.Priority,
+		Due: t.Due, CreatedAt: t.CreatedAt, UpdatedAt: t.UpdatedAt,
+	})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor returns the task a cursor for sort stands for.
+func decodeCursor(s, sort string) (model.Task, error) {
+	var c graphQLCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil || c.ID == "" {
+		return model.Task{}, errors.New("after must be an endCursor")
+	}
+	if c.Sort != sort {
+		return model.Task{}, errors.New("after is the endCursor of a different sort")
+	}
+	return model.Task{
+		ID: c.ID, Title: c.Title, Priority: c.Priority,
+		Due: c.Due, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt,
+	}, nil
+}
+
+func checkFirst(first int32) error {
+	if first < 1 || first > MaxPageSize {
+		return &graphQLError{code: "BAD_USER_INPUT", message: fmt.Sprintf("first must be between 1 and %d", MaxPageSize)}
+	}
+	return nil
+}
+
+type graphQLConnection struct {
+	Nodes      []*graphQLTask
+	PageInfo   graphQLPageInfo
+	TotalCount int32
+}
+
+type graphQLPageInfo struct {
+	EndCursor   *string
+	HasNextPage bool
+}
+
+type graphQLStats struct {
+	Total     int32
+	Completed int32
+	Pending   int32
+}
+
+func newGraphQLStats(tasks []model.Task) *graphQLStats {
+	s := &graphQLStats{Total: int32(len(tasks))}
+	for _, t := range tasks {
+		if t.Done {
+			s.Completed++
+		}
+	}
+	s.Pending = s.Total - s.Completed
+	return s
+}
+
+type graphQLTask struct {
+	t model.Task
+}
+
+func (t *graphQLTask) ID() graphql.ID      { return graphql.ID(t.t.ID) }
+func (t *graphQLTask) Title() string       { return t.t.Title }
+func (t *graphQLTask) Description() string { return t.t.Description }
+func (t *graphQLTask) Done() bool          { return t.t.Done }
+func (t *graphQLTask) Priority() string    { return strings.ToUpper(t.t.Priority.String()) }
+func (t *graphQLTask) CreatedAt() string   { return t.t.CreatedAt }
+func (t *graphQLTask) UpdatedAt() string   { return t.t.UpdatedAt }
+
+func (t *graphQLTask) Project() *graphQLProject {
+	if t.t.Project == "" {
+		return nil
+	}
+	return &graphQLProject{t.t.Project}
+}
+
+func (t *graphQLTask) Due() *string {
+	if t.t.Due == "" {
+		return nil
+	}
+	return &t.t.Due
+}
+
+type graphQLProject struct {
+	name string
+}
+
+func (p *graphQLProject) Name() string { return p.name }
+
+func (p *graphQLProject) Tasks(ctx context.Context, args graphQLTasksArgs) (*graphQLConnection, error) {
+	return graphQLTasks(ctx, args, p.name)
+}
+
+func (p *graphQLProject) Stats(ctx context.Context) *graphQLStats {
+	return newGraphQLStats(store.FilterByProjectContext(ctx, p.name))
+}
+
+type graphQLEvent struct {
+	e events.Event
+}
+
+func (e *graphQLEvent) ID() graphql.ID     { return graphql.ID(strconv.FormatUint(e.e.ID, 10)) }
+func (e *graphQLEvent) Type() string       { return strings.ToUpper(string(e.e.Type)) }
+func (e *graphQLEvent) Task() *graphQLTask { return &graphQLTask{e.e.Task} }
+
+type graphQLFilter struct {
+	Done     *bool
+	Priority *string
+	Project  *string
+}
+
+// filter converts f, which may be nil to match everything.
+func (f *graphQLFilter) filter() events.Filter {
+	var filter events.Filter
+	if f == nil {
+		return filter
+	}
+	filter.Done = f.Done
+	if f.Priority != nil {
+		// The schema only admits the Priority enum's values, which are
+		// the model's names in upper case.
+		p, _ := model.ParsePriority(*f.Priority)
+		filter.Priority = &p
+	}
+	if f.Project != nil {
+		filter.Project = *f.Project
+	}
+	return filter
+}
+
+type graphQLSort struct {
+	Field     string
+	Direction string
+}
+
+// graphQLSortFields compares tasks by each TaskSortField.
+var graphQLSortFields = map[string]func(a, b model.Task) int{
+	"ID":         func(a, b model.Task) int { return strings.Compare(a.ID, b.ID) },
+	"TITLE":      func(a, b model.Task) int { return strings.Compare(a.Title, b.Title) },
+	"PRIORITY":   func(a, b model.Task) int { return cmp.Compare(a.Priority, b.Priority) },
+	"CREATED_AT": func(a, b model.Task) int { return strings.Compare(a.CreatedAt, b.CreatedAt) },
+	"UPDATED_AT": func(a, b model.Task) int { return strings.Compare(a.UpdatedAt, b.UpdatedAt) },
+	"DUE": func(a, b model.Task) int {
+		if (a.Due == "") != (b.Due == "") {
+			return cmp.Compare(b.Due, a.Due)
+		}
+		return strings.Compare(a.Due, b.Due)
+	},
+}
+
+// String names the order s selects, e.g. "TITLE DESC".
+func (s *graphQLSort) String() string {
+	if s == nil {
+		return "ID ASC"
+	}
+	return s.Field + " " + s.Direction
+}
+
+// compare returns the order s selects, which for a nil s is by ID.
+func (s *graphQLSort) compare() func(a, b model.Task) int {
+	byField, desc := graphQLSortFields["ID"], false
+	if s != nil {
+		byField, desc = graphQLSortFields[s.Field], s.Direction == "DESC"
+	}
+	return func(a, b model.Task) int {
+		c := byField(a, b)
+		if c == 0 {
+			c = strings.Compare(a.ID, b.ID)
+		}
+		if desc {
+			return -c
+		}
+		return c
+	}
+}
+
+type graphQLTaskInput struct {
+	Title       string
+	Description string
+	Done        bool
+	Priority    string
+	Project     string
+	Due         string
+}
+
+func (in graphQLTaskInput) task() model.Task {
+	priority, _ := model.ParsePriority(in.Priority)
+	return model.Task{
+		Title:       in.Title,
+		Description: in.Description,
+		Done:        in.Done,
+		Priority:    priority,
+		Project:     in.Project,
+		Due:         in.Due,
+	}
+}
+
+// graphQLError is a resolver error whose extensions carry a code, and for
+// invalid input the fields at fault, as "input.<field>".
+type graphQLError struct {
+	code    string
+	message string
+	fields  []model.FieldError
+}
+
+func (e *graphQLError) Error() string { return e.message }
+
+func (e *graphQLError) Extensions() map[string]any {
+	ext := map[string]any{"code": e.code}
+	if len(e.fields) > 0 {
+		fields := make([]map[string]any, len(e.fields))
+		for i, f := range e.fields {
+			fields[i] = map[string]any{"field": "input." + f.Field, "code": f.Code, "message": f.Message}
+		}
+		ext["fields"] = fields
+	}
+	return ext
+}
+
+// validateGraphQLTask applies the model's rules, like validateTask.
+func validateGraphQLTask(t model.Task) error {
+	var errs model.ValidationError
+	if !errors.As(model.Validate(t), &errs) {
+		return nil
+	}
+	return &graphQLError{code: "BAD_USER_INPUT", message: "the input has invalid fields", fields: errs}
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/sawez-deepsource/demo-go/health"
+)
+
+// Healthz answers as long as the process can serve HTTP at all.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, r, http.StatusOK, health.Report{Status: health.StatusOK, Checks: []health.CheckResult{}})
+}
+
+// Livez runs the liveness checks and answers 503 if any fails, telling the
+// orchestrator to restart the process.
+func Livez(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, r, health.Live(r.Context()))
+}
+
+// Readyz runs the readiness checks and answers 503 if any fails, including
+// once shutdown has begun, telling load balancers to stop sending traffic.
+// A degraded report still answers 200.
+func Readyz(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, r, health.Ready(r.Context()))
+}
+
+func writeReport(w http.ResponseWriter, r *http.Request, report health.Report) {
+	status := http.StatusOK
+	if report.Status == health.StatusFail {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeResponse(w, r, status, report)
+}
+
+// ProbeMux returns a mux serving only the health probes.
+func ProbeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", Healthz)
+	mux.HandleFunc("GET /livez", Livez)
+	mux.HandleFunc("GET /readyz", Readyz)
+	return mux
+}
+
+// Probes serves the health probes itself and passes every other request to
+// next, so that orchestrators can probe without a client certificate, a
+// rate limit budget or spec validation in the way.
+func Probes(next http.Handler) http.Handler {
+	probes := ProbeMux()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := probes.Handler(r); pattern != "" {
+			probes.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,72 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/handler"
+	"github.com/sawez-deepsource/demo-go/health"
+)
+
+func probe(t *testing.T, mux http.Handler, path string) (int, health.Report) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	var report health.Report
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("decoding %s: %v", path, err)
+	}
+	return w.Code, report
+}
+
+func TestProbes(t *testing.T) {
+	mux := setupMux()
+	var down atomic.Bool
+	health.Register(health.Check{Name: "test_backend", Readiness: true, Run: func(context.Context) error {
+		if down.Load() {
+			return errors.New("unreachable")
+		}
+		return nil
+	}})
+
+	for _, path := range []string{"/healthz", "/livez", "/readyz"} {
+		if code, report := probe(t, mux, path); code != http.StatusOK || report.Status != health.StatusOK {
+			t.Errorf("expected %s to pass, got %d %+v", path, code, report)
+		}
+	}
+	if _, report := probe(t, mux, "/readyz"); len(report.Checks) < 4 || report.Checks[0].Name != "store" {
+		t.Errorf("expected every readiness check in the report, got %+v", report.Checks)
+	}
+
+	down.Store(true)
+	defer down.Store(false)
+	code, report := probe(t, mux, "/readyz")
+	if code != http.StatusServiceUnavailable || report.Status != health.StatusFail {
+		t.Fatalf("expected a failing check to fail readiness, got %d %+v", code, report)
+	}
+	if code, _ := probe(t, mux, "/livez"); code != http.StatusOK {
+		t.Errorf("expected liveness to ignore readiness checks, got %d", code)
+	}
+}
+
+func TestProbesBypassAuthAndLimits(t *testing.T) {
+	refuse := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	h := handler.Probes(refuse)
+	for _, path := range []string{"/healthz", "/livez"} {
+		if code, _ := probe(t, h, path); code != http.StatusOK {
+			t.Errorf("expected %s to be answered before the wrapped handler, got %d", path, code)
+		}
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected other requests to reach the wrapped handler, got %d", w.Code)
+	}
+}
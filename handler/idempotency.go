@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyTTL is how long the response to a request with an
+// Idempotency-Key is kept for retries.
+var IdempotencyTTL = 24 * time.Hour
+
+// IdempotencySweepInterval is how often SweepIdempotencyKeys looks for
+// expired keys.
+var IdempotencySweepInterval = time.Minute
+
+const maxIdempotencyKeyLength = 255
+
+// idempotentResponse is the first response to a key, recorded once done.
+type idempotentResponse struct {
+	fingerprint [sha256.Size]byte
+	expires     time.Time
+	done        bool
+	status      int
+	header      http.Header
+	body        []byte
+}
+
+var (
+	idempotencyMu sync.Mutex
+	// idempotencyKeys is keyed by the client, as identified for rate
+	// limiting, and its Idempotency-Key.
+	idempotencyKeys = map[string]*idempotentResponse{}
+)
+
+// Idempotent makes next safe to retry for clients that send an
+// Idempotency-Key header. The first response for a key, unless it is a
+// server error or 429, is kept for IdempotencyTTL and replayed with an
+// Idempotent-Replayed header to later requests from the same client with
+// the same key and body. Reusing a key with a different body fails with
+// 422, and retrying while the first request is in progress with 409.
+func Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength))
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+		if err != nil {
+			writeError(w, r, http.StatusRequestEntityTooLarge, "the request body is too large")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := sha256.Sum256(body)
+		scoped := clientKey(r) + " " + key
+
+		idempotencyMu.Lock()
+		first, found := idempotencyKeys[scoped]
+		if found && !time.Now().Before(first.expires) {
+			found = false
+		}
+		var prev idempotentResponse
+		if found {
+			prev = *first
+		} else {
+			first = &idempotentResponse{fingerprint: fingerprint, expires: time.Now().Add(IdempotencyTTL)}
+			idempotencyKeys[scoped] = first
+		}
+		idempotencyMu.Unlock()
+
+		if found {
+			switch {
+			case prev.fingerprint != fingerprint:
+				writeError(w, r, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request body")
+			case !prev.done:
+				writeError(w, r, http.StatusConflict, "a request with this Idempotency-Key is still in progress")
+			default:
+				maps.Copy(w.Header(), prev.header)
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(prev.status)
+				w.Write(prev.body)
+			}
+			return
+		}
+
+		rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		next(rec, r)
+		idempotencyMu.Lock()
+		if rec.status >= 500 || rec.status == http.StatusTooManyRequests {
+			// Nothing was done: let the client retry for real.
+			if idempotencyKeys[scoped] == first {
+				delete(idempotencyKeys, scoped)
+			}
+		} else {
+			first.done, first.status, first.header, first.body = true, rec.status, rec.header.Clone(), rec.body.Bytes()
+		}
+		idempotencyMu.Unlock()
+		rec.copyTo(w)
+	}
+}
+
+// SweepIdempotencyKeys removes expired Idempotency-Keys every
+// IdempotencySweepInterval until ctx is done.
+func SweepIdempotencyKeys(ctx context.Context) {
+	ticker := time.NewTicker(IdempotencySweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if n := sweepIdempotencyKeys(now); n > 0 {
+				slog.DebugContext(ctx, "expired idempotency keys removed", "count", n)
+			}
+		}
+	}
+}
+
+func sweepIdempotencyKeys(now time.Time) int {
+	idempotencyMu.Lock()
+	defer idempotencyMu.Unlock()
+	n := len(idempotencyKeys)
+	maps.DeleteFunc(idempotencyKeys, func(_ string, e *idempotentResponse) bool {
+		return !now.Before(e.expires)
+	})
+	return n - len(idempotencyKeys)
+}
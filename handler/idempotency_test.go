@@ -0,0 +1,94 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/handler"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+func createWithKey(mux http.Handler, key, remoteAddr, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+	if remoteAddr != "" {
+		req.RemoteAddr = remoteAddr
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotentCreate(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+	const body = `{"title":"Once","priority":1}`
+
+	first := createWithKey(mux, "replay", "", body)
+	if first.Code != http.StatusCreated || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("expected the task to be created, got %d: %s", first.Code, first.Body)
+	}
+	retry := createWithKey(mux, "replay", "", body)
+	if retry.Code != http.StatusCreated || retry.Header().Get("Idempotent-Replayed") != "true" || retry.Body.String() != first.Body.String() {
+		t.Fatalf("expected the first response to be replayed, got %d: %s", retry.Code, retry.Body)
+	}
+	if store.Count() != 1 {
+		t.Fatalf("expected one task, got %d", store.Count())
+	}
+
+	if w := createWithKey(mux, "replay", "", `{"title":"Twice","priority":1}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected a different body to be refused with 422, got %d", w.Code)
+	}
+	if w := createWithKey(mux, "replay", "198.51.100.7:4321", body); w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("expected keys to be scoped per client, got %d", w.Code)
+	}
+	if w := createWithKey(mux, strings.Repeat("k", 256), "", body); w.Code != http.StatusBadRequest {
+		t.Errorf("expected an overlong key to be refused, got %d", w.Code)
+	}
+
+	// Invalid tasks are replayed too, so a retry sees the same error.
+	if w := createWithKey(mux, "invalid", "", `{"title":""}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	if w := createWithKey(mux, "invalid", "", `{"title":""}`); w.Code != http.StatusBadRequest || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("expected the error to be replayed, got %d", w.Code)
+	}
+	if store.Count() != 2 {
+		t.Errorf("expected two tasks, got %d", store.Count())
+	}
+}
+
+func TestIdempotencyKeysExpire(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+	ttl, interval := handler.IdempotencyTTL, handler.IdempotencySweepInterval
+	handler.IdempotencyTTL, handler.IdempotencySweepInterval = 20*time.Millisecond, 5*time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		handler.SweepIdempotencyKeys(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		handler.IdempotencyTTL, handler.IdempotencySweepInterval = ttl, interval
+	})
+
+	if w := createWithKey(mux, "expiring", "", `{"title":"First","priority":1}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+	time.Sleep(50 * time.Millisecond)
+	w := createWithKey(mux, "expiring", "", `{"title":"Second","priority":1}`)
+	if w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("expected the expired key to be reusable, got %d: %s", w.Code, w.Body)
+	}
+	if store.Count() != 2 {
+		t.Errorf("expected two tasks, got %d", store.Count())
+	}
+}
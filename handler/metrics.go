@@ -0,0 +1,14 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/sawez-deepsource/demo-go/metrics"
+)
+
+var metricsHandler = metrics.Handler()
+
+// Metrics serves the Prometheus metrics collected by the metrics package.
+func Metrics(w http.ResponseWriter, r *http.Request) {
+	metricsHandler.ServeHTTP(w, r)
+}
@@ -0,0 +1,653 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sawez-deepsource/demo-go/health"
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+	"github.com/sawez-deepsource/demo-go/webhook"
+)
+
+// apiOperation documents one entry of Routes. Bodies are given as Go values
+// whose types are reflected into JSON Schema, so the document follows the
+// structs the handlers actually encode and decode.
+type apiOperation struct {
+	Summary     string
+	Description string
+	Params      []apiParam
+	// Body is the request body decoded with the codec named by
+	// Content-Type. RawBody lists media types accepted as-is instead.
+	Body      any
+	RawBody   []string
+	Responses map[int]apiResponse
+}
+
+type apiParam struct {
+	Name        string
+	In          string
+	Description string
+	Type        any
+	Enum        []string
+	Required    bool
+}
+
+// apiResponse is either a Body encoded with the negotiated codec, a Problem,
+// or a document in one of the Raw media types; a Body may be offered in Raw
+// media types as well. Headers maps response header names to descriptions.
+type apiResponse struct {
+	Description string
+	Body        any
+	Problem     bool
+	Raw         []string
+	Headers     map[string]string
+}
+
+var taskFilterParams = []apiParam{
+	{Name: "done", In: "query", Type: true, Description: "Only tasks that are, or are not, done."},
+	{Name: "priority", In: "query", Type: model.PriorityLow, Description: "Only tasks with this priority."},
+	{Name: "project", In: "query", Type: "", Description: "Only tasks in this project."},
+}
+
+var formatParam = apiParam{Name: "format", In: "query", Type: "", Enum: fileFormats, Description: "File format. Defaults to csv."}
+
+var fileMediaTypes = []string{"text/csv", "text/calendar", "text/plain", "text/markdown"}
+
+func problem(description string) apiResponse {
+	return apiResponse{Description: description, Problem: true}
+}
+
+// apiOperations describes every route in Routes, keyed by its pattern.
+var apiOperations = map[string]apiOperation{
+	"GET /tasks": {
+		Summary:     "List tasks",
+		Description: "Filters are exclusive: done is applied first, then priority, then project. Without limit every matching task is returned.",
+		Params: append([]apiParam{
+			{Name: "limit", In: "query", Type: 0, Description: fmt.Sprintf("Page size, from 1 to %d.", MaxPageSize)},
+			{Name: "after", In: "query", Type: "", Description: "Return tasks after this ID, as given in the next link."},
+		}, taskFilterParams...),
+		Responses: map[int]apiResponse{
+			200: {
+				Description: "The selected tasks, ordered by ID.",
+				Body:        []model.Task{},
+				Headers:     map[string]string{"Link": `The next page as a rel="next" link, when limit is set and more tasks remain.`},
+			},
+			400: problem("A filter or the page size is invalid."),
+		},
+	},
+	"POST /tasks": {
+		Summary:     "Create a task",
+		Description: "Send an Idempotency-Key to retry safely: the first response for a key is replayed to retries from the same client with the same body for 24 hours, instead of creating the task again.",
+		Params: []apiParam{
+			{Name: "Idempotency-Key", In: "header", Type: "", Description: "A unique value, such as a UUID, identifying this creation across retries. At most 255 characters."},
+		},
+		Body: model.Task{},
+		Responses: map[int]apiResponse{
+			201: {
+				Description: "The created task.",
+				Body:        model.Task{},
+				Headers:     map[string]string{"Idempotent-Replayed": "true when this is the response to an earlier request with the same Idempotency-Key."},
+			},
+			400: problem("The task is invalid, or the Idempotency-Key is too long."),
+			409: problem("A request with the same Idempotency-Key is still in progress."),
+			413: problem("The body is too large."),
+			422: problem("The Idempotency-Key was used with a different body."),
+		},
+	},
+	"GET /tasks/{id}": {
+		Summary: "Get a task",
+		Responses: map[int]apiResponse{
+			200: {Description: "The task.", Body: model.Task{}},
+			404: problem("No task has this ID."),
+		},
+	},
+	"PUT /tasks/{id}": {
+		Summary: "Replace a task",
+		Body:    model.Task{},
+		Responses: map[int]apiResponse{
+			200: {Description: "The updated task.", Body: model.Task{}},
+			400: problem("The task is invalid."),
+			404: problem("No task has this ID."),
+		},
+	},
+	"DELETE /tasks/{id}": {
+		Summary: "Delete a task",
+		Responses: map[int]apiResponse{
+			204: {Description: "The task was deleted."},
+			404: problem("No task has this ID."),
+		},
+	},
+	"POST /tasks:batch": {
+		Summary:     "Apply several operations",
+		Description: fmt.Sprintf("Applies up to %d create, update and delete operations. Atomic batches are all-or-nothing; operations that would have succeeded in a failed atomic batch report 424.", MaxBatchSize),
+		Body:        batchRequest{},
+		Responses: map[int]apiResponse{
+			207: {Description: "One result per operation, in request order.", Body: batchResponse{}},
+			400: problem("The batch is empty, too large or malformed."),
+		},
+	},
+	"GET /tasks/export": {
+		Summary: "Export tasks as a file",
+		Params:  append([]apiParam{formatParam}, taskFilterParams...),
+		Responses: map[int]apiResponse{
+			200: {Description: "The selected tasks in the requested format.", Raw: fileMediaTypes},
+			400: problem("The format or a filter is invalid."),
+		},
+	},
+	"POST /tasks/import": {
+		Summary:     "Import tasks from a file",
+		Description: "Rows whose ID matches an existing task update the fields the format carries, or the columns a CSV file has; all others create new tasks. VTODOs are matched by UID, including UIDs from other calendars imported before. Rows with errors are skipped.",
+		Params: []apiParam{
+			formatParam,
+			{Name: "map", In: "query", Type: "", Description: "CSV column aliases as a comma-separated list of Header:column pairs, e.g. Summary:title,Urgency:priority."},
+			{Name: "dry_run", In: "query", Type: true, Description: "Validate the file without changing any tasks."},
+		},
+		RawBody: fileMediaTypes,
+		Responses: map[int]apiResponse{
+			200: {Description: "A summary of the import.", Body: importResponse{}},
+			400: problem("The file cannot be parsed."),
+		},
+	},
+	"GET /tasks.ics": {
+		Summary: "Calendar feed",
+		Params: append([]apiParam{
+			{Name: "subscription", In: "query", Type: "", Required: true, Description: "Subscription ID."},
+			{Name: "token", In: "query", Type: "", Required: true, Description: "Token issued for the subscription."},
+		}, taskFilterParams...),
+		Responses: map[int]apiResponse{
+			200: {Description: "Tasks as VTODO components.", Raw: []string{"text/calendar"}},
+			400: problem("A filter is invalid."),
+			401: problem("The subscription was revoked or the token does not match it."),
+		},
+	},
+	"POST /calendar/subscriptions": {
+		Summary:     "Create a calendar feed subscription",
+		Description: "The feed URL carries the token, since calendar clients cannot authenticate. When the caller presents a client certificate, only that user can list and revoke the subscription.",
+		Body:        calendarSubscriptionRequest{},
+		Responses: map[int]apiResponse{
+			201: {Description: "The feed URL for the subscription.", Body: calendarSubscriptionResponse{}},
+			400: problem("The name is missing."),
+			500: problem("The subscription could not be saved."),
+		},
+	},
+	"GET /calendar/subscriptions": {
+		Summary: "List calendar feed subscriptions",
+		Responses: map[int]apiResponse{
+			200: {Description: "The subscriptions the caller owns, without tokens.", Body: []calendarSubscription{}},
+		},
+	},
+	"DELETE /calendar/subscriptions/{id}": {
+		Summary:     "Revoke a calendar feed subscription",
+		Description: "Its feed URL stops working for good: subscription IDs are never reused.",
+		Responses: map[int]apiResponse{
+			204: {Description: "The subscription was revoked."},
+			404: problem("The caller owns no subscription with this ID."),
+			500: problem("The revocation could not be saved."),
+		},
+	},
+	"GET /stats": {
+		Summary: "Task counts",
+		Responses: map[int]apiResponse{
+			200: {Description: "Counts of all, completed and pending tasks.", Body: statsResponse{}},
+		},
+	},
+	"GET /events": {
+		Summary:     "Stream task changes",
+		Description: "Server-sent events named created, updated or deleted, each carrying the task as JSON data, and removed for tasks an update takes out of the filter. Send Last-Event-ID to resume after a reconnect; if the events after it are no longer buffered, the stream starts with a reset event with empty data, after which the tasks should be loaded again. When the server shuts down it sends a shutdown event with empty data and ends the stream.",
+		Params: append([]apiParam{
+			{Name: "Last-Event-ID", In: "header", Type: uint64(0), Description: "ID of the last event received."},
+		}, taskFilterParams...),
+		Responses: map[int]apiResponse{
+			200: {Description: "An event stream.", Raw: []string{"text/event-stream"}},
+			400: problem("A filter or Last-Event-ID is invalid."),
+		},
+	},
+	"GET /ws": {
+		Summary:     "Task WebSocket",
+		Description: "Upgrades to a WebSocket carrying JSON messages in the SocketMessage schema, in both directions. Subscriptions get removed events for tasks an update takes out of their filter. When the server shuts down it answers the command in progress and closes with status 1001 (going away).",
+		Responses: map[int]apiResponse{
+			101: {Description: "Switching to the WebSocket protocol."},
+		},
+	},
+	"GET /graphql": {
+		Summary:     "Run a GraphQL query",
+		Description: graphQLDescription + " Mutations must be sent with POST.",
+		Params: []apiParam{
+			{Name: "query", In: "query", Type: "", Required: true, Description: "The GraphQL document."},
+			{Name: "operationName", In: "query", Type: "", Description: "The operation to run, if the document has several."},
+			{Name: "variables", In: "query", Type: "", Description: "Variables as a JSON object."},
+		},
+		Responses: graphQLResponses(map[int]apiResponse{
+			400: problem("The query is missing or the variables are not JSON."),
+			405: problem("The operation is a mutation."),
+		}),
+	},
+	"POST /graphql": {
+		Summary:     "Run a GraphQL operation",
+		Description: graphQLDescription,
+		Body:        graphQLRequest{},
+		Responses: graphQLResponses(map[int]apiResponse{
+			400: problem("The payload is invalid or has no query."),
+		}),
+	},
+	"POST /webhooks": {
+		Summary:     "Register a webhook",
+		Description: "Task changes are POSTed to the URL, signed with the secret. A secret is generated if none is given; it is only returned here. The URL must reach a public address, and redirects are not followed.",
+		Body:        webhookRequest{},
+		Responses: map[int]apiResponse{
+			201: {Description: "The subscription, including its secret.", Body: webhook.Subscription{}},
+			400: problem("The URL or event list is invalid, or the URL targets a private address."),
+		},
+	},
+	"GET /webhooks": {
+		Summary: "List webhooks",
+		Responses: map[int]apiResponse{
+			200: {Description: "Every subscription, without secrets.", Body: []webhook.Subscription{}},
+		},
+	},
+	"GET /webhooks/{id}": {
+		Summary: "Get a webhook",
+		Responses: map[int]apiResponse{
+			200: {Description: "The subscription, without its secret.", Body: webhook.Subscription{}},
+			404: problem("No webhook has this ID."),
+		},
+	},
+	"DELETE /webhooks/{id}": {
+		Summary: "Delete a webhook",
+		Responses: map[int]apiResponse{
+			204: {Description: "The webhook was deleted."},
+			404: problem("No webhook has this ID."),
+		},
+	},
+	"GET /webhooks/{id}/deliveries": {
+		Summary: "List webhook deliveries",
+		Params: []apiParam{
+			{Name: "status", In: "query", Type: "", Enum: []string{webhook.StatusPending, webhook.StatusDelivered, webhook.StatusFailed}, Description: "Only deliveries in this state; failed is the dead-letter list."},
+		},
+		Responses: map[int]apiResponse{
+			200: {Description: "Deliveries with their attempts.", Body: []webhook.Delivery{}},
+			400: problem("The status filter is invalid."),
+			404: problem("No webhook has this ID."),
+		},
+	},
+	"GET /openapi.json": {
+		Summary: "This document",
+		Responses: map[int]apiResponse{
+			200: {Description: "The OpenAPI description of the API.", Raw: []string{"application/json"}},
+		},
+	},
+	"GET /metrics": {
+		Summary: "Prometheus metrics",
+		Responses: map[int]apiResponse{
+			200: {Description: "Metrics in the Prometheus text exposition format.", Raw: []string{"text/plain"}},
+		},
+	},
+	"GET /healthz": {
+		Summary:     "Process health",
+		Description: "Answers 200 whenever the process can serve HTTP; it runs no checks.",
+		Responses: map[int]apiResponse{
+			200: {Description: "The process is up.", Body: health.Report{}},
+		},
+	},
+	"GET /livez": {
+		Summary:     "Liveness probe",
+		Description: "Runs the checks whose failure means the process should be restarted.",
+		Responses: map[int]apiResponse{
+			200: {Description: "Every liveness check passed.", Body: health.Report{}},
+			503: {Description: "A liveness check failed.", Body: health.Report{}},
+		},
+	},
+	"GET /readyz": {
+		Summary:     "Readiness probe",
+		Description: "Runs the checks whose failure means the server should not receive traffic, including whether it is draining for shutdown.",
+		Responses: map[int]apiResponse{
+			200: {Description: "Every readiness check passed, or the report is degraded because only checks of optional dependencies such as webhook persistence failed.", Body: health.Report{}},
+			503: {Description: "A readiness check failed.", Body: health.Report{}},
+		},
+	},
+	"GET /docs": {
+		Summary: "Interactive API documentation",
+		Responses: map[int]apiResponse{
+			200: {Description: "An HTML page rendering this document.", Raw: []string{"text/html"}},
+		},
+	},
+}
+
+const graphQLDescription = "Runs a query, mutation or subscription against the GraphQL schema, which is available by introspection. " +
+	"Errors in the operation are reported in the errors of a 200 response. Operations nested deeper than graphql.max_depth fail with code TOO_DEEP, " +
+	"and those more complex than graphql.max_complexity with TOO_COMPLEX: each field costs 1, and fields under a list cost once per item the list may hold. " +
+	"Clients that accept text/event-stream get each result as a next event followed by a complete event, as in the GraphQL over SSE protocol; subscriptions require it. " +
+	"When the server shuts down, streams get a complete event early; resume taskChanged by passing the last event ID as after."
+
+// graphQLResponses adds the responses common to both GraphQL routes.
+func graphQLResponses(responses map[int]apiResponse) map[int]apiResponse {
+	responses[200] = apiResponse{
+		Description: "The result, or a stream of results.",
+		Body:        graphQLResponse{},
+		Raw:         []string{"text/event-stream"},
+	}
+	responses[406] = problem("The operation is a subscription and text/event-stream is not accepted.")
+	return responses
+}
+
+// apiSchemas are documented even though no operation body refers to them.
+var apiSchemas = []any{socketMessage{}}
+
+// schemaEnums lists the values of named types that are enumerations.
+var schemaEnums = map[reflect.Type][]any{
+	reflect.TypeFor[model.Priority]():   {model.PriorityLow, model.PriorityMedium, model.PriorityHigh},
+	reflect.TypeFor[store.ChangeType](): {store.Created, store.Updated, store.Deleted},
+	reflect.TypeFor[store.OpKind]():     {store.OpCreate, store.OpUpdate, store.OpDelete},
+}
+
+// requiredFields overrides the required properties of structs whose fields
+// are optional on input even though they are always encoded.
+var requiredFields = map[reflect.Type][]string{
+	reflect.TypeFor[model.Task]():                  {"title"},
+	reflect.TypeFor[batchRequest]():                {"operations"},
+	reflect.TypeFor[batchOperation]():              {"op"},
+	reflect.TypeFor[calendarSubscriptionRequest](): {"name"},
+	reflect.TypeFor[webhookRequest]():              {"url"},
+	reflect.TypeFor[graphQLRequest]():              {"query"},
+}
+
+// readOnlyFields are set by the server and ignored in requests.
+var readOnlyFields = map[reflect.Type][]string{
+	reflect.TypeFor[model.Task]():           {"id", "created_at", "updated_at"},
+	reflect.TypeFor[webhook.Subscription](): {"id", "created_at"},
+}
+
+var timestampFields = map[string]bool{"created_at": true, "updated_at": true, "at": true, "next_attempt": true}
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+var openAPIDocument = sync.OnceValues(func() ([]byte, error) {
+	return json.MarshalIndent(openAPI(), "", "  ")
+})
+
+// OpenAPISpec serves the OpenAPI 3.1 description of every route.
+func OpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	doc, err := openAPIDocument()
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "failed to build the API description")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(doc)
+}
+
+// SwaggerUIVersion is the swagger-ui-dist release that the docs page loads
+// from unpkg. It is an exact version, so the files cannot change under the
+// page the way they could under a range.
+var SwaggerUIVersion = "5.17.14"
+
+// SwaggerUICSSIntegrity and SwaggerUIJSIntegrity are Subresource Integrity
+// hashes of that release's swagger-ui.css and swagger-ui-bundle.js, such as
+// "sha384-" followed by the output of openssl dgst -sha384 -binary FILE |
+// openssl base64 -A. When set, browsers refuse files that do not match.
+// Update them together with SwaggerUIVersion.
+var (
+	SwaggerUICSSIntegrity string
+	SwaggerUIJSIntegrity  string
+)
+
+// APIDocs serves an interactive explorer for the OpenAPI document.
+func APIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, docsPage,
+		swaggerUIAsset("href", "swagger-ui.css", SwaggerUICSSIntegrity),
+		swaggerUIAsset("src", "swagger-ui-bundle.js", SwaggerUIJSIntegrity))
+}
+
+// swaggerUIAsset returns the attributes loading file from the pinned
+// release, with integrity if it is set.
+func swaggerUIAsset(attr, file, integrity string) string {
+	url := "https://unpkg.com/swagger-ui-dist@" + SwaggerUIVersion + "/" + file
+	attrs := attr + `="` + html.EscapeString(url) + `"`
+	if integrity != "" {
+		attrs += ` integrity="` + html.EscapeString(integrity) + `" crossorigin="anonymous"`
+	}
+	return attrs
+}
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Task API</title>
+  <link rel="stylesheet" %s>
+</head>
+<body>
+  <div id="docs"></div>
+  <script %s></script>
+  <script>SwaggerUIBundle({url: "/openapi.json", dom_id: "#docs"});</script>
+</body>
+</html>
+`
+
+func openAPI() map[string]any {
+	schemas := schemaSet{}
+	paths := map[string]map[string]any{}
+	for pattern, op := range apiOperations {
+		method, path, _ := strings.Cut(pattern, " ")
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(method)] = schemas.operation(path, op)
+	}
+	for _, v := range apiSchemas {
+		schemas.of(reflect.TypeOf(v))
+	}
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "Task API",
+			"version":     "1.0.0",
+			"description": "Bodies documented as application/json can also be sent and requested as application/yaml, application/msgpack or text/csv using Content-Type and Accept. Errors are RFC 9457 problem details. Every route is rate limited and may answer 429. When the server verifies client certificates against a list of users, every route may answer 403; when it is configured with API tokens, requests with an unknown bearer token get 401.",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}
+}
+
+// schemaSet collects the component schemas referenced while building the
+// document, keyed by name.
+type schemaSet map[string]any
+
+func (s schemaSet) operation(path string, op apiOperation) map[string]any {
+	out := map[string]any{"summary": op.Summary}
+	if op.Description != "" {
+		out["description"] = op.Description
+	}
+
+	var params []any
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		params = append(params, map[string]any{
+			"name": m[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+		})
+	}
+	for _, p := range op.Params {
+		schema := s.of(reflect.TypeOf(p.Type))
+		if p.Enum != nil {
+			schema = map[string]any{"type": "string", "enum": p.Enum}
+		}
+		param := map[string]any{"name": p.Name, "in": p.In, "schema": schema}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		if p.Required {
+			param["required"] = true
+		}
+		params = append(params, param)
+	}
+	if params != nil {
+		out["parameters"] = params
+	}
+
+	responses := map[string]any{}
+	negotiated := false
+	for status, resp := range op.Responses {
+		body := map[string]any{"description": resp.Description}
+		switch {
+		case resp.Body != nil:
+			negotiated = true
+			content := rawContent(resp.Raw)
+			content["application/json"] = map[string]any{"schema": s.of(reflect.TypeOf(resp.Body))}
+			body["content"] = content
+		case resp.Problem:
+			body["content"] = s.problemContent()
+		case resp.Raw != nil:
+			body["content"] = rawContent(resp.Raw)
+		}
+		if resp.Headers != nil {
+			headers := map[string]any{}
+			for name, desc := range resp.Headers {
+				headers[name] = map[string]any{"description": desc, "schema": map[string]any{"type": "string"}}
+			}
+			body["headers"] = headers
+		}
+		responses[fmt.Sprint(status)] = body
+	}
+	common := map[int]apiResponse{
+		401: problem("The bearer token is not a known API token."),
+		403: problem("The client certificate is not mapped to a user."),
+		429: problem("The rate limit or daily quota is exhausted."),
+	}
+	if negotiated {
+		common[406] = problem("None of the accepted media types can be produced.")
+	}
+	switch {
+	case op.Body != nil:
+		out["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": s.of(reflect.TypeOf(op.Body))},
+			},
+		}
+		common[413] = problem("The body is too large.")
+		common[415] = problem("The Content-Type is not supported.")
+	case op.RawBody != nil:
+		out["requestBody"] = map[string]any{"required": true, "content": rawContent(op.RawBody)}
+		common[413] = problem("The body is too large.")
+	}
+	for status, resp := range common {
+		if _, ok := op.Responses[status]; !ok {
+			responses[fmt.Sprint(status)] = map[string]any{"description": resp.Description, "content": s.problemContent()}
+		}
+	}
+	out["responses"] = responses
+	return out
+}
+
+func (s schemaSet) problemContent() map[string]any {
+	return map[string]any{
+		"application/problem+json": map[string]any{"schema": s.of(reflect.TypeFor[errorResponse]())},
+	}
+}
+
+func rawContent(mediaTypes []string) map[string]any {
+	content := map[string]any{}
+	for _, mt := range mediaTypes {
+		schema := map[string]any{"type": "string"}
+		if mt == "application/json" {
+			schema["type"] = "object"
+		}
+		content[mt] = map[string]any{"schema": schema}
+	}
+	return content
+}
+
+// of returns the JSON Schema for t. Structs and enumerations are added to
+// the set and referenced by name.
+func (s schemaSet) of(t reflect.Type) map[string]any {
+	if values, ok := schemaEnums[t]; ok {
+		name := t.Name()
+		if _, ok := s[name]; !ok {
+			typ := "integer"
+			if t.Kind() == reflect.String {
+				typ = "string"
+			}
+			s[name] = map[string]any{"type": typ, "enum": values}
+		}
+		return ref(name)
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return s.of(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		if _, ok := s[name]; !ok {
+			s[name] = nil // reserve the name in case t refers to itself
+			s[name] = s.object(t)
+		}
+		return ref(name)
+	}
+	return map[string]any{}
+}
+
+func (s schemaSet) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	required := []string{}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		omitempty := strings.Contains(opts, "omitempty")
+
+		prop := s.of(f.Type)
+		if f.Type.Kind() == reflect.Pointer && !omitempty {
+			prop = map[string]any{"anyOf": []any{prop, map[string]any{"type": "null"}}}
+		}
+		if timestampFields[name] {
+			prop["format"] = "date-time"
+		}
+		for _, ro := range readOnlyFields[t] {
+			if ro == name {
+				prop["readOnly"] = true
+			}
+		}
+		props[name] = prop
+		if !omitempty {
+			required = append(required, name)
+		}
+	}
+	if r, ok := requiredFields[t]; ok {
+		required = r
+	}
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
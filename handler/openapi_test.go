@@ -0,0 +1,127 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/handler"
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+type openAPIDoc struct {
+	OpenAPI    string                                `json:"openapi"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func fetchOpenAPI(t *testing.T, mux http.Handler) (openAPIDoc, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var doc openAPIDoc
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid document: %v", err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Fatalf("expected OpenAPI 3.1.0, got %q", doc.OpenAPI)
+	}
+	return doc, w.Body.String()
+}
+
+func TestOpenAPIDescribesEveryRoute(t *testing.T) {
+	doc, _ := fetchOpenAPI(t, setupMux())
+
+	routes := map[string]bool{}
+	for _, rt := range handler.Routes {
+		method, path, _ := strings.Cut(rt.Pattern, " ")
+		routes[strings.ToLower(method)+" "+path] = true
+		if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("route %q is missing from the spec", rt.Pattern)
+		}
+	}
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			if !routes[method+" "+path] {
+				t.Errorf("spec documents %s %s, which is not a route", method, path)
+			}
+		}
+	}
+}
+
+func TestOpenAPIDescribesModelFields(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+	doc, body := fetchOpenAPI(t, mux)
+
+	typ := reflect.TypeFor[model.Task]()
+	for i := range typ.NumField() {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if _, ok := doc.Components.Schemas["Task"].Properties[name]; !ok {
+			t.Errorf("Task field %q is missing from the spec", name)
+		}
+	}
+
+	// The handlers' own response types are unexported, so compare the
+	// schemas against the fields of real responses instead.
+	responses := map[string]*http.Request{
+		"StatsResponse": httptest.NewRequest(http.MethodGet, "/stats", nil),
+		"ErrorResponse": httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"priority":7}`)),
+	}
+	for schema, req := range responses {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		var fields map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &fields); err != nil {
+			t.Fatalf("%s: %v", schema, err)
+		}
+		for name := range fields {
+			if _, ok := doc.Components.Schemas[schema].Properties[name]; !ok {
+				t.Errorf("%s field %q is missing from the spec", schema, name)
+			}
+		}
+	}
+
+	for _, ref := range strings.Split(body, `"$ref": "#/components/schemas/`)[1:] {
+		name, _, _ := strings.Cut(ref, `"`)
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("reference to undefined schema %q", name)
+		}
+	}
+}
+
+func TestAPIDocs(t *testing.T) {
+	w := httptest.NewRecorder()
+	setupMux().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), "/openapi.json") {
+		t.Fatalf("expected an HTML page loading /openapi.json, got %q", w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `src="https://unpkg.com/swagger-ui-dist@`+handler.SwaggerUIVersion+`/swagger-ui-bundle.js"></script>`) {
+		t.Fatalf("expected swagger-ui to be loaded from the pinned release, got %q", w.Body.String())
+	}
+
+	handler.SwaggerUIJSIntegrity = "sha384-test"
+	defer func() { handler.SwaggerUIJSIntegrity = "" }()
+	w = httptest.NewRecorder()
+	setupMux().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if !strings.Contains(w.Body.String(), `swagger-ui-bundle.js" integrity="sha384-test" crossorigin="anonymous"></script>`) {
+		t.Fatalf("expected the bundle to carry its integrity hash, got %q", w.Body.String())
+	}
+	if strings.Count(w.Body.String(), "integrity=") != 1 {
+		t.Fatalf("expected no integrity attribute without a hash, got %q", w.Body.String())
+	}
+}
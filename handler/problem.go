@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	problemTypeDefault    = "about:blank"
+	problemTypeValidation = "/problems/validation"
+)
+
+// validationErrors collects every field problem found in one request.
+type validationErrors []fieldError
+
+func (v validationErrors) Error() string {
+	details := make([]string, len(v))
+	for i, e := range v {
+		details[i] = e.Detail
+	}
+	return strings.Join(details, "; ")
+}
+
+// within returns the errors with their pointers moved under base, for errors
+// about an object nested in a larger request body.
+func (v validationErrors) within(base string) validationErrors {
+	out := make(validationErrors, len(v))
+	for i, e := range v {
+		e.Pointer = "#" + base + strings.TrimPrefix(e.Pointer, "#")
+		out[i] = e
+	}
+	return out
+}
+
+func newProblem(status int, detail string) errorResponse {
+	return errorResponse{
+		Type:   problemTypeDefault,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// validationProblem turns err into a 400 problem. Field pointers are
+// prefixed with base, so that errors about a nested object point into the
+// whole request body.
+func validationProblem(err error, base string) errorResponse {
+	var verrs validationErrors
+	if !errors.As(err, &verrs) {
+		return newProblem(http.StatusBadRequest, err.Error())
+	}
+	p := newProblem(http.StatusBadRequest, "the request body has invalid fields")
+	p.Type = problemTypeValidation
+	p.Title = "Validation failed"
+	p.Errors = verrs.within(base)
+	return p
+}
@@ -0,0 +1,105 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail"`
+	Instance string `json:"instance"`
+	Errors   []struct {
+		Pointer string `json:"pointer"`
+		Code    string `json:"code"`
+	} `json:"errors"`
+}
+
+func TestValidationReportsEveryField(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+
+	body := `{"priority":9,"due":"next week"}`
+	req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("expected application/problem+json, got %q", ct)
+	}
+	var p problem
+	json.NewDecoder(w.Body).Decode(&p)
+	if p.Status != http.StatusBadRequest || p.Instance != "/tasks" || p.Type == "" {
+		t.Fatalf("unexpected problem %+v", p)
+	}
+	want := []string{"#/title:required", "#/priority:out_of_range", "#/due:invalid_format"}
+	if len(p.Errors) != len(want) {
+		t.Fatalf("expected %d field errors, got %+v", len(want), p.Errors)
+	}
+	for i, e := range p.Errors {
+		if e.Pointer+":"+e.Code != want[i] {
+			t.Fatalf("expected %s, got %s:%s", want[i], e.Pointer, e.Code)
+		}
+	}
+}
+
+func TestTypeMismatchPointsAtField(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+
+	req := httptest.NewRequest(http.MethodPut, "/tasks/1", strings.NewReader(`{"title":"x","priority":"high"}`))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var p problem
+	json.NewDecoder(w.Body).Decode(&p)
+	if w.Code != http.StatusBadRequest || len(p.Errors) != 1 || p.Errors[0].Pointer != "#/priority" {
+		t.Fatalf("expected a priority type error, got %d %+v", w.Code, p)
+	}
+}
+
+func TestNotFoundProblem(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks/999", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var p problem
+	json.NewDecoder(w.Body).Decode(&p)
+	if p.Type != "about:blank" || p.Title != "Not Found" || p.Detail != "task not found" || p.Instance != "/tasks/999" {
+		t.Fatalf("unexpected problem %+v", p)
+	}
+}
+
+func TestBatchProblemPointsIntoRequest(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+
+	body := `{"operations":[{"op":"create","task":{"title":"ok"}},{"op":"create","task":{"priority":7}}]}`
+	req := httptest.NewRequest(http.MethodPost, "/tasks:batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var resp struct {
+		Results []struct {
+			Error *problem `json:"error"`
+		} `json:"results"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	p := resp.Results[1].Error
+	if p == nil || len(p.Errors) != 2 || p.Errors[0].Pointer != "#/operations/1/task/title" {
+		t.Fatalf("unexpected batch problem %+v", p)
+	}
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/ratelimit"
+)
+
+// RateLimitConfig sets the per-client budgets enforced by RateLimit. Rates
+// are in requests per second; bursts are the bucket sizes. The default daily
+// create quota fits one batch of MaxBatchSize creates, so that a migration
+// can be a single request.
+type RateLimitConfig struct {
+	ReadRate         float64
+	ReadBurst        int
+	WriteRate        float64
+	WriteBurst       int
+	DailyCreateQuota int
+}
+
+var DefaultRateLimitConfig = RateLimitConfig{
+	ReadRate:         20,
+	ReadBurst:        40,
+	WriteRate:        5,
+	WriteBurst:       10,
+	DailyCreateQuota: 10000,
+}
+
+// RateLimiter holds the per-client budgets of a RateLimitConfig. One
+// limiter is shared by the HTTP and gRPC servers, so that a client has the
+// same budget whichever API it uses.
+type RateLimiter struct {
+	reads   *ratelimit.Limiter
+	writes  *ratelimit.Limiter
+	creates *ratelimit.Quota
+}
+
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+	return &RateLimiter{
+		reads:   ratelimit.New(cfg.ReadRate, cfg.ReadBurst),
+		writes:  ratelimit.New(cfg.WriteRate, cfg.WriteBurst),
+		creates: ratelimit.NewQuota(cfg.DailyCreateQuota),
+	}
+}
+
+// Allow takes a request by the client key from its read or write budget.
+func (l *RateLimiter) Allow(key string, write bool) ratelimit.Result {
+	if write {
+		return l.writes.Allow(key)
+	}
+	return l.reads.Allow(key)
+}
+
+type clientKeyCtx struct{}
+
+type rateLimitClient struct {
+	limiter *RateLimiter
+	key     string
+}
+
+// WithClient returns a copy of ctx in which ChargeCreates draws from the
+// daily create quota of the client key, and chargeWrite from its write
+// budget.
+func (l *RateLimiter) WithClient(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, clientKeyCtx{}, rateLimitClient{l, key})
+}
+
+// ChargeCreates takes n tasks from the daily create quota of the client in
+// ctx, or none if they do not all fit. Every path that creates tasks calls
+// it first. Without a client in ctx, creation is not limited.
+func ChargeCreates(ctx context.Context, n int) ratelimit.Result {
+	c, ok := ctx.Value(clientKeyCtx{}).(rateLimitClient)
+	if !ok || n == 0 {
+		return ratelimit.Result{Allowed: true}
+	}
+	return c.limiter.creates.TakeN(c.key, n)
+}
+
+// refundCreates gives n creations charged with ChargeCreates back to the
+// client in ctx, when a rolled back batch did not create them after all.
+func refundCreates(ctx context.Context, n int) {
+	if c, ok := ctx.Value(clientKeyCtx{}).(rateLimitClient); ok && n > 0 {
+		c.limiter.creates.Refund(c.key, n)
+	}
+}
+
+// chargeWrite takes a write from the budget of the client in ctx, for
+// writes that do not arrive as requests of their own, such as WebSocket
+// commands.
+func chargeWrite(ctx context.Context) ratelimit.Result {
+	c, ok := ctx.Value(clientKeyCtx{}).(rateLimitClient)
+	if !ok {
+		return ratelimit.Result{Allowed: true}
+	}
+	return c.limiter.Allow(c.key, true)
+}
+
+// RateLimitKey identifies a client for rate limiting: by the user of its
+// verified client certificate or API token, or by its IP address.
+// Credentials that have not been verified are never used, so that clients
+// cannot get a fresh budget by making one up.
+func RateLimitKey(user, remoteAddr string) string {
+	if user != "" {
+		return "user:" + user
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return "ip:" + host
+}
+
+// RateLimit limits requests per client, as identified by RateLimitKey.
+// Reads and writes draw from separate buckets, and task creation is
+// additionally capped per client per day through ChargeCreates.
+func RateLimit(l *RateLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := clientKey(r)
+		res := l.Allow(key, isWrite(r.Method))
+		setRateLimitHeaders(w, res)
+		if !res.Allowed {
+			w.Header().Set("Retry-After", seconds(res.RetryAfter))
+			writeError(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(l.WithClient(r.Context(), key)))
+	})
+}
+
+// chargeCreates charges n creations to the client of r, answering 429 and
+// returning false if its daily quota does not allow them.
+func chargeCreates(w http.ResponseWriter, r *http.Request, n int) bool {
+	quota := ChargeCreates(r.Context(), n)
+	if quota.Allowed {
+		return true
+	}
+	setRateLimitHeaders(w, quota)
+	w.Header().Set("Retry-After", seconds(quota.RetryAfter))
+	writeError(w, r, http.StatusTooManyRequests, "daily task creation quota exceeded")
+	return false
+}
+
+func clientKey(r *http.Request) string {
+	return RateLimitKey(User(r.Context()), r.RemoteAddr)
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, res ratelimit.Result) {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("RateLimit-Reset", seconds(res.Reset))
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
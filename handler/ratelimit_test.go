@@ -0,0 +1,172 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/handler"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+func TestRateLimitSeparatesReadsAndWrites(t *testing.T) {
+	store.Clear()
+	h := handler.RateLimit(handler.NewRateLimiter(handler.RateLimitConfig{
+		ReadRate: 1, ReadBurst: 2,
+		WriteRate: 1, WriteBurst: 1,
+		DailyCreateQuota: 100,
+	}), setupMux())
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatal("expected Retry-After header")
+	}
+	if w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("expected RateLimit-Remaining 0, got %q", w.Header().Get("RateLimit-Remaining"))
+	}
+	var problem struct {
+		Title  string `json:"title"`
+		Status int    `json:"status"`
+	}
+	json.NewDecoder(w.Body).Decode(&problem)
+	if problem.Title != "Too Many Requests" || problem.Status != http.StatusTooManyRequests {
+		t.Fatalf("expected problem details body, got %+v", problem)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"title":"Write"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected writes to use their own budget, got %d", w.Code)
+	}
+}
+
+func TestRateLimitIgnoresUnverifiedTokens(t *testing.T) {
+	store.Clear()
+	h := handler.RateLimit(handler.NewRateLimiter(handler.RateLimitConfig{
+		ReadRate: 1, ReadBurst: 1,
+		WriteRate: 1, WriteBurst: 1,
+		DailyCreateQuota: 100,
+	}), setupMux())
+
+	codes := []int{}
+	for _, token := range []string{"Bearer alice", "Bearer bob"} {
+		req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Fatalf("expected made-up tokens to share the IP's budget, got %v", codes)
+	}
+}
+
+func TestRateLimitKeysByVerifiedToken(t *testing.T) {
+	store.Clear()
+	h := handler.TokenAuth(tokenDigests("alice-token", "alice", "bob-token", "bob"), handler.RateLimit(handler.NewRateLimiter(handler.RateLimitConfig{
+		ReadRate: 1, ReadBurst: 1,
+		WriteRate: 1, WriteBurst: 1,
+		DailyCreateQuota: 100,
+	}), setupMux()))
+
+	codes := []int{}
+	for _, token := range []string{"Bearer alice-token", "Bearer bob-token", "Bearer alice-token", "Bearer made-up"} {
+		req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+	want := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusUnauthorized}
+	if !slices.Equal(codes, want) {
+		t.Fatalf("expected each token its own budget and unknown tokens refused, got %v", codes)
+	}
+}
+
+func TestDailyCreateQuota(t *testing.T) {
+	store.Clear()
+	h := handler.RateLimit(handler.NewRateLimiter(handler.RateLimitConfig{
+		ReadRate: 100, ReadBurst: 100,
+		WriteRate: 100, WriteBurst: 100,
+		DailyCreateQuota: 1,
+	}), setupMux())
+
+	req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"title":"First"}`))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"title":"Second"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the quota is used, got %d", w.Code)
+	}
+}
+
+func TestDailyCreateQuotaCountsEveryCreate(t *testing.T) {
+	store.Clear()
+	h := handler.RateLimit(handler.NewRateLimiter(handler.RateLimitConfig{
+		ReadRate: 100, ReadBurst: 100,
+		WriteRate: 100, WriteBurst: 100,
+		DailyCreateQuota: 3,
+	}), setupMux())
+
+	// A rolled back batch does not use up the quota.
+	rolledBack := `{"atomic":true,"operations":[{"op":"create","task":{"title":"A"}},{"op":"create","task":{"title":"B"}},{"op":"delete","id":"404"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/tasks:batch", strings.NewReader(rolledBack))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusMultiStatus || store.Count() != 0 {
+		t.Fatalf("expected the batch to be rolled back, got %d with %d tasks", w.Code, store.Count())
+	}
+
+	batch := `{"operations":[{"op":"create","task":{"title":"A"}},{"op":"create","task":{"title":"B"}}]}`
+	req = httptest.NewRequest(http.MethodPost, "/tasks:batch", strings.NewReader(batch))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("expected 207, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/tasks/import", strings.NewReader("title\nC\nD\n"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected an import over the quota to be refused, got %d", w.Code)
+	}
+
+	body := `{"query":"mutation { createTask(input: {title: \"C\"}) { id } }"}`
+	req = httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	req = httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), "QUOTA_EXCEEDED") {
+		t.Fatalf("expected the mutation to exceed the quota, got %s", w.Body)
+	}
+	if store.Count() != 3 {
+		t.Errorf("expected three tasks, got %d", store.Count())
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import "net/http"
+
+// Route is an endpoint of the API: a ServeMux pattern and its handler.
+type Route struct {
+	Pattern string
+	Handler http.HandlerFunc
+}
+
+// Routes lists every endpoint the server exposes. Each one must be
+// described in the OpenAPI document served by OpenAPISpec.
+var Routes = []Route{
+	{"GET /tasks", ListTasks},
+	{"POST /tasks", Idempotent(CreateTask)},
+	{"GET /tasks/{id}", GetTask},
+	{"PUT /tasks/{id}", UpdateTask},
+	{"DELETE /tasks/{id}", DeleteTask},
+	{"POST /tasks:batch", BatchTasks},
+	{"GET /tasks/export", ExportTasks},
+	{"POST /tasks/import", ImportTasks},
+	{"GET /tasks.ics", CalendarFeed},
+	{"POST /calendar/subscriptions", CreateCalendarSubscription},
+	{"GET /calendar/subscriptions", ListCalendarSubscriptions},
+	{"DELETE /calendar/subscriptions/{id}", DeleteCalendarSubscription},
+	{"GET /stats", TaskStats},
+	{"GET /events", StreamEvents},
+	{"GET /ws", TaskSocket},
+	{"GET /graphql", GraphQL},
+	{"POST /graphql", GraphQL},
+	{"POST /webhooks", CreateWebhook},
+	{"GET /webhooks", ListWebhooks},
+	{"GET /webhooks/{id}", GetWebhook},
+	{"DELETE /webhooks/{id}", DeleteWebhook},
+	{"GET /webhooks/{id}/deliveries", ListWebhookDeliveries},
+	{"GET /openapi.json", OpenAPISpec},
+	{"GET /docs", APIDocs},
+	{"GET /metrics", Metrics},
+	{"GET /healthz", Healthz},
+	{"GET /livez", Livez},
+	{"GET /readyz", Readyz},
+}
+
+// Register adds every route in Routes to mux.
+func Register(mux *http.ServeMux) {
+	for _, rt := range Routes {
+		mux.HandleFunc(rt.Pattern, rt.Handler)
+	}
+}
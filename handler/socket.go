@@ -0,0 +1,370 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sawez-deepsource/demo-go/events"
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+const (
+	socketWriteWait  = 10 * time.Second
+	socketPongWait   = 60 * time.Second
+	socketPingPeriod = socketPongWait * 9 / 10
+	socketMaxMessage = 64 << 10
+	// socketSendBuffer is how many outgoing messages may queue for a
+	// connection before the client is considered too slow and disconnected.
+	socketSendBuffer = 64
+)
+
+// socketMessage is the single envelope used in both directions. Clients send
+// subscribe, unsubscribe, create, update and delete; the server answers each
+// with a result or error carrying the same ID, and pushes event messages for
+// the active subscription.
+type socketMessage struct {
+	Type   string         `json:"type"`
+	ID     string         `json:"id,omitempty"`
+	TaskID string         `json:"task_id,omitempty"`
+	Task   *model.Task    `json:"task,omitempty"`
+	Tasks  []model.Task   `json:"tasks,omitempty"`
+	Filter *events.Filter `json:"filter,omitempty"`
+	After  uint64         `json:"after,omitempty"`
+	Event  *events.Event  `json:"event,omitempty"`
+	Error  *errorResponse `json:"error,omitempty"`
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+var (
+	socketsMu sync.Mutex
+	sockets   = map[*socket]struct{}{}
+)
+
+type socket struct {
+	// ctx is the upgrade request's context, which carries its request ID.
+	ctx  context.Context
+	conn *websocket.Conn
+	out  chan socketMessage
+	done chan struct{}
+	once sync.Once
+
+	// goingAway is set when the server shuts down. The read loop then
+	// stops, and draining tells writeLoop to send what is queued and
+	// return, closing written.
+	goingAway atomic.Bool
+	draining  chan struct{}
+	written   chan struct{}
+
+	mu  sync.Mutex
+	sub *events.Subscription
+}
+
+// TaskSocket upgrades the request to a WebSocket speaking the task board
+// protocol described on socketMessage. Each create, update and delete
+// command draws from the client's write budget, like a request would.
+func TaskSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an error response.
+		return
+	}
+	s := &socket{
+		ctx:      r.Context(),
+		conn:     conn,
+		out:      make(chan socketMessage, socketSendBuffer),
+		done:     make(chan struct{}),
+		draining: make(chan struct{}),
+		written:  make(chan struct{}),
+	}
+	socketsMu.Lock()
+	sockets[s] = struct{}{}
+	socketsMu.Unlock()
+	defer func() {
+		if !s.goingAway.Load() {
+			s.close(websocket.CloseNormalClosure, "")
+			return
+		}
+		// Replies to the last command are queued; send them first.
+		close(s.draining)
+		<-s.written
+		s.close(websocket.CloseGoingAway, "server shutting down")
+	}()
+
+	go s.writeLoop()
+
+	conn.SetReadLimit(socketMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	conn.SetPongHandler(func(string) error {
+		if s.goingAway.Load() {
+			return nil
+		}
+		return conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg socketMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			s.sendError("", http.StatusBadRequest, "invalid json payload")
+			continue
+		}
+		s.handle(msg)
+	}
+}
+
+// ShutdownWebSockets closes every connection once the command it is handling,
+// if any, has been answered, telling the client that the server is going
+// away. It is needed because http.Server.Shutdown does not track hijacked
+// connections. It returns once all are closed; when ctx is done first, the
+// rest are closed at once with CloseWebSockets and an error counts them.
+func ShutdownWebSockets(ctx context.Context) error {
+	err := untilClosed(ctx, "websocket connections", func() int {
+		socketsMu.Lock()
+		defer socketsMu.Unlock()
+		for s := range sockets {
+			s.goingAway.Store(true)
+			// Wake the read loop.
+			s.conn.SetReadDeadline(time.Now())
+		}
+		return len(sockets)
+	})
+	if err != nil {
+		CloseWebSockets()
+	}
+	return err
+}
+
+// CloseWebSockets tells every connected client that the server is going away
+// and closes the connections immediately.
+func CloseWebSockets() {
+	socketsMu.Lock()
+	open := make([]*socket, 0, len(sockets))
+	for s := range sockets {
+		open = append(open, s)
+	}
+	socketsMu.Unlock()
+	for _, s := range open {
+		s.close(websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+func (s *socket) handle(msg socketMessage) {
+	switch msg.Type {
+	case "subscribe":
+		var filter events.Filter
+		if msg.Filter != nil {
+			filter = *msg.Filter
+		}
+		s.subscribe(filter, msg.After)
+		matching := make([]model.Task, 0)
+		for _, t := range store.AllContext(s.ctx) {
+			if filter.Match(t) {
+				matching = append(matching, t)
+			}
+		}
+		s.send(socketMessage{Type: "result", ID: msg.ID, Tasks: matching})
+	case "unsubscribe":
+		s.unsubscribe()
+		s.send(socketMessage{Type: "result", ID: msg.ID})
+	case "create", "update", "delete":
+		if res := chargeWrite(s.ctx); !res.Allowed {
+			s.sendError(msg.ID, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		s.write(msg)
+	default:
+		s.sendError(msg.ID, http.StatusBadRequest, "unknown message type")
+	}
+}
+
+// write runs a create, update or delete command.
+func (s *socket) write(msg socketMessage) {
+	switch msg.Type {
+	case "create":
+		if msg.Task == nil {
+			s.sendError(msg.ID, http.StatusBadRequest, "task is required")
+			return
+		}
+		if err := validateTask(*msg.Task); err != nil {
+			s.sendValidationError(msg.ID, err)
+			return
+		}
+		if !ChargeCreates(s.ctx, 1).Allowed {
+			s.sendError(msg.ID, http.StatusTooManyRequests, "daily task creation quota exceeded")
+			return
+		}
+		created := store.AddContext(s.ctx, *msg.Task)
+		slog.InfoContext(s.ctx, "task created", "id", created.ID, "title", created.Title)
+		s.send(socketMessage{Type: "result", ID: msg.ID, Task: &created})
+	case "update":
+		if msg.Task == nil {
+			s.sendError(msg.ID, http.StatusBadRequest, "task is required")
+			return
+		}
+		if err := validateTask(*msg.Task); err != nil {
+			s.sendValidationError(msg.ID, err)
+			return
+		}
+		updated, ok := store.UpdateContext(s.ctx, msg.TaskID, *msg.Task)
+		if !ok {
+			s.sendError(msg.ID, http.StatusNotFound, "task not found")
+			return
+		}
+		slog.InfoContext(s.ctx, "task updated", "id", updated.ID, "title", updated.Title)
+		s.send(socketMessage{Type: "result", ID: msg.ID, Task: &updated})
+	case "delete":
+		if !store.DeleteContext(s.ctx, msg.TaskID) {
+			s.sendError(msg.ID, http.StatusNotFound, "task not found")
+			return
+		}
+		slog.InfoContext(s.ctx, "task deleted", "id", msg.TaskID)
+		s.send(socketMessage{Type: "result", ID: msg.ID})
+	}
+}
+
+// subscribe replaces the connection's subscription. Without after, only
+// changes from now on are delivered. If the events after it are no longer
+// buffered none are replayed, and the snapshot in the result stands in for
+// them.
+func (s *socket) subscribe(filter events.Filter, after uint64) {
+	if after == 0 {
+		after = events.LastID()
+	}
+	backlog, sub := events.Subscribe(after)
+	if sub.Missed {
+		backlog = nil
+	}
+
+	s.mu.Lock()
+	if s.sub != nil {
+		events.Cancel(s.sub)
+	}
+	s.sub = sub
+	s.mu.Unlock()
+
+	go func() {
+		for _, e := range backlog {
+			s.sendEvent(e, filter)
+		}
+		for e := range sub.C {
+			// Events still buffered after a resubscribe belong to the
+			// old filter.
+			if !s.subscribed(sub) {
+				return
+			}
+			s.sendEvent(e, filter)
+		}
+		if s.subscribed(sub) {
+			s.close(websocket.CloseTryAgainLater, "too slow to keep up with events")
+		}
+	}()
+}
+
+func (s *socket) subscribed(sub *events.Subscription) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sub == sub
+}
+
+func (s *socket) unsubscribe() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sub != nil {
+		events.Cancel(s.sub)
+		s.sub = nil
+	}
+}
+
+// sendEvent sends e if it concerns filter, as a removed event if an update
+// took its task out of the filter.
+func (s *socket) sendEvent(e events.Event, filter events.Filter) {
+	if e, ok := filter.Select(e); ok {
+		s.send(socketMessage{Type: "event", Event: &e})
+	}
+}
+
+func (s *socket) sendError(id string, status int, message string) {
+	p := newProblem(status, message)
+	s.send(socketMessage{Type: "error", ID: id, Error: &p})
+}
+
+func (s *socket) sendValidationError(id string, err error) {
+	p := validationProblem(err, "/task")
+	s.send(socketMessage{Type: "error", ID: id, Error: &p})
+}
+
+// send queues msg without blocking. A client that lets its queue fill up is
+// disconnected rather than allowed to stall the server.
+func (s *socket) send(msg socketMessage) {
+	select {
+	case <-s.done:
+	case s.out <- msg:
+	default:
+		s.close(websocket.CloseTryAgainLater, "too slow to keep up")
+	}
+}
+
+func (s *socket) writeLoop() {
+	defer close(s.written)
+	ping := time.NewTicker(socketPingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.draining:
+			for {
+				select {
+				case msg := <-s.out:
+					s.conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+					if s.conn.WriteJSON(msg) != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		case msg := <-s.out:
+			s.conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+			if err := s.conn.WriteJSON(msg); err != nil {
+				s.close(websocket.CloseInternalServerErr, "")
+				return
+			}
+		case <-ping.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait)); err != nil {
+				s.close(websocket.CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
+
+// close sends a close frame with code and reason, then tears the connection
+// down. Only the first call has any effect.
+func (s *socket) close(code int, reason string) {
+	s.once.Do(func() {
+		close(s.done)
+		s.unsubscribe()
+		socketsMu.Lock()
+		delete(sockets, s)
+		socketsMu.Unlock()
+		msg := websocket.FormatCloseMessage(code, reason)
+		s.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(socketWriteWait))
+		s.conn.Close()
+	})
+}
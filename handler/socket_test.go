@@ -0,0 +1,186 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sawez-deepsource/demo-go/handler"
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+type socketMessage struct {
+	Type   string       `json:"type"`
+	ID     string       `json:"id,omitempty"`
+	TaskID string       `json:"task_id,omitempty"`
+	Task   *model.Task  `json:"task,omitempty"`
+	Tasks  []model.Task `json:"tasks,omitempty"`
+	Filter any          `json:"filter,omitempty"`
+	Event  *struct {
+		ID   uint64     `json:"id"`
+		Type string     `json:"type"`
+		Task model.Task `json:"task"`
+	} `json:"event,omitempty"`
+	Error *struct {
+		Status int `json:"status"`
+		Errors []struct {
+			Pointer string `json:"pointer"`
+		} `json:"errors"`
+	} `json:"error,omitempty"`
+}
+
+func dialSocket(t *testing.T) *websocket.Conn {
+	t.Helper()
+	return dialSocketTo(t, setupMux())
+}
+
+func dialSocketTo(t *testing.T, h http.Handler) *websocket.Conn {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dialing websocket: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func roundTrip(t *testing.T, conn *websocket.Conn, msg socketMessage) socketMessage {
+	t.Helper()
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatalf("writing message: %v", err)
+	}
+	for {
+		var reply socketMessage
+		if err := conn.ReadJSON(&reply); err != nil {
+			t.Fatalf("reading reply: %v", err)
+		}
+		if reply.ID == msg.ID && reply.Type != "event" {
+			return reply
+		}
+	}
+}
+
+func TestSocketSubscribeAndCreate(t *testing.T) {
+	store.Clear()
+	store.Add(model.NewTask("Existing", "desc", model.PriorityHigh))
+	conn := dialSocket(t)
+
+	reply := roundTrip(t, conn, socketMessage{Type: "subscribe", ID: "1", Filter: map[string]any{"priority": 2}})
+	if reply.Type != "result" || len(reply.Tasks) != 1 {
+		t.Fatalf("expected snapshot with 1 task, got %+v", reply)
+	}
+
+	low := model.NewTask("Low", "desc", model.PriorityLow)
+	if reply := roundTrip(t, conn, socketMessage{Type: "create", ID: "2", Task: &low}); reply.Type != "result" {
+		t.Fatalf("expected result, got %+v", reply)
+	}
+	high := model.NewTask("High", "desc", model.PriorityHigh)
+	reply = roundTrip(t, conn, socketMessage{Type: "create", ID: "3", Task: &high})
+	if reply.Type != "result" || reply.Task == nil || reply.Task.ID == "" {
+		t.Fatalf("expected created task, got %+v", reply)
+	}
+
+	var event socketMessage
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatalf("reading event: %v", err)
+	}
+	if event.Type != "event" || event.Event.Type != "created" || event.Event.Task.Title != "High" {
+		t.Fatalf("expected created event for high task only, got %+v", event)
+	}
+
+	moved := *reply.Task
+	moved.Priority = model.PriorityLow
+	store.Update(moved.ID, moved)
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatalf("reading event: %v", err)
+	}
+	if event.Type != "event" || event.Event.Type != "removed" || event.Event.Task.ID != moved.ID {
+		t.Fatalf("expected the task to leave the subscription, got %+v", event)
+	}
+}
+
+func TestSocketChargesWrites(t *testing.T) {
+	store.Clear()
+	conn := dialSocketTo(t, handler.RateLimit(handler.NewRateLimiter(handler.RateLimitConfig{
+		ReadRate: 100, ReadBurst: 100,
+		WriteRate: 0.001, WriteBurst: 1,
+		DailyCreateQuota: 100,
+	}), setupMux()))
+
+	first := model.NewTask("First", "desc", model.PriorityLow)
+	if reply := roundTrip(t, conn, socketMessage{Type: "create", ID: "1", Task: &first}); reply.Type != "result" {
+		t.Fatalf("expected result, got %+v", reply)
+	}
+	second := model.NewTask("Second", "desc", model.PriorityLow)
+	reply := roundTrip(t, conn, socketMessage{Type: "create", ID: "2", Task: &second})
+	if reply.Type != "error" || reply.Error.Status != http.StatusTooManyRequests {
+		t.Fatalf("expected the second write to be rate limited, got %+v", reply)
+	}
+	if store.Count() != 1 {
+		t.Errorf("expected one task, got %d", store.Count())
+	}
+}
+
+func TestSocketValidatesCommands(t *testing.T) {
+	store.Clear()
+	conn := dialSocket(t)
+
+	bad := model.Task{Title: "Bad Priority", Priority: 5}
+	reply := roundTrip(t, conn, socketMessage{Type: "create", ID: "1", Task: &bad})
+	if reply.Type != "error" || reply.Error.Status != 400 || reply.Error.Errors[0].Pointer != "#/task/priority" {
+		t.Fatalf("expected validation error, got %+v", reply)
+	}
+
+	reply = roundTrip(t, conn, socketMessage{Type: "delete", ID: "2", TaskID: "999"})
+	if reply.Type != "error" || reply.Error.Status != 404 {
+		t.Fatalf("expected not found error, got %+v", reply)
+	}
+}
+
+func TestCloseWebSockets(t *testing.T) {
+	conn := dialSocket(t)
+	roundTrip(t, conn, socketMessage{Type: "unsubscribe", ID: "1"})
+
+	handler.CloseWebSockets()
+
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("expected going away close, got %v", err)
+	}
+}
+
+func TestShutdownWebSockets(t *testing.T) {
+	store.Clear()
+	conn := dialSocket(t)
+	task := model.NewTask("Last words", "desc", model.PriorityLow)
+	if err := conn.WriteJSON(socketMessage{Type: "create", ID: "1", Task: &task}); err != nil {
+		t.Fatal(err)
+	}
+	for store.Count() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := handler.ShutdownWebSockets(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var reply socketMessage
+	if err := conn.ReadJSON(&reply); err != nil || reply.Type != "result" || reply.ID != "1" {
+		t.Fatalf("expected the command in progress to be answered, got %+v (%v)", reply, err)
+	}
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("expected going away close, got %v", err)
+	}
+}
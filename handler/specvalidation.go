@@ -0,0 +1,441 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/metrics"
+)
+
+// SpecValidationConfig selects what SpecValidation checks against the
+// OpenAPI document.
+type SpecValidationConfig struct {
+	// Requests rejects requests whose parameters or JSON body break the
+	// spec with a 400 validation problem.
+	Requests bool
+	// Responses replaces responses that break the spec with a 500 problem
+	// listing the violations. Every response is buffered to check it, so
+	// this is meant for tests.
+	Responses bool
+}
+
+// SpecValidation checks traffic on the routes in the OpenAPI document,
+// counting violations in metrics.SpecViolations. Unknown routes, non-JSON
+// bodies and streaming responses pass through.
+func SpecValidation(cfg SpecValidationConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		spec, err := loadSpec()
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "failed to load the API description")
+			return
+		}
+		op, pattern := spec.match(r)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if cfg.Requests {
+			if p := spec.checkRequest(w, r, op, pattern); p != nil {
+				metrics.SpecViolations.WithLabelValues("request", pattern).Inc()
+				writeProblem(w, r, *p)
+				return
+			}
+		}
+
+		if !cfg.Responses || op.streaming(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if errs := spec.checkResponse(op, rec); len(errs) > 0 {
+			metrics.SpecViolations.WithLabelValues("response", pattern).Inc()
+			slog.WarnContext(r.Context(), "response breaks the spec", "method", r.Method, "path", r.URL.Path, "errors", errs)
+			p := newProblem(http.StatusInternalServerError, fmt.Sprintf("the %d response does not match the API description", rec.status))
+			p.Errors = errs
+			writeProblem(w, r, p)
+			return
+		}
+		rec.copyTo(w)
+	})
+}
+
+// bufferedResponse holds a response until it has been checked.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if !b.wrote {
+		b.status, b.wrote = status, true
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) copyTo(w http.ResponseWriter) {
+	for k, v := range b.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
+
+// apiSpec is the served OpenAPI document, decoded for validation.
+type apiSpec struct {
+	Paths      map[string]map[string]*specOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]any `json:"schemas"`
+	} `json:"components"`
+	router *http.ServeMux
+}
+
+type specOperation struct {
+	Parameters  []specParameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]specMedia `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]specMedia `json:"content"`
+	} `json:"responses"`
+}
+
+type specParameter struct {
+	Name     string `json:"name"`
+	In       string `json:"in"`
+	Required bool   `json:"required"`
+	Schema   any    `json:"schema"`
+}
+
+type specMedia struct {
+	Schema any `json:"schema"`
+}
+
+var loadSpec = sync.OnceValues(func() (*apiSpec, error) {
+	doc, err := openAPIDocument()
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var s apiSpec
+	if err := dec.Decode(&s); err != nil {
+		return nil, err
+	}
+	// A mux of no-op handlers finds the operation a request is for, using
+	// the same matching rules as the real one.
+	s.router = http.NewServeMux()
+	for path, ops := range s.Paths {
+		for method := range ops {
+			s.router.HandleFunc(strings.ToUpper(method)+" "+path, func(http.ResponseWriter, *http.Request) {})
+		}
+	}
+	return &s, nil
+})
+
+func (s *apiSpec) match(r *http.Request) (*specOperation, string) {
+	_, pattern := s.router.Handler(r)
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		return nil, ""
+	}
+	return s.Paths[path][strings.ToLower(method)], pattern
+}
+
+// streaming reports whether the operation answers r with a stream that
+// cannot be buffered: always for upgrades and event streams, and for
+// operations that also answer JSON when r accepts an event stream.
+func (op *specOperation) streaming(r *http.Request) bool {
+	if _, ok := op.Responses["101"]; ok {
+		return true
+	}
+	content := op.Responses["200"].Content
+	if _, ok := content["text/event-stream"]; !ok {
+		return false
+	}
+	_, alt := content["application/json"]
+	return !alt || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+func (s *apiSpec) checkRequest(w http.ResponseWriter, r *http.Request, op *specOperation, pattern string) *errorResponse {
+	var errs validationErrors
+	_, path, _ := strings.Cut(pattern, " ")
+	for _, p := range op.Parameters {
+		var value string
+		var present bool
+		switch p.In {
+		case "path":
+			value, present = pathValue(path, r.URL.Path, p.Name), true
+		case "query":
+			value, present = r.URL.Query().Get(p.Name), r.URL.Query().Has(p.Name)
+		case "header":
+			value = r.Header.Get(p.Name)
+			present = value != ""
+		}
+		if !present {
+			if p.Required {
+				errs = append(errs, fieldError{Parameter: p.Name, Code: "required", Detail: p.Name + " is required"})
+			}
+			continue
+		}
+		for _, e := range s.check(p.Schema, s.parseParam(p.Schema, value), "", p.Name, true) {
+			e.Parameter, e.Pointer = p.Name, ""
+			errs = append(errs, e)
+		}
+	}
+	if len(errs) > 0 {
+		p := newProblem(http.StatusBadRequest, "the request has invalid parameters")
+		p.Type = problemTypeValidation
+		p.Title = "Validation failed"
+		p.Errors = errs
+		return &p
+	}
+
+	if op.RequestBody == nil {
+		return nil
+	}
+	// Bodies in every format are limited; only JSON ones are checked.
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if !isJSON(r.Header.Get("Content-Type"), true) {
+		return nil
+	}
+	media, ok := op.RequestBody.Content["application/json"]
+	if !ok {
+		return nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		p := newProblem(http.StatusRequestEntityTooLarge, "the request body is too large")
+		return &p
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	value, err := decodeJSON(body)
+	if err != nil {
+		// Leave syntax errors to the handler, which reports them in its
+		// own terms.
+		return nil
+	}
+	if errs := s.check(media.Schema, value, "#", "body", true); len(errs) > 0 {
+		p := validationProblem(errs, "")
+		return &p
+	}
+	return nil
+}
+
+func (s *apiSpec) checkResponse(op *specOperation, rec *bufferedResponse) validationErrors {
+	resp, ok := op.Responses[strconv.Itoa(rec.status)]
+	if !ok {
+		return validationErrors{{Code: "undocumented_status", Detail: fmt.Sprintf("status %d is not documented", rec.status)}}
+	}
+	if len(resp.Content) == 0 {
+		if rec.body.Len() > 0 {
+			return validationErrors{{Code: "unexpected_body", Detail: "the response should have no body"}}
+		}
+		return nil
+	}
+	contentType := rec.header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	media, documented := resp.Content[mediaType]
+	if !isJSON(contentType, false) {
+		_, negotiable := resp.Content["application/json"]
+		if !documented && !negotiable {
+			return validationErrors{{Code: "undocumented_content_type", Detail: fmt.Sprintf("%q is not documented", contentType)}}
+		}
+		return nil
+	}
+	if !documented {
+		return validationErrors{{Code: "undocumented_content_type", Detail: fmt.Sprintf("%q is not documented", contentType)}}
+	}
+	value, err := decodeJSON(rec.body.Bytes())
+	if err != nil {
+		return validationErrors{{Code: "invalid_json", Detail: err.Error()}}
+	}
+	return s.check(media.Schema, value, "#", "body", false)
+}
+
+// check validates value against the subset of JSON Schema that openAPI
+// generates. Properties marked readOnly are ignored in requests.
+func (s *apiSpec) check(schema, value any, pointer, name string, request bool) validationErrors {
+	sc, _ := schema.(map[string]any)
+	if ref, ok := sc["$ref"].(string); ok {
+		return s.check(s.Components.Schemas[strings.TrimPrefix(ref, "#/components/schemas/")], value, pointer, name, request)
+	}
+	if anyOf, ok := sc["anyOf"].([]any); ok {
+		var first validationErrors
+		for i, alt := range anyOf {
+			errs := s.check(alt, value, pointer, name, request)
+			if len(errs) == 0 {
+				return nil
+			}
+			if i == 0 {
+				first = errs
+			}
+		}
+		return first
+	}
+
+	fail := func(code, format string, args ...any) validationErrors {
+		return validationErrors{{Pointer: pointer, Code: code, Detail: name + " " + fmt.Sprintf(format, args...)}}
+	}
+	if typ, ok := sc["type"].(string); ok && !hasType(value, typ) {
+		return fail("invalid_type", "must be %s", typeName(typ))
+	}
+	if enum, ok := sc["enum"].([]any); ok {
+		allowed := make([]string, len(enum))
+		found := false
+		for i, e := range enum {
+			allowed[i] = fmt.Sprint(e)
+			found = found || allowed[i] == fmt.Sprint(value)
+		}
+		if !found {
+			return fail("invalid_value", "must be one of %s", strings.Join(allowed, ", "))
+		}
+	}
+	if min, ok := sc["minimum"].(json.Number); ok {
+		n, _ := value.(json.Number).Float64()
+		if m, _ := min.Float64(); n < m {
+			return fail("out_of_range", "must be at least %s", min)
+		}
+	}
+	if sc["format"] == "date-time" {
+		if _, err := time.Parse(time.RFC3339, value.(string)); err != nil {
+			return fail("invalid_format", "must be an RFC 3339 timestamp")
+		}
+	}
+
+	var errs validationErrors
+	switch v := value.(type) {
+	case map[string]any:
+		props, _ := sc["properties"].(map[string]any)
+		required, _ := sc["required"].([]any)
+		for _, r := range required {
+			key := r.(string)
+			if _, ok := v[key]; !ok {
+				errs = append(errs, fieldError{Pointer: pointer + "/" + key, Code: "required", Detail: key + " is required"})
+			}
+		}
+		for _, key := range slices.Sorted(maps.Keys(props)) {
+			prop := props[key]
+			field, ok := v[key]
+			if !ok || (request && prop.(map[string]any)["readOnly"] == true) {
+				continue
+			}
+			errs = append(errs, s.check(prop, field, pointer+"/"+key, key, request)...)
+		}
+		if extra, ok := sc["additionalProperties"]; ok {
+			for _, key := range slices.Sorted(maps.Keys(v)) {
+				if _, ok := props[key]; !ok {
+					errs = append(errs, s.check(extra, v[key], pointer+"/"+key, key, request)...)
+				}
+			}
+		}
+	case []any:
+		for i, item := range v {
+			errs = append(errs, s.check(sc["items"], item, fmt.Sprintf("%s/%d", pointer, i), fmt.Sprintf("%s[%d]", name, i), request)...)
+		}
+	}
+	return errs
+}
+
+// parseParam converts a parameter to the JSON value its schema describes,
+// leaving it as a string when it does not parse so that check reports it.
+func (s *apiSpec) parseParam(schema any, value string) any {
+	sc, _ := schema.(map[string]any)
+	if ref, ok := sc["$ref"].(string); ok {
+		sc, _ = s.Components.Schemas[strings.TrimPrefix(ref, "#/components/schemas/")].(map[string]any)
+	}
+	switch sc["type"] {
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case "integer":
+		if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return json.Number(value)
+		}
+	}
+	return value
+}
+
+func pathValue(pattern, path, name string) string {
+	want := "{" + name + "}"
+	segments := strings.Split(path, "/")
+	for i, seg := range strings.Split(pattern, "/") {
+		if seg == want && i < len(segments) {
+			return segments[i]
+		}
+	}
+	return ""
+}
+
+func hasType(value any, typ string) bool {
+	switch v := value.(type) {
+	case nil:
+		return typ == "null"
+	case bool:
+		return typ == "boolean"
+	case string:
+		return typ == "string"
+	case json.Number:
+		if typ == "integer" {
+			_, err := v.Int64()
+			return err == nil
+		}
+		return typ == "number"
+	case []any:
+		return typ == "array"
+	case map[string]any:
+		return typ == "object"
+	}
+	return false
+}
+
+func typeName(typ string) string {
+	switch typ {
+	case "array", "object", "integer":
+		return "an " + typ
+	case "null":
+		return typ
+	}
+	return "a " + typ
+}
+
+// isJSON reports whether contentType is JSON. An empty Content-Type counts
+// only when allowEmpty is set, as request bodies default to JSON.
+func isJSON(contentType string, allowEmpty bool) bool {
+	if contentType == "" {
+		return allowEmpty
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
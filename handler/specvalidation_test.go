@@ -0,0 +1,136 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sawez-deepsource/demo-go/handler"
+	"github.com/sawez-deepsource/demo-go/metrics"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+type problemBody struct {
+	Status int `json:"status"`
+	Errors []struct {
+		Pointer   string `json:"pointer"`
+		Parameter string `json:"parameter"`
+		Code      string `json:"code"`
+	} `json:"errors"`
+}
+
+func violations(direction, route string) float64 {
+	return testutil.ToFloat64(metrics.SpecViolations.WithLabelValues(direction, route))
+}
+
+func TestSpecValidationRejectsRequests(t *testing.T) {
+	store.Clear()
+	mux := http.NewServeMux()
+	handler.Register(mux)
+	h := handler.SpecValidation(handler.SpecValidationConfig{Requests: true}, mux)
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		location string
+		code     string
+	}{
+		{"query type", http.MethodGet, "/tasks?done=maybe", "", "done", "invalid_type"},
+		{"query enum", http.MethodGet, "/tasks?priority=5", "", "priority", "invalid_value"},
+		{"required query", http.MethodGet, "/tasks.ics?subscription=1", "", "token", "required"},
+		{"header", http.MethodGet, "/events", "", "Last-Event-ID", "invalid_type"},
+		{"body type", http.MethodPost, "/tasks", `{"title":5}`, "#/title", "invalid_type"},
+		{"body enum", http.MethodPost, "/tasks", `{"title":"x","priority":7}`, "#/priority", "invalid_value"},
+		{"required field", http.MethodPost, "/tasks", `{"done":true}`, "#/title", "required"},
+		{"nested", http.MethodPost, "/tasks:batch", `{"operations":[{"op":"create","task":{"title":[]}}]}`, "#/operations/0/task/title", "invalid_type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.location == "Last-Event-ID" {
+				req.Header.Set("Last-Event-ID", "latest")
+			}
+			_, pattern := mux.Handler(req)
+			before := violations("request", pattern)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d: %s", w.Code, w.Body)
+			}
+			var p problemBody
+			json.NewDecoder(w.Body).Decode(&p)
+			if len(p.Errors) != 1 || p.Errors[0].Pointer+p.Errors[0].Parameter != tt.location || p.Errors[0].Code != tt.code {
+				t.Fatalf("expected %s at %s, got %+v", tt.code, tt.location, p.Errors)
+			}
+			if got := violations("request", pattern); got != before+1 {
+				t.Fatalf("expected the violation to be counted, got %v", got-before)
+			}
+		})
+	}
+}
+
+func TestSpecValidationPassesValidRequests(t *testing.T) {
+	store.Clear()
+	mux := http.NewServeMux()
+	handler.Register(mux)
+	h := handler.SpecValidation(handler.SpecValidationConfig{Requests: true, Responses: true}, mux)
+
+	// Read-only fields such as id are ignored in request bodies, and the
+	// body is still there for the handler after validation.
+	req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"id":"99","title":"Valid","priority":2}`))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/tasks?done=false", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Valid") {
+		t.Fatalf("expected the task to be listed, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestSpecValidationRejectsResponses(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"total":"many","completed":1}`))
+	})
+	mux.HandleFunc("DELETE /tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := handler.SpecValidation(handler.SpecValidationConfig{Responses: true}, mux)
+
+	before := violations("response", "GET /stats")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", w.Code)
+	}
+	var p problemBody
+	json.NewDecoder(w.Body).Decode(&p)
+	got := map[string]string{}
+	for _, e := range p.Errors {
+		got[e.Pointer] = e.Code
+	}
+	if got["#/total"] != "invalid_type" || got["#/pending"] != "required" || len(got) != 2 {
+		t.Fatalf("unexpected violations: %+v", p.Errors)
+	}
+	if violations("response", "GET /stats") != before+1 {
+		t.Fatal("expected the violation to be counted")
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/tasks/1", nil))
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "undocumented_status") {
+		t.Fatalf("expected an undocumented status to be reported, got %d: %s", w.Code, w.Body)
+	}
+}
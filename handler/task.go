@@ -2,18 +2,38 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 
+	"github.com/sawez-deepsource/demo-go/codec"
 	"github.com/sawez-deepsource/demo-go/model"
 	"github.com/sawez-deepsource/demo-go/store"
 )
 
+// errorResponse is an RFC 9457 problem details object. Errors lists
+// individual problems with the request body, each located by a JSON pointer.
 type errorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []fieldError `json:"errors,omitempty"`
+}
+
+// fieldError locates a problem either in the body, by JSON pointer, or in a
+// path, query or header parameter, by name.
+type fieldError struct {
+	Pointer   string `json:"pointer,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+	Code      string `json:"code"`
+	Detail    string `json:"detail"`
 }
 
 type statsResponse struct {
@@ -22,118 +42,242 @@ type statsResponse struct {
 	Pending   int `json:"pending"`
 }
 
+// MaxPageSize caps the limit parameter of ListTasks.
+const MaxPageSize = 1000
+
 func ListTasks(w http.ResponseWriter, r *http.Request) {
+	tasks, err := filterTasks(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, more, err := paginate(r, tasks)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if more {
+		next := *r.URL
+		q := next.Query()
+		q.Set("after", page[len(page)-1].ID)
+		next.RawQuery = q.Encode()
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+	}
+	writeResponse(w, r, http.StatusOK, page)
+}
+
+// paginate applies the after and limit query parameters to tasks, which
+// must be in ID order. Pages are keyed by the last ID seen rather than an
+// offset, so tasks added or removed meanwhile do not shift later pages. It
+// reports whether tasks remain after the page.
+func paginate(r *http.Request, tasks []model.Task) ([]model.Task, bool, error) {
+	q := r.URL.Query()
+	if after := q.Get("after"); after != "" {
+		i, found := slices.BinarySearchFunc(tasks, after, func(t model.Task, id string) int {
+			return strings.Compare(t.ID, id)
+		})
+		if found {
+			i++
+		}
+		tasks = tasks[i:]
+	}
+	v := q.Get("limit")
+	if v == "" {
+		return tasks, false, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 || limit > MaxPageSize {
+		return nil, false, fmt.Errorf("limit must be between 1 and %d", MaxPageSize)
+	}
+	if len(tasks) <= limit {
+		return tasks, false, nil
+	}
+	return tasks[:limit], true, nil
+}
+
+// filterTasks returns the tasks selected by the done, priority or project
+// query parameter, or all tasks when none is given.
+func filterTasks(r *http.Request) ([]model.Task, error) {
 	doneFilter := r.URL.Query().Get("done")
 	priorityFilter := r.URL.Query().Get("priority")
-
-	var tasks []model.Task
+	projectFilter := r.URL.Query().Get("project")
 
 	if doneFilter != "" {
 		done := doneFilter == "true"
-		tasks = store.FilterByDone(done)
+		return store.FilterByDoneContext(r.Context(), done), nil
 	} else if priorityFilter != "" {
 		p, err := strconv.Atoi(priorityFilter)
 		if err != nil || !model.ValidatePriority(model.Priority(p)) {
-			writeError(w, http.StatusBadRequest, "invalid priority filter")
-			return
+			return nil, errors.New("invalid priority filter")
 		}
-		tasks = store.FilterByPriority(model.Priority(p))
-	} else {
-		tasks = store.All()
+		return store.FilterByPriorityContext(r.Context(), model.Priority(p)), nil
+	} else if projectFilter != "" {
+		return store.FilterByProjectContext(r.Context(), projectFilter), nil
 	}
-
-	writeJSON(w, http.StatusOK, tasks)
+	return store.AllContext(r.Context()), nil
 }
 
 func GetTask(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	t, ok := store.Get(id)
+	t, ok := store.GetContext(r.Context(), id)
 	if !ok {
-		writeError(w, http.StatusNotFound, "task not found")
+		writeError(w, r, http.StatusNotFound, "task not found")
 		return
 	}
-	writeJSON(w, http.StatusOK, t)
+	writeResponse(w, r, http.StatusOK, t)
 }
 
 func CreateTask(w http.ResponseWriter, r *http.Request) {
 	var t model.Task
-	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid json payload")
+	if !decodeBody(w, r, &t) {
 		return
 	}
-	if t.Title == "" {
-		writeError(w, http.StatusBadRequest, "title is required")
+	if err := validateTask(t); err != nil {
+		writeValidationError(w, r, err)
 		return
 	}
-	if !model.ValidatePriority(t.Priority) {
-		writeError(w, http.StatusBadRequest, "priority must be 0 (low), 1 (medium), or 2 (high)")
+	if !chargeCreates(w, r, 1) {
 		return
 	}
-	created := store.Add(t)
-	log.Printf("task created: id=%s title=%q", created.ID, created.Title)
-	writeJSON(w, http.StatusCreated, created)
+	created := store.AddContext(r.Context(), t)
+	slog.InfoContext(r.Context(), "task created", "id", created.ID, "title", created.Title)
+	writeResponse(w, r, http.StatusCreated, created)
 }
 
 func UpdateTask(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	var t model.Task
-	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid json payload")
+	if !decodeBody(w, r, &t) {
 		return
 	}
-	if t.Title == "" {
-		writeError(w, http.StatusBadRequest, "title is required")
+	if err := validateTask(t); err != nil {
+		writeValidationError(w, r, err)
 		return
 	}
-	if !model.ValidatePriority(t.Priority) {
-		writeError(w, http.StatusBadRequest, "priority must be 0 (low), 1 (medium), or 2 (high)")
-		return
-	}
-	updated, ok := store.Update(id, t)
+	updated, ok := store.UpdateContext(r.Context(), id, t)
 	if !ok {
-		writeError(w, http.StatusNotFound, "task not found")
+		writeError(w, r, http.StatusNotFound, "task not found")
 		return
 	}
-	log.Printf("task updated: id=%s title=%q", updated.ID, updated.Title)
-	writeJSON(w, http.StatusOK, updated)
+	slog.InfoContext(r.Context(), "task updated", "id", updated.ID, "title", updated.Title)
+	writeResponse(w, r, http.StatusOK, updated)
 }
 
 func DeleteTask(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	if !store.Delete(id) {
-		writeError(w, http.StatusNotFound, "task not found")
+	if !store.DeleteContext(r.Context(), id) {
+		writeError(w, r, http.StatusNotFound, "task not found")
 		return
 	}
-	log.Printf("task deleted: id=%s", id)
+	slog.InfoContext(r.Context(), "task deleted", "id", id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func TaskStats(w http.ResponseWriter, r *http.Request) {
-	all := store.All()
-	completed := store.FilterByDone(true)
+	all := store.AllContext(r.Context())
+	completed := store.FilterByDoneContext(r.Context(), true)
 	stats := statsResponse{
 		Total:     len(all),
 		Completed: len(completed),
 		Pending:   len(all) - len(completed),
 	}
-	writeJSON(w, http.StatusOK, stats)
+	writeResponse(w, r, http.StatusOK, stats)
 }
 
-func writeJSON(w http.ResponseWriter, status int, v any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(v); err != nil {
-		log.Printf("failed to encode json response: %v", err)
+// validateTask checks the fields a client may set on create and update
+// against the model's rules. It is shared by every entry point that accepts
+// tasks, and reports every problem at once as validationErrors.
+func validateTask(t model.Task) error {
+	var merrs model.ValidationError
+	if !errors.As(model.Validate(t), &merrs) {
+		return nil
 	}
+	errs := make(validationErrors, len(merrs))
+	for i, e := range merrs {
+		errs[i] = fieldError{Pointer: "#/" + e.Field, Code: e.Code, Detail: e.Message}
+	}
+	return errs
+}
+
+// decodeBody decodes the request body in the format named by its
+// Content-Type, reading at most maxImportSize bytes. On failure it writes a
+// 413, 415 or 400 response and returns false.
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	c, ok := codec.ForContentType(r.Header.Get("Content-Type"))
+	if !ok {
+		writeError(w, r, http.StatusUnsupportedMediaType, "unsupported content type")
+		return false
+	}
+	if err := c.Decode(http.MaxBytesReader(w, r.Body, maxImportSize), v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, "the request body is too large")
+			return false
+		}
+		if errors.Is(err, codec.ErrUnsupported) {
+			writeError(w, r, http.StatusUnsupportedMediaType, fmt.Sprintf("%s is not supported for this request", c.Name()))
+			return false
+		}
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			writeValidationError(w, r, validationErrors{{
+				Pointer: "#/" + strings.ReplaceAll(typeErr.Field, ".", "/"),
+				Code:    "invalid_type",
+				Detail:  fmt.Sprintf("%s cannot be a %s", typeErr.Field, typeErr.Value),
+			}})
+			return false
+		}
+		writeError(w, r, http.StatusBadRequest, fmt.Sprintf("invalid %s payload", c.Name()))
+		return false
+	}
+	return true
+}
+
+// writeResponse encodes v in the format the client asked for in its Accept
+// header, answering 406 if no registered codec is acceptable.
+func writeResponse(w http.ResponseWriter, r *http.Request, status int, v any) {
+	c, ok := codec.Negotiate(r.Header.Get("Accept"))
+	if !ok {
+		writeError(w, r, http.StatusNotAcceptable, "none of the accepted media types can be produced")
+		return
+	}
+	encode(w, r, c, c.MediaTypes()[0], status, v)
+}
+
+// writeError sends a problem with the generic about:blank type, whose title
+// is the status text.
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	writeProblem(w, r, newProblem(status, message))
+}
+
+// writeValidationError sends a 400 problem listing every field error in err,
+// or a plain 400 if err is not a validationErrors.
+func writeValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	writeProblem(w, r, validationProblem(err, ""))
 }
 
-func writeError(w http.ResponseWriter, status int, message string) {
-	w.Header().Set("Content-Type", "application/json")
+// writeProblem negotiates like writeResponse but falls back to the default
+// format rather than failing. JSON problems use application/problem+json.
+func writeProblem(w http.ResponseWriter, r *http.Request, p errorResponse) {
+	c, ok := codec.Negotiate(r.Header.Get("Accept"))
+	if !ok {
+		c = codec.Default
+	}
+	contentType := c.MediaTypes()[0]
+	if c.Name() == "json" {
+		contentType = "application/problem+json"
+	}
+	p.Instance = r.URL.Path
+	encode(w, r, c, contentType, p.Status, p)
+}
+
+func encode(w http.ResponseWriter, r *http.Request, c codec.Codec, contentType string, status int, v any) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
 	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(errorResponse{
-		Error:   http.StatusText(status),
-		Message: message,
-	})
+	if err := c.Encode(w, v); err != nil {
+		slog.ErrorContext(r.Context(), "failed to encode response", "format", c.Name(), "error", err)
+	}
 }
 
 // -------------------------------------------------------
@@ -12,15 +12,12 @@ import (
 	"github.com/sawez-deepsource/demo-go/store"
 )
 
-func setupMux() *http.ServeMux {
+// setupMux serves every route with strict response validation, so each
+// test also checks that the handlers keep to the OpenAPI document.
+func setupMux() http.Handler {
 	mux := http.NewServeMux()
-	mux.HandleFunc("POST /tasks", handler.CreateTask)
-	mux.HandleFunc("GET /tasks", handler.ListTasks)
-	mux.HandleFunc("GET /tasks/{id}", handler.GetTask)
-	mux.HandleFunc("PUT /tasks/{id}", handler.UpdateTask)
-	mux.HandleFunc("DELETE /tasks/{id}", handler.DeleteTask)
-	mux.HandleFunc("GET /stats", handler.TaskStats)
-	return mux
+	handler.Register(mux)
+	return handler.SpecValidation(handler.SpecValidationConfig{Responses: true}, mux)
 }
 
 func TestCreateAndListTasks(t *testing.T) {
@@ -276,6 +273,48 @@ func TestFilterByPriority(t *testing.T) {
 	}
 }
 
+func TestListTasksPagination(t *testing.T) {
+	store.Clear()
+	mux := setupMux()
+
+	for _, title := range []string{"One", "Two", "Three", "Four", "Five"} {
+		store.Add(model.NewTask(title, "", model.PriorityLow))
+	}
+
+	var titles []string
+	next := "/tasks?limit=2"
+	for pages := 0; next != ""; pages++ {
+		if pages == 3 {
+			t.Fatal("expected 3 pages")
+		}
+		req := httptest.NewRequest(http.MethodGet, next, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+		var tasks []model.Task
+		json.NewDecoder(w.Body).Decode(&tasks)
+		for _, task := range tasks {
+			titles = append(titles, task.Title)
+		}
+		next = ""
+		if link := w.Header().Get("Link"); link != "" {
+			next = strings.TrimPrefix(strings.TrimSuffix(link, `>; rel="next"`), "<")
+		}
+	}
+	if strings.Join(titles, ",") != "One,Two,Three,Four,Five" {
+		t.Fatalf("expected every task once in order, got %v", titles)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks?limit=0", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for limit=0, got %d", w.Code)
+	}
+}
+
 func TestInvalidJSON(t *testing.T) {
 	store.Clear()
 	mux := setupMux()
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadTokens reads a YAML mapping from the SHA-256 hex digest of each API
+// token to its user, so that the file does not hold the tokens themselves.
+func LoadTokens(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tokens := map[string]string{}
+	if err := yaml.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for digest, user := range tokens {
+		if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size || user == "" {
+			return nil, fmt.Errorf("%s: %q is not the SHA-256 hex digest of a token mapped to a user", path, digest)
+		}
+	}
+	return tokens, nil
+}
+
+// TokenUser returns the user of the bearer token in an Authorization header
+// value. It returns "" without an error when there is no header or tokens
+// is nil, in which case tokens are not checked and never identify anyone.
+func TokenUser(tokens map[string]string, authorization string) (string, error) {
+	if authorization == "" || tokens == nil {
+		return "", nil
+	}
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		return "", errors.New("authorization must be a bearer token")
+	}
+	sum := sha256.Sum256([]byte(token))
+	user, ok := tokens[hex.EncodeToString(sum[:])]
+	if !ok {
+		return "", errors.New("unknown API token")
+	}
+	return user, nil
+}
+
+// TokenAuth maps the bearer token of each request to a user, available to
+// later handlers through User, as ClientCertAuth does for certificates.
+// Requests with a token not in tokens are refused with 401. A user already
+// set by a client certificate takes precedence over the token's.
+func TokenAuth(tokens map[string]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := TokenUser(tokens, r.Header.Get("Authorization"))
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, r, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if user == "" || User(r.Context()) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+	})
+}
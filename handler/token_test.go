@@ -0,0 +1,75 @@
+package handler_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/handler"
+)
+
+// tokenDigests maps the digest of each token to the user following it.
+func tokenDigests(pairs ...string) map[string]string {
+	tokens := map[string]string{}
+	for i := 0; i < len(pairs); i += 2 {
+		sum := sha256.Sum256([]byte(pairs[i]))
+		tokens[hex.EncodeToString(sum[:])] = pairs[i+1]
+	}
+	return tokens
+}
+
+func TestTokenAuth(t *testing.T) {
+	whoami := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, handler.User(r.Context()))
+	})
+	h := handler.ClientCertAuth(nil, handler.TokenAuth(tokenDigests("s3cret", "ci"), whoami))
+
+	tests := []struct {
+		name          string
+		authorization string
+		cert          string
+		code          int
+		user          string
+	}{
+		{"token", "Bearer s3cret", "", http.StatusOK, "ci"},
+		{"no token", "", "", http.StatusOK, ""},
+		{"unknown token", "Bearer guessed", "", http.StatusUnauthorized, ""},
+		{"not bearer", "Basic czNjcmV0", "", http.StatusUnauthorized, ""},
+		{"certificate first", "Bearer s3cret", "alice@example.com", http.StatusOK, "alice@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.cert != "" {
+				req = withClientCert(req, tt.cert)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tt.code || (tt.code == http.StatusOK && w.Body.String() != tt.user) {
+				t.Fatalf("expected %d %q, got %d %q", tt.code, tt.user, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestLoadTokens(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "tokens.yaml")
+	os.WriteFile(good, []byte("2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b: ci\n"), 0o600)
+	if tokens, err := handler.LoadTokens(good); err != nil || tokens["2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"] != "ci" {
+		t.Fatalf("expected the token to load, got %v %v", tokens, err)
+	}
+	plain := filepath.Join(dir, "plain.yaml")
+	os.WriteFile(plain, []byte("s3cret: ci\n"), 0o600)
+	if _, err := handler.LoadTokens(plain); err == nil {
+		t.Fatal("expected tokens in the clear to be refused")
+	}
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/sawez-deepsource/demo-go/store"
+	"github.com/sawez-deepsource/demo-go/webhook"
+)
+
+type webhookRequest struct {
+	URL    string             `json:"url"`
+	Events []store.ChangeType `json:"events"`
+	Secret string             `json:"secret"`
+}
+
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if err := webhook.ValidateURL(r.Context(), req.URL); err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, e := range req.Events {
+		if e != store.Created && e != store.Updated && e != store.Deleted {
+			writeError(w, r, http.StatusBadRequest, "events must be created, updated or deleted")
+			return
+		}
+	}
+	created, err := webhook.Create(webhook.Subscription{
+		URL:    req.URL,
+		Events: req.Events,
+		Secret: req.Secret,
+	})
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "failed to create webhook")
+		return
+	}
+	slog.InfoContext(r.Context(), "webhook created", "id", created.ID, "url", created.URL)
+	// The secret is only ever returned here, so a generated one can be
+	// recorded by the caller.
+	writeResponse(w, r, http.StatusCreated, created)
+}
+
+func ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	subs := webhook.All()
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	writeResponse(w, r, http.StatusOK, subs)
+}
+
+func GetWebhook(w http.ResponseWriter, r *http.Request) {
+	s, ok := webhook.Get(r.PathValue("id"))
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "webhook not found")
+		return
+	}
+	s.Secret = ""
+	writeResponse(w, r, http.StatusOK, s)
+}
+
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !webhook.Delete(id) {
+		writeError(w, r, http.StatusNotFound, "webhook not found")
+		return
+	}
+	slog.InfoContext(r.Context(), "webhook deleted", "id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries shows every delivery attempt for a webhook. The
+// status filter accepts pending, delivered, or failed for the dead-letter
+// list.
+func ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", webhook.StatusPending, webhook.StatusDelivered, webhook.StatusFailed:
+	default:
+		writeError(w, r, http.StatusBadRequest, "invalid status filter")
+		return
+	}
+	deliveries, err := webhook.Deliveries(r.PathValue("id"), status)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "webhook not found")
+		return
+	}
+	writeResponse(w, r, http.StatusOK, deliveries)
+}
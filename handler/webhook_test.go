@@ -0,0 +1,72 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/webhook"
+)
+
+func TestCreateWebhookHidesSecret(t *testing.T) {
+	webhook.Load("")
+	mux := setupMux()
+
+	body := `{"url":"https://ci.example.com/hook","events":["created"]}`
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+	var created webhook.Subscription
+	json.NewDecoder(w.Body).Decode(&created)
+	if created.Secret == "" {
+		t.Fatal("expected a generated secret in the create response")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/webhooks/"+created.ID, nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), created.Secret) {
+		t.Fatal("expected secret to be hidden after creation")
+	}
+}
+
+func TestCreateWebhookValidation(t *testing.T) {
+	webhook.Load("")
+	mux := setupMux()
+
+	for _, body := range []string{
+		`{"url":"ftp://example.com"}`,
+		`{"url":"https://example.com","events":["archived"]}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestWebhookDeliveriesNotFound(t *testing.T) {
+	webhook.Load("")
+	mux := setupMux()
+
+	req := httptest.NewRequest(http.MethodGet, "/webhooks/999/deliveries", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,6 @@
+package health
+
+// Undrain reverses Drain between tests.
+func Undrain() {
+	draining.Store(false)
+}
@@ -0,0 +1,171 @@
+// Package health runs the checks behind the liveness and readiness probes
+// and tracks whether the server is draining before shutdown.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/store"
+	"github.com/sawez-deepsource/demo-go/webhook"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+	// StatusWarn is the status of a failed Degrades check, and
+	// StatusDegraded that of a probe whose only failures are such checks.
+	StatusWarn     = "warn"
+	StatusDegraded = "degraded"
+)
+
+// Timeout bounds each check; a check still running after it fails.
+var Timeout = time.Second
+
+// Check is a named probe of one dependency. Liveness checks should only fail
+// when restarting the process would help; readiness checks fail whenever the
+// server should not receive traffic. A check that Degrades is reported by
+// the probes it is marked for but does not fail them, for dependencies the
+// API can serve without.
+type Check struct {
+	Name      string
+	Liveness  bool
+	Readiness bool
+	Degrades  bool
+	Run       func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of one check.
+type CheckResult struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of a probe: ok only if every check passed, and
+// degraded if only checks that Degrades failed.
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+var (
+	mu     sync.RWMutex
+	checks = []Check{
+		{Name: "store", Liveness: true, Readiness: true, Run: pingStore},
+		// Deliveries are retried, so failing to save them does not stop the
+		// API from serving.
+		{Name: "webhooks", Readiness: true, Degrades: true, Run: func(context.Context) error { return webhook.Err() }},
+		{Name: "draining", Readiness: true, Run: notDraining},
+	}
+
+	draining atomic.Bool
+)
+
+// Register adds c to the checks run by the probes it is marked for,
+// replacing any check with the same name.
+func Register(c Check) {
+	mu.Lock()
+	defer mu.Unlock()
+	for i := range checks {
+		if checks[i].Name == c.Name {
+			checks[i] = c
+			return
+		}
+	}
+	checks = append(checks, c)
+}
+
+// Drain makes readiness fail from now on, so that load balancers stop
+// sending traffic before the server shuts down.
+func Drain() {
+	draining.Store(true)
+}
+
+// Draining reports whether Drain has been called.
+func Draining() bool {
+	return draining.Load()
+}
+
+// Live runs the liveness checks.
+func Live(ctx context.Context) Report {
+	return run(ctx, func(c Check) bool { return c.Liveness })
+}
+
+// Ready runs the readiness checks.
+func Ready(ctx context.Context) Report {
+	return run(ctx, func(c Check) bool { return c.Readiness })
+}
+
+// run runs the selected checks concurrently, each under Timeout, and
+// reports them in registration order.
+func run(ctx context.Context, selected func(Check) bool) Report {
+	mu.RLock()
+	var run []Check
+	for _, c := range checks {
+		if selected(c) {
+			run = append(run, c)
+		}
+	}
+	mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make([]CheckResult, len(run))}
+	var wg sync.WaitGroup
+	for i, c := range run {
+		wg.Go(func() {
+			report.Checks[i] = runCheck(ctx, c)
+		})
+	}
+	wg.Wait()
+	for _, res := range report.Checks {
+		switch {
+		case res.Status == StatusFail:
+			report.Status = StatusFail
+		case res.Status == StatusWarn && report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, c Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() { errc <- c.Run(ctx) }()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", Timeout)
+	}
+	res := CheckResult{Name: c.Name, Status: StatusOK, DurationMS: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		res.Status, res.Error = StatusFail, err.Error()
+		if c.Degrades {
+			res.Status = StatusWarn
+		}
+	}
+	return res
+}
+
+// pingStore checks that the store lock can be taken, which is all that can
+// go wrong with an in-memory store.
+func pingStore(context.Context) error {
+	store.Count()
+	return nil
+}
+
+func notDraining(context.Context) error {
+	if Draining() {
+		return errors.New("server is shutting down")
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/health"
+)
+
+func find(r health.Report, name string) (health.CheckResult, bool) {
+	for _, c := range r.Checks {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return health.CheckResult{}, false
+}
+
+func TestDrain(t *testing.T) {
+	defer health.Undrain()
+	if r := health.Ready(context.Background()); r.Status != health.StatusOK {
+		t.Fatalf("expected ready, got %+v", r)
+	}
+
+	health.Drain()
+	r := health.Ready(context.Background())
+	c, _ := find(r, "draining")
+	if r.Status != health.StatusFail || c.Status != health.StatusFail || c.Error != "server is shutting down" {
+		t.Fatalf("expected draining to fail readiness, got %+v", r)
+	}
+	if r := health.Live(context.Background()); r.Status != health.StatusOK {
+		t.Fatalf("expected draining not to affect liveness, got %+v", r)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	health.Timeout = 20 * time.Millisecond
+	defer func() { health.Timeout = time.Second }()
+	var failing atomic.Bool
+	failing.Store(true)
+	health.Register(health.Check{Name: "backend", Readiness: true, Run: func(context.Context) error {
+		if failing.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	}})
+	health.Register(health.Check{Name: "stuck", Liveness: true, Run: func(ctx context.Context) error {
+		if failing.Load() {
+			<-ctx.Done()
+		}
+		return nil
+	}})
+
+	ready := health.Ready(context.Background())
+	if c, ok := find(ready, "backend"); !ok || c.Error != "connection refused" || ready.Status != health.StatusFail {
+		t.Errorf("expected the backend check to fail readiness, got %+v", ready)
+	}
+	if _, ok := find(ready, "stuck"); ok {
+		t.Error("expected liveness-only checks to be skipped by readiness")
+	}
+	live := health.Live(context.Background())
+	if c, _ := find(live, "stuck"); c.Status != health.StatusFail || c.Error != "timed out after 20ms" || c.DurationMS < 20 {
+		t.Errorf("expected the stuck check to time out, got %+v", live)
+	}
+
+	failing.Store(false)
+	if r := health.Ready(context.Background()); r.Status != health.StatusOK || len(r.Checks) != 4 {
+		t.Errorf("expected every readiness check to pass, got %+v", r)
+	}
+}
+
+func TestDegradingCheckKeepsReadiness(t *testing.T) {
+	health.Register(health.Check{Name: "optional", Readiness: true, Degrades: true, Run: func(context.Context) error {
+		return errors.New("disk full")
+	}})
+	defer health.Register(health.Check{Name: "optional", Run: func(context.Context) error { return nil }})
+
+	r := health.Ready(context.Background())
+	if c, _ := find(r, "optional"); r.Status != health.StatusDegraded || c.Status != health.StatusWarn || c.Error != "disk full" {
+		t.Fatalf("expected a degraded report, got %+v", r)
+	}
+}
@@ -0,0 +1,292 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/model"
+)
+
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405Z"
+	// maxLineOctets is the longest content line allowed before folding.
+	maxLineOctets = 75
+)
+
+// Encode writes tasks as a VCALENDAR of RFC 5545 VTODO components. The task
+// ID is used as the UID, so a feed can be re-imported without duplicating
+// tasks. now stamps every component's DTSTAMP.
+func Encode(w io.Writer, tasks []model.Task, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeFolded(bw, name+":"+value)
+	}
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//demo-go//tasks//EN")
+	stamp := now.UTC().Format(dateTimeLayout)
+	for _, t := range tasks {
+		line("BEGIN", "VTODO")
+		line("UID", escape(t.ID))
+		line("DTSTAMP", stamp)
+		line("SUMMARY", escape(t.Title))
+		if t.Description != "" {
+			line("DESCRIPTION", escape(t.Description))
+		}
+		if t.Project != "" {
+			line("CATEGORIES", escape(t.Project))
+		}
+		line("PRIORITY", strconv.Itoa(toICalPriority(t.Priority)))
+		if ts, ok := formatTimestamp(t.CreatedAt); ok {
+			line("CREATED", ts)
+		}
+		if ts, ok := formatTimestamp(t.UpdatedAt); ok {
+			line("LAST-MODIFIED", ts)
+		}
+		if t.Due != "" {
+			if d, err := time.Parse(time.DateOnly, t.Due); err == nil {
+				writeFolded(bw, "DUE;VALUE=DATE:"+d.Format(dateLayout))
+			} else if ts, ok := formatTimestamp(t.Due); ok {
+				line("DUE", ts)
+			}
+		}
+		if t.Done {
+			line("STATUS", "COMPLETED")
+			if ts, ok := formatTimestamp(t.UpdatedAt); ok {
+				line("COMPLETED", ts)
+			}
+		} else {
+			line("STATUS", "NEEDS-ACTION")
+		}
+		line("END", "VTODO")
+	}
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// writeFolded writes a content line, folding it into 75-octet chunks without
+// splitting UTF-8 sequences.
+func writeFolded(w *bufio.Writer, s string) {
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space, which counts.
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func formatTimestamp(s string) (string, bool) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return "", false
+	}
+	return t.UTC().Format(dateTimeLayout), true
+}
+
+// toICalPriority maps onto the RFC 5545 scale, where 1 is highest and 9
+// lowest.
+func toICalPriority(p model.Priority) int {
+	switch p {
+	case model.PriorityHigh:
+		return 1
+	case model.PriorityMedium:
+		return 5
+	default:
+		return 9
+	}
+}
+
+func fromICalPriority(n int) model.Priority {
+	switch {
+	case n >= 1 && n <= 4:
+		return model.PriorityHigh
+	case n == 5:
+		return model.PriorityMedium
+	default:
+		return model.PriorityLow
+	}
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+var unescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
+
+// Todo is one decoded VTODO. Line is where its BEGIN:VTODO appears.
+type Todo struct {
+	Line   int
+	Task   model.Task
+	Errors []string
+}
+
+// Decode reads every VTODO from an iCalendar stream. Other components are
+// skipped. The UID becomes the task ID.
+func Decode(r io.Reader) ([]Todo, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var todos []Todo
+	var cur *Todo
+	depth := 0
+	for _, l := range lines {
+		name, params, value, ok := parseLine(l.text)
+		if !ok {
+			if cur != nil {
+				cur.Errors = append(cur.Errors, fmt.Sprintf("line %d is malformed", l.num))
+			}
+			continue
+		}
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VTODO"):
+			cur = &Todo{Line: l.num}
+			depth = 0
+		case cur == nil:
+		case name == "BEGIN":
+			// Nested components such as VALARM.
+			depth++
+		case name == "END" && depth > 0:
+			depth--
+		case name == "END" && strings.EqualFold(value, "VTODO"):
+			todos = append(todos, *cur)
+			cur = nil
+		case depth == 0:
+			if err := setProperty(&cur.Task, name, params, value); err != nil {
+				cur.Errors = append(cur.Errors, err.Error())
+			}
+		}
+	}
+	if cur != nil {
+		return nil, errors.New("unterminated VTODO")
+	}
+	return todos, nil
+}
+
+type contentLine struct {
+	num  int
+	text string
+}
+
+func unfold(r io.Reader) ([]contentLine, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	var lines []contentLine
+	num := 0
+	for sc.Scan() {
+		num++
+		text := strings.TrimSuffix(sc.Text(), "\r")
+		if (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1].text += text[1:]
+			continue
+		}
+		if text == "" {
+			continue
+		}
+		lines = append(lines, contentLine{num: num, text: text})
+	}
+	return lines, sc.Err()
+}
+
+// parseLine splits "NAME;PARAM=x:VALUE" into its parts. Parameter values may
+// be quoted and contain colons.
+func parseLine(s string) (name string, params map[string]string, value string, ok bool) {
+	inQuotes := false
+	colon := -1
+	for i, c := range s {
+		if c == '"' {
+			inQuotes = !inQuotes
+		} else if c == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return "", nil, "", false
+	}
+	head := strings.Split(s[:colon], ";")
+	params = map[string]string{}
+	for _, p := range head[1:] {
+		k, v, _ := strings.Cut(p, "=")
+		params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+	return strings.ToUpper(head[0]), params, s[colon+1:], true
+}
+
+func setProperty(t *model.Task, name string, params map[string]string, value string) error {
+	switch name {
+	case "UID":
+		t.ID = unescape(value)
+	case "SUMMARY":
+		t.Title = unescape(value)
+	case "DESCRIPTION":
+		t.Description = unescape(value)
+	case "CATEGORIES":
+		// Tasks belong to a single project; use the first category.
+		first, _, _ := strings.Cut(value, ",")
+		t.Project = unescape(first)
+	case "PRIORITY":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > 9 {
+			return errors.New("PRIORITY must be a number from 0 to 9")
+		}
+		t.Priority = fromICalPriority(n)
+	case "STATUS":
+		t.Done = strings.EqualFold(value, "COMPLETED")
+	case "DUE":
+		due, err := parseDue(params, value)
+		if err != nil {
+			return err
+		}
+		t.Due = due
+	case "CREATED":
+		ts, err := parseDateTime(value)
+		if err != nil {
+			return errors.New("CREATED must be a date-time")
+		}
+		t.CreatedAt = ts.Format(time.RFC3339)
+	}
+	return nil
+}
+
+func parseDue(params map[string]string, value string) (string, error) {
+	if params["VALUE"] == "DATE" || len(value) == len(dateLayout) {
+		d, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return "", errors.New("DUE must be a date or date-time")
+		}
+		return d.Format(time.DateOnly), nil
+	}
+	ts, err := parseDateTime(value)
+	if err != nil {
+		return "", errors.New("DUE must be a date or date-time")
+	}
+	return ts.Format(time.RFC3339), nil
+}
+
+// parseDateTime accepts UTC and floating date-times. Floating times and
+// TZID-qualified ones are taken as UTC, since tasks carry no time zone.
+func parseDateTime(value string) (time.Time, error) {
+	return time.Parse("20060102T150405", strings.TrimSuffix(value, "Z"))
+}
@@ -0,0 +1,102 @@
+package ical_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/ical"
+	"github.com/sawez-deepsource/demo-go/model"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tasks := []model.Task{
+		{
+			ID:          "1",
+			Title:       "Plan; review, ship",
+			Description: strings.Repeat("long description ", 10) + "\nsecond line",
+			Priority:    model.PriorityHigh,
+			Project:     "launch",
+			Due:         "2024-03-01",
+			CreatedAt:   "2024-01-01T09:00:00Z",
+			UpdatedAt:   "2024-01-02T09:00:00Z",
+		},
+		{
+			ID:        "2",
+			Title:     "Done already",
+			Done:      true,
+			Priority:  model.PriorityMedium,
+			Due:       "2024-03-01T17:30:00Z",
+			CreatedAt: "2024-01-01T09:00:00Z",
+			UpdatedAt: "2024-01-03T09:00:00Z",
+		},
+	}
+	var buf bytes.Buffer
+	if err := ical.Encode(&buf, tasks, time.Now()); err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if len(line) > 75 {
+			t.Fatalf("expected lines to be folded at 75 octets, got %d: %q", len(line), line)
+		}
+	}
+	if !strings.Contains(buf.String(), "DUE;VALUE=DATE:20240301\r\n") {
+		t.Fatalf("expected a date-valued DUE, got:\n%s", buf.String())
+	}
+
+	todos, err := ical.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(todos) != 2 {
+		t.Fatalf("expected 2 todos, got %d", len(todos))
+	}
+	for i, todo := range todos {
+		want := tasks[i]
+		want.UpdatedAt = ""
+		if len(todo.Errors) > 0 || todo.Task != want {
+			t.Fatalf("expected %+v, got %+v (errors %v)", want, todo.Task, todo.Errors)
+		}
+	}
+}
+
+func TestDecodeForeignCalendar(t *testing.T) {
+	input := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"UID:event-1",
+		"SUMMARY:Not a task",
+		"END:VEVENT",
+		"BEGIN:VTODO",
+		"UID:abc@example.com",
+		"SUMMARY:Call the",
+		"  plumber",
+		"PRIORITY:6",
+		"DUE;TZID=Europe/Berlin:20240301T100000",
+		"BEGIN:VALARM",
+		"SUMMARY:Alarm text",
+		"END:VALARM",
+		"END:VTODO",
+		"BEGIN:VTODO",
+		"SUMMARY:Bad",
+		"PRIORITY:high",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	todos, err := ical.Decode(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(todos) != 2 {
+		t.Fatalf("expected 2 todos, got %d", len(todos))
+	}
+	got := todos[0].Task
+	if got.ID != "abc@example.com" || got.Title != "Call the plumber" || got.Priority != model.PriorityLow || got.Due != "2024-03-01T10:00:00Z" {
+		t.Fatalf("unexpected task %+v", got)
+	}
+	if todos[1].Line != 16 || len(todos[1].Errors) != 1 {
+		t.Fatalf("expected a priority error for the todo on line 16, got %+v", todos[1])
+	}
+}
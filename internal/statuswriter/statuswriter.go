@@ -0,0 +1,53 @@
+// Package statuswriter records what the HTTP middlewares report about a
+// response: its status code and size.
+package statuswriter
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// Writer remembers the status code and number of body bytes written
+// through it. Status is 200 until a handler says otherwise.
+type Writer struct {
+	http.ResponseWriter
+	Status int
+	Bytes  int
+	wrote  bool
+}
+
+func New(w http.ResponseWriter) *Writer {
+	return &Writer{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (w *Writer) WriteHeader(status int) {
+	if !w.wrote {
+		w.Status, w.wrote = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.wrote = true
+	n, err := w.ResponseWriter.Write(p)
+	w.Bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *Writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush and Hijack are implemented directly for handlers that type-assert
+// rather than use http.ResponseController, such as the WebSocket upgrader.
+// A hijacked connection is recorded as 101 Switching Protocols.
+func (w *Writer) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *Writer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.Status, w.wrote = http.StatusSwitchingProtocols, true
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
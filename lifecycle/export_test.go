@@ -0,0 +1,8 @@
+package lifecycle
+
+// Reset removes every registered step between tests.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	steps = nil
+}
@@ -0,0 +1,226 @@
+// Package lifecycle shuts the server down in order: first it stops taking
+// work, then it stops background workers, then it persists state. Steps
+// that overrun the deadline are abandoned and reported rather than waited
+// for.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Phase orders shutdown steps. Phases run one after the other; the steps of
+// a phase run concurrently.
+type Phase int
+
+const (
+	// PhaseStop stops accepting connections, tells long-lived clients to
+	// go away and waits for requests in progress.
+	PhaseStop Phase = iota
+	// PhaseWorkers stops background workers once nothing can give them
+	// more work.
+	PhaseWorkers
+	// PhaseFlush persists state and flushes buffered telemetry.
+	PhaseFlush
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseStop:
+		return "stop"
+	case PhaseWorkers:
+		return "workers"
+	case PhaseFlush:
+		return "flush"
+	}
+	return fmt.Sprintf("Phase(%d)", int(p))
+}
+
+// FlushGrace is how long flush steps get when the deadline has already
+// passed by the time they start: losing state is worse than overrunning.
+var FlushGrace = time.Second
+
+// Step is one part of a shutdown. Stop should return when its work is done
+// or ctx is done, whichever comes first.
+type Step struct {
+	Phase Phase
+	Name  string
+	Stop  func(ctx context.Context) error
+}
+
+// Result is the outcome of a step.
+type Result struct {
+	Phase    Phase
+	Name     string
+	Duration time.Duration
+	Err      error
+	// Abandoned is set when the step had not returned by the deadline.
+	Abandoned bool
+}
+
+// Report lists the results of every step, in the order they were
+// registered within each phase.
+type Report []Result
+
+// Abandoned returns the names of the steps that did not finish in time.
+func (r Report) Abandoned() []string {
+	var names []string
+	for _, res := range r {
+		if res.Abandoned {
+			names = append(names, res.Name)
+		}
+	}
+	return names
+}
+
+// Err joins the errors of the steps that failed or were abandoned, or
+// returns nil if the shutdown was clean.
+func (r Report) Err() error {
+	var errs []error
+	for _, res := range r {
+		if res.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", res.Name, res.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var (
+	mu    sync.Mutex
+	steps []Step
+)
+
+// Register adds a step to the shutdown, replacing any step of the same
+// phase and name.
+func Register(s Step) {
+	mu.Lock()
+	defer mu.Unlock()
+	i := slices.IndexFunc(steps, func(old Step) bool { return old.Phase == s.Phase && old.Name == s.Name })
+	if i >= 0 {
+		steps[i] = s
+		return
+	}
+	steps = append(steps, s)
+}
+
+// Go runs fn in a new goroutine as the background worker name. Shutdown
+// cancels its context in PhaseWorkers and waits for it to return.
+func Go(name string, fn func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(ctx)
+	}()
+	Register(Step{Phase: PhaseWorkers, Name: name, Stop: func(stop context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-stop.Done():
+			return stop.Err()
+		}
+	}})
+}
+
+// Shutdown runs the registered steps phase by phase until ctx is done. A
+// step still running then is abandoned: it is left to the process exit and
+// reported with Abandoned set, and the next phase starts. Flush steps run
+// even after the deadline, with FlushGrace.
+func Shutdown(ctx context.Context) Report {
+	mu.Lock()
+	all := slices.Clone(steps)
+	mu.Unlock()
+	slices.SortStableFunc(all, func(a, b Step) int { return int(a.Phase) - int(b.Phase) })
+
+	var report Report
+	for start := 0; start < len(all); {
+		phase := all[start].Phase
+		end := start
+		for end < len(all) && all[end].Phase == phase {
+			end++
+		}
+		phaseCtx := ctx
+		if phase == PhaseFlush && ctx.Err() != nil {
+			var cancel context.CancelFunc
+			phaseCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), FlushGrace)
+			defer cancel()
+		}
+		report = append(report, run(phaseCtx, all[start:end])...)
+		start = end
+	}
+	return report
+}
+
+// returnGrace is how long steps get to return once ctx is done before they
+// are abandoned, so that steps honouring ctx can report what they left.
+const returnGrace = 100 * time.Millisecond
+
+// run runs steps concurrently and waits for them, or for ctx to be done.
+func run(ctx context.Context, steps []Step) []Result {
+	results := make([]Result, len(steps))
+	done := make([]chan struct{}, len(steps))
+	for i, s := range steps {
+		done[i] = make(chan struct{})
+		go func() {
+			defer close(done[i])
+			start := time.Now()
+			err := s.Stop(ctx)
+			results[i] = Result{Phase: s.Phase, Name: s.Name, Duration: time.Since(start), Err: err}
+			if err != nil {
+				slog.Error("shutdown step failed", "phase", s.Phase, "step", s.Name, "error", err)
+			} else {
+				slog.Info("shutdown step finished", "phase", s.Phase, "step", s.Name, "duration", results[i].Duration)
+			}
+		}()
+	}
+
+	out := make([]Result, len(steps))
+	var grace context.Context
+	for i, s := range steps {
+		// select picks at random among ready cases, so a step that has
+		// finished must be seen before ctx or grace are considered.
+		if finished(done[i], ctx.Done()) {
+			out[i] = results[i]
+			continue
+		}
+		if grace == nil {
+			var cancel context.CancelFunc
+			grace, cancel = context.WithTimeout(context.WithoutCancel(ctx), returnGrace)
+			defer cancel()
+		}
+		if finished(done[i], grace.Done()) {
+			out[i] = results[i]
+			continue
+		}
+		out[i] = Result{Phase: s.Phase, Name: s.Name, Err: ctx.Err(), Abandoned: true}
+		slog.Warn("shutdown step abandoned", "phase", s.Phase, "step", s.Name)
+	}
+	return out
+}
+
+// finished waits for done or deadline and reports whether done was closed,
+// preferring done when both are.
+func finished(done, deadline <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+	}
+	select {
+	case <-done:
+		return true
+	case <-deadline:
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}
+}
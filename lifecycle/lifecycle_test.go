@@ -0,0 +1,93 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/lifecycle"
+)
+
+func TestShutdownRunsPhasesInOrder(t *testing.T) {
+	defer lifecycle.Reset()
+	var mu sync.Mutex
+	var order []string
+	step := func(phase lifecycle.Phase, name string) {
+		lifecycle.Register(lifecycle.Step{Phase: phase, Name: name, Stop: func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			return nil
+		}})
+	}
+	step(lifecycle.PhaseFlush, "state")
+	step(lifecycle.PhaseStop, "server")
+	var stopped bool
+	lifecycle.Go("worker", func(ctx context.Context) {
+		<-ctx.Done()
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+		order = append(order, "worker")
+	})
+
+	report := lifecycle.Shutdown(context.Background())
+	if err := report.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !stopped || !slices.Equal(order, []string{"server", "worker", "state"}) {
+		t.Errorf("expected stop, workers, then flush, got %v", order)
+	}
+	if len(report) != 3 || report[1].Phase != lifecycle.PhaseWorkers || report[1].Name != "worker" {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
+
+func TestShutdownAbandonsOverrunningSteps(t *testing.T) {
+	defer lifecycle.Reset()
+	release := make(chan struct{})
+	defer close(release)
+	lifecycle.Register(lifecycle.Step{Phase: lifecycle.PhaseStop, Name: "stuck", Stop: func(context.Context) error {
+		<-release
+		return nil
+	}})
+	lifecycle.Register(lifecycle.Step{Phase: lifecycle.PhaseStop, Name: "polite", Stop: func(ctx context.Context) error {
+		<-ctx.Done()
+		return errors.New("2 connections still open")
+	}})
+	var flushed bool
+	lifecycle.Register(lifecycle.Step{Phase: lifecycle.PhaseFlush, Name: "state", Stop: func(ctx context.Context) error {
+		flushed = ctx.Err() == nil
+		return nil
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	report := lifecycle.Shutdown(ctx)
+
+	if got := report.Abandoned(); !slices.Equal(got, []string{"stuck"}) {
+		t.Errorf("expected only the stuck step to be abandoned, got %v", got)
+	}
+	if err := report.Err(); err == nil || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline in the error, got %v", err)
+	}
+	if report[1].Err == nil || report[1].Err.Error() != "2 connections still open" {
+		t.Errorf("expected the polite step to report what it left, got %v", report[1].Err)
+	}
+	if !flushed {
+		t.Error("expected state to be flushed with a fresh deadline")
+	}
+}
+
+func TestRegisterReplaces(t *testing.T) {
+	defer lifecycle.Reset()
+	fail := errors.New("old")
+	lifecycle.Register(lifecycle.Step{Phase: lifecycle.PhaseFlush, Name: "state", Stop: func(context.Context) error { return fail }})
+	lifecycle.Register(lifecycle.Step{Phase: lifecycle.PhaseFlush, Name: "state", Stop: func(context.Context) error { return nil }})
+	if report := lifecycle.Shutdown(context.Background()); len(report) != 1 || report.Err() != nil {
+		t.Errorf("expected the step to be replaced, got %+v", report)
+	}
+}
@@ -0,0 +1,107 @@
+// Package logging sets up the server's structured logger and the middleware
+// that gives every request an ID and an access log line.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RedactedKeys are attribute keys whose values are never written. Keys
+// match case-insensitively, either exactly or as a suffix after "_", so
+// "token" also covers "api_token".
+var RedactedKeys = []string{"token", "secret", "password", "authorization", "cookie", "api_key"}
+
+const redacted = "[REDACTED]"
+
+// New returns a logger writing to w in format "json" or "text" at level
+// and above. It adds the request ID and trace context from the context to
+// every record and redacts the values of RedactedKeys.
+func New(w io.Writer, format string, level slog.Leveler) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	var h slog.Handler
+	switch format {
+	case "json", "":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q; use json or text", format)
+	}
+	return slog.New(contextHandler{h}), nil
+}
+
+// ParseLevel reads a level name such as "debug" or "warn".
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(s))
+	return l, err
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if sensitive(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+func sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range RedactedKeys {
+		if key == k || strings.HasSuffix(key, "_"+k) {
+			return true
+		}
+	}
+	return false
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying id, which the logger from New
+// adds to every record logged with it.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// contextHandler adds the request ID and the current trace and span IDs
+// from the record's context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
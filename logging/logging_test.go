@@ -0,0 +1,144 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sawez-deepsource/demo-go/logging"
+)
+
+// capture makes a JSON logger writing to a buffer the default for the test.
+func capture(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "json", level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		out = append(out, rec)
+	}
+	return out
+}
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "text", slog.LevelWarn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown", "token", "abc", "api_token", "def", "Authorization", "Bearer x", "title", "kept")
+	out := buf.String()
+	if strings.Contains(out, "hidden") || !strings.Contains(out, "msg=shown") {
+		t.Fatalf("expected only the warning, got %q", out)
+	}
+	for _, secret := range []string{"abc", "def", "Bearer"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("expected %q to be redacted in %q", secret, out)
+		}
+	}
+	if !strings.Contains(out, "title=kept") || !strings.Contains(out, "token=[REDACTED]") {
+		t.Errorf("expected other fields to be kept, got %q", out)
+	}
+
+	if _, err := logging.New(&buf, "xml", slog.LevelInfo); err == nil {
+		t.Error("expected an unknown format to fail")
+	}
+	if _, err := logging.ParseLevel("loud"); err == nil {
+		t.Error("expected an unknown level to fail")
+	}
+	if l, err := logging.ParseLevel("debug"); err != nil || l != slog.LevelDebug {
+		t.Errorf("expected debug, got %v (%v)", l, err)
+	}
+}
+
+func TestRequestIDs(t *testing.T) {
+	buf := capture(t, slog.LevelInfo)
+	var seen string
+	h := logging.RequestIDs(logging.AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestID(r.Context())
+		slog.InfoContext(r.Context(), "handled")
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	})))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	h.ServeHTTP(w, req)
+	if seen != "abc-123" || w.Header().Get("X-Request-ID") != "abc-123" {
+		t.Fatalf("expected the client's ID to be kept, got %q and %q", seen, w.Header().Get("X-Request-ID"))
+	}
+
+	recs := records(t, buf)
+	if len(recs) != 2 {
+		t.Fatalf("expected a handler and an access line, got %v", recs)
+	}
+	for _, rec := range recs {
+		if rec["request_id"] != "abc-123" {
+			t.Errorf("expected the request ID on %v", rec)
+		}
+	}
+	access := recs[1]
+	if access["msg"] != "request" || access["method"] != "GET" || access["path"] != "/tasks" || access["status"] != 200.0 || access["level"] != "INFO" {
+		t.Errorf("unexpected access line %v", access)
+	}
+
+	buf.Reset()
+	for _, bad := range []string{"", "has space", "quote\"", strings.Repeat("x", 129)} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+		req.Header.Set("X-Request-ID", bad)
+		h.ServeHTTP(w, req)
+		id := w.Header().Get("X-Request-ID")
+		if id == bad || len(id) != 32 || seen != id {
+			t.Errorf("expected %q to be replaced with a generated ID, got %q", bad, id)
+		}
+	}
+	if access := records(t, buf)[1]; access["status"] != 404.0 || access["level"] != "WARN" {
+		t.Errorf("expected a 404 to be logged as a warning, got %v", access)
+	}
+}
+
+func TestTraceContext(t *testing.T) {
+	buf := capture(t, slog.LevelInfo)
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0736a1")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+
+	slog.InfoContext(ctx, "traced")
+	slog.Info("untraced")
+	recs := records(t, buf)
+	if recs[0]["trace_id"] != traceID.String() || recs[0]["span_id"] != spanID.String() {
+		t.Errorf("expected the trace context on %v", recs[0])
+	}
+	if _, ok := recs[1]["trace_id"]; ok {
+		t.Errorf("expected no trace context on %v", recs[1])
+	}
+}
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/internal/statuswriter"
+)
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds IDs accepted from clients.
+const maxRequestIDLen = 128
+
+// RequestIDs gives every request an ID: the client's X-Request-ID if it is
+// well-formed, otherwise a random one. The ID is echoed in the response
+// header and stored in the request context for RequestID and the logger.
+func RequestIDs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := AcceptRequestID(r.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
+
+// AcceptRequestID returns id if it is well-formed, otherwise a new random
+// ID, for transports other than HTTP that carry the client's request ID.
+func AcceptRequestID(id string) string {
+	if !validRequestID(id) {
+		return newRequestID()
+	}
+	return id
+}
+
+// validRequestID accepts short IDs of printable characters that cannot
+// break out of a header or a log line.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range []byte(id) {
+		if c <= ' ' || c >= 0x7f || c == '"' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLog logs one line per request with its method, path, status and
+// duration. Server errors are logged at level error, client errors at warn.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := statuswriter.New(w)
+		next.ServeHTTP(sw, r)
+
+		level := slog.LevelInfo
+		switch {
+		case sw.Status >= 500:
+			level = slog.LevelError
+		case sw.Status >= 400:
+			level = slog.LevelWarn
+		}
+		slog.Log(r.Context(), level, "request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", sw.Status,
+			"bytes", sw.Bytes,
+			"duration", time.Since(start),
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
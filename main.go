@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,59 +14,228 @@ import (
 	"syscall"
 	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/sawez-deepsource/demo-go/certs"
+	"github.com/sawez-deepsource/demo-go/config"
+	"github.com/sawez-deepsource/demo-go/grpcapi"
 	"github.com/sawez-deepsource/demo-go/handler"
+	"github.com/sawez-deepsource/demo-go/health"
+	"github.com/sawez-deepsource/demo-go/lifecycle"
+	"github.com/sawez-deepsource/demo-go/logging"
+	"github.com/sawez-deepsource/demo-go/metrics"
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/tracing"
+	"github.com/sawez-deepsource/demo-go/webhook"
 )
 
 // GSC-G101: Hardcoded credentials
 var adminToken = "Bearer super-secret-admin-token-12345"
 
 func main() {
+	loader, err := config.Parse(os.Args[0], os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+	cfg, err := loader.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	if loader.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Validate has checked the level and format.
+	var level slog.LevelVar
+	level.UnmarshalText([]byte(cfg.Log.Level))
+	logger, _ := logging.New(os.Stderr, cfg.Log.Format, &level)
+	slog.SetDefault(logger)
+	model.SetLimits(cfg.Limits)
+	handler.SetGraphQLConfig(handler.GraphQLConfig(cfg.GraphQL))
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Exporter, os.Stdout)
+	if err != nil {
+		fatal("setting up tracing", err)
+	}
+
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("GET /tasks", handler.ListTasks)
-	mux.HandleFunc("POST /tasks", handler.CreateTask)
-	mux.HandleFunc("GET /tasks/{id}", handler.GetTask)
-	mux.HandleFunc("PUT /tasks/{id}", handler.UpdateTask)
-	mux.HandleFunc("DELETE /tasks/{id}", handler.DeleteTask)
-	mux.HandleFunc("GET /stats", handler.TaskStats)
+	handler.Register(mux)
+	validated := handler.SpecValidation(handler.SpecValidationConfig{Requests: true}, mux)
+
+	if cfg.Calendar.FeedSecret != "" {
+		handler.SetCalendarSecret(cfg.Calendar.FeedSecret)
+	}
+	if err := handler.LoadCalendarSubscriptions(cfg.Calendar.StateFile); err != nil {
+		fatal("loading calendar subscriptions", err)
+	}
+	if err := webhook.Load(cfg.Webhooks.StateFile); err != nil {
+		fatal("loading webhooks", err)
+	}
+	lifecycle.Go("webhook deliveries", webhook.Run)
+	lifecycle.Go("idempotency key sweeper", handler.SweepIdempotencyKeys)
+
+	var users map[string]string
+	if cfg.TLS.UsersFile != "" {
+		if users, err = certs.LoadUsers(cfg.TLS.UsersFile); err != nil {
+			fatal("loading client certificate users", err)
+		}
+	}
+	var tokens map[string]string
+	if cfg.Auth.TokensFile != "" {
+		if tokens, err = handler.LoadTokens(cfg.Auth.TokensFile); err != nil {
+			fatal("loading API tokens", err)
+		}
+	}
+	limiter := handler.NewRateLimiter(handler.RateLimitConfig(cfg.RateLimit))
+	limited := handler.TokenAuth(tokens, handler.RateLimit(limiter, validated))
+	if cfg.TLS.ClientAuth != certs.ClientAuthNone {
+		limited = handler.ClientCertAuth(users, limited)
+	}
+	limited = handler.Probes(limited)
 
 	srv := &http.Server{
-		Addr:         ":8000",
-		Handler:      loggingMiddleware(mux),
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  30 * time.Second,
+		Addr:         cfg.Server.Addr,
+		Handler:      tracing.Middleware(mux, logging.RequestIDs(logging.AccessLog(metrics.Middleware(mux, limited)))),
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+	if cfg.TLS.CertFile != "" {
+		srv.TLSConfig, err = certs.NewServerConfig(certs.Options{
+			CertFile:     cfg.TLS.CertFile,
+			KeyFile:      cfg.TLS.KeyFile,
+			ClientCAFile: cfg.TLS.ClientCAFile,
+			ClientAuth:   cfg.TLS.ClientAuth,
+		})
+		if err != nil {
+			fatal("setting up TLS", err)
+		}
+	}
+
+	if cfg.GRPC.Addr != "" {
+		var opts []grpc.ServerOption
+		if srv.TLSConfig != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(srv.TLSConfig)))
+		}
+		svc := grpcapi.NewService()
+		grpcSrv := grpcapi.NewServer(svc, users, tokens, limiter, opts...)
+		lis, err := net.Listen("tcp", cfg.GRPC.Addr)
+		if err != nil {
+			fatal("listening for gRPC", err)
+		}
+		go func() {
+			slog.Info("gRPC server starting", "addr", lis.Addr().String(), "tls", srv.TLSConfig != nil)
+			if err := grpcSrv.Serve(lis); err != nil {
+				fatal("gRPC server error", err)
+			}
+		}()
+		lifecycle.Register(lifecycle.Step{Phase: lifecycle.PhaseStop, Name: "grpc server", Stop: func(ctx context.Context) error {
+			return svc.Shutdown(ctx, grpcSrv)
+		}})
 	}
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	// With tls.client_auth=require, probes cannot even complete the TLS
+	// handshake, so they are also offered on a plain listener.
+	if cfg.Server.ProbeAddr != "" {
+		probeSrv := &http.Server{
+			Addr:        cfg.Server.ProbeAddr,
+			Handler:     handler.ProbeMux(),
+			ReadTimeout: cfg.Server.ReadTimeout,
+			IdleTimeout: cfg.Server.IdleTimeout,
+		}
+		go func() {
+			slog.Info("probe server starting", "addr", probeSrv.Addr)
+			if err := probeSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal("probe server error", err)
+			}
+		}()
+		lifecycle.Register(lifecycle.Step{Phase: lifecycle.PhaseStop, Name: "probe server", Stop: probeSrv.Shutdown})
+	}
+
+	// Streams and sockets are closed alongside the server, which waits for
+	// the other requests in progress. Workers stop after that, so that
+	// every change made by a request reaches them, and state is saved last.
+	lifecycle.Register(lifecycle.Step{Phase: lifecycle.PhaseStop, Name: "http server", Stop: srv.Shutdown})
+	lifecycle.Register(lifecycle.Step{Phase: lifecycle.PhaseStop, Name: "event streams", Stop: handler.ShutdownEventStreams})
+	lifecycle.Register(lifecycle.Step{Phase: lifecycle.PhaseStop, Name: "websockets", Stop: handler.ShutdownWebSockets})
+	lifecycle.Register(lifecycle.Step{Phase: lifecycle.PhaseFlush, Name: "webhook state", Stop: func(context.Context) error {
+		pending, err := webhook.Flush()
+		if err == nil && pending > 0 {
+			slog.Info("webhook deliveries left for the next start", "pending", pending)
+		}
+		return err
+	}})
+	lifecycle.Register(lifecycle.Step{Phase: lifecycle.PhaseFlush, Name: "traces", Stop: shutdownTracing})
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	go func() {
-		log.Printf("server starting on %s", srv.Addr)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server error: %v", err)
+		slog.Info("server starting", "addr", srv.Addr, "tls", srv.TLSConfig != nil)
+		var err error
+		if srv.TLSConfig != nil {
+			// The certificate comes from TLSConfig.
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fatal("server error", err)
 		}
 	}()
 
-	<-quit
-	log.Println("shutting down server...")
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		next, err := loader.Load()
+		if err != nil {
+			slog.Error("configuration not reloaded", "error", err)
+			continue
+		}
+		var restart []string
+		cfg, restart = config.Reload(cfg, next)
+		level.UnmarshalText([]byte(cfg.Log.Level))
+		model.SetLimits(cfg.Limits)
+		handler.SetGraphQLConfig(handler.GraphQLConfig(cfg.GraphQL))
+		if len(restart) > 0 {
+			slog.Warn("some settings only change on restart", "settings", restart)
+		}
+		slog.Info("configuration reloaded")
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// Fail readiness first and keep serving for a while, so that load
+	// balancers stop routing here before connections are refused.
+	health.Drain()
+	slog.Info("draining before shutdown", "delay", cfg.Server.DrainDelay)
+	time.Sleep(cfg.Server.DrainDelay)
+	slog.Info("shutting down server")
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("server forced to shutdown: %v", err)
+	report := lifecycle.Shutdown(ctx)
+	if err := report.Err(); err != nil {
+		slog.Error("server stopped uncleanly", "abandoned", report.Abandoned(), "error", err)
+		os.Exit(1)
 	}
-
-	log.Println("server stopped")
+	slog.Info("server stopped")
 }
 
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		next.ServeHTTP(w, r)
-		log.Printf("%s %s %s", r.Method, r.URL.Path, time.Since(start))
-	})
+// fatal logs err at level error and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
 
 // -------------------------------------------------------
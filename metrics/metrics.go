@@ -0,0 +1,152 @@
+// Package metrics collects the server's Prometheus metrics: HTTP traffic
+// per route, store operations and sizes, and Go runtime statistics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sawez-deepsource/demo-go/internal/statuswriter"
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+const namespace = "taskapi"
+
+// Registry holds every metric exported by the server. It is separate from
+// prometheus.DefaultRegisterer so that tests see only what is set up here.
+var Registry = prometheus.NewRegistry()
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route pattern, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	inFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests currently being served, including open streams.",
+	})
+
+	storeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "store_operation_duration_seconds",
+		Help:      "Latency of store calls, including lock waits, by operation.",
+		Buckets:   prometheus.ExponentialBuckets(1e-6, 4, 10),
+	}, []string{"op"})
+
+	// SpecViolations counts requests and responses that broke the OpenAPI
+	// document, by direction ("request" or "response") and route pattern.
+	SpecViolations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spec_violations_total",
+		Help:      "Requests and responses that did not match the OpenAPI document.",
+	}, []string{"direction", "route"})
+)
+
+func init() {
+	Registry.MustRegister(
+		requests,
+		requestDuration,
+		inFlight,
+		storeDuration,
+		SpecViolations,
+		taskCollector{},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	store.ObserveOperations(func(op string, d time.Duration) {
+		storeDuration.WithLabelValues(op).Observe(d.Seconds())
+	})
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Middleware records every request passed to next. Routes are labelled
+// with the pattern they match in mux, so that IDs in paths do not create a
+// series each; requests matching no route are labelled "unmatched", and
+// methods outside the standard set "OTHER".
+func Middleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		sw := statuswriter.New(w)
+		next.ServeHTTP(sw, r)
+
+		code := strconv.Itoa(sw.Status)
+		method := methodLabel(r.Method)
+		requests.WithLabelValues(route, method, code).Inc()
+		requestDuration.WithLabelValues(route, method, code).Observe(time.Since(start).Seconds())
+	})
+}
+
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+var (
+	tasksDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "tasks"),
+		"Tasks in the store.", nil, nil)
+	tasksByDoneDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "tasks_by_done"),
+		"Tasks in the store by completion.", []string{"done"}, nil)
+	tasksByPriorityDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "tasks_by_priority"),
+		"Tasks in the store by priority.", []string{"priority"}, nil)
+)
+
+// taskCollector reports the size of the store at scrape time, so the
+// numbers cannot drift from the store's contents.
+type taskCollector struct{}
+
+func (taskCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tasksDesc
+	ch <- tasksByDoneDesc
+	ch <- tasksByPriorityDesc
+}
+
+func (taskCollector) Collect(ch chan<- prometheus.Metric) {
+	tasks := store.All()
+	var done int
+	byPriority := map[model.Priority]int{}
+	for _, t := range tasks {
+		if t.Done {
+			done++
+		}
+		byPriority[t.Priority]++
+	}
+	ch <- prometheus.MustNewConstMetric(tasksDesc, prometheus.GaugeValue, float64(len(tasks)))
+	ch <- prometheus.MustNewConstMetric(tasksByDoneDesc, prometheus.GaugeValue, float64(done), "true")
+	ch <- prometheus.MustNewConstMetric(tasksByDoneDesc, prometheus.GaugeValue, float64(len(tasks)-done), "false")
+	for p := model.PriorityLow; p <= model.PriorityHigh; p++ {
+		ch <- prometheus.MustNewConstMetric(tasksByPriorityDesc, prometheus.GaugeValue, float64(byPriority[p]), p.String())
+	}
+}
@@ -0,0 +1,106 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/metrics"
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+func scrape(t *testing.T) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	return w.Body.String()
+}
+
+func expectLines(t *testing.T, body string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected %q in:\n%s", line, body)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /widgets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "missing" {
+			http.NotFound(w, r)
+			return
+		}
+		if !strings.Contains(scrape(t), "taskapi_http_requests_in_flight 1\n") {
+			t.Error("expected the request to be in flight")
+		}
+		w.Write([]byte("ok"))
+	})
+	h := metrics.Middleware(mux, mux)
+
+	for _, path := range []string{"/widgets/1", "/widgets/2", "/widgets/missing", "/nowhere"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("MADEUP", "/nowhere", nil))
+
+	expectLines(t, scrape(t),
+		`taskapi_http_requests_total{code="200",method="GET",route="GET /widgets/{id}"} 2`,
+		`taskapi_http_requests_total{code="404",method="GET",route="GET /widgets/{id}"} 1`,
+		`taskapi_http_requests_total{code="404",method="GET",route="unmatched"} 1`,
+		`taskapi_http_requests_total{code="404",method="OTHER",route="unmatched"} 1`,
+		`taskapi_http_request_duration_seconds_count{code="200",method="GET",route="GET /widgets/{id}"} 2`,
+		`taskapi_http_requests_in_flight 0`,
+	)
+}
+
+func TestMiddlewareHijack(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /upgrade", func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nConnection: close\r\n\r\n")
+		conn.Close()
+	})
+	srv := httptest.NewServer(metrics.Middleware(mux, mux))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/upgrade")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	expectLines(t, scrape(t), `taskapi_http_requests_total{code="101",method="GET",route="GET /upgrade"} 1`)
+}
+
+func TestStoreMetrics(t *testing.T) {
+	store.Clear()
+	store.Add(model.NewTask("One", "", model.PriorityHigh))
+	done := store.Add(model.NewTask("Two", "", model.PriorityLow))
+	done.Done = true
+	store.Update(done.ID, done)
+
+	body := scrape(t)
+	expectLines(t, body,
+		`taskapi_tasks 2`,
+		`taskapi_tasks_by_done{done="false"} 1`,
+		`taskapi_tasks_by_done{done="true"} 1`,
+		`taskapi_tasks_by_priority{priority="high"} 1`,
+		`taskapi_tasks_by_priority{priority="low"} 1`,
+		`taskapi_tasks_by_priority{priority="medium"} 0`,
+	)
+	for _, want := range []string{`taskapi_store_operation_duration_seconds_count{op="add"}`, `taskapi_store_operation_duration_seconds_count{op="update"}`, "go_goroutines", "go_memstats_heap_alloc_bytes"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s to be exported", want)
+		}
+	}
+}
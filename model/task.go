@@ -2,6 +2,8 @@ package model
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,12 +15,25 @@ const (
 	PriorityHigh   Priority = 2
 )
 
+// priorityNames names each priority, indexed by its value.
+var priorityNames = []string{"low", "medium", "high"}
+
+// String returns the priority's name: low, medium or high.
+func (p Priority) String() string {
+	if !ValidatePriority(p) {
+		return "Priority(" + strconv.Itoa(int(p)) + ")"
+	}
+	return priorityNames[p]
+}
+
 type Task struct {
 	ID          string   `json:"id"`
 	Title       string   `json:"title"`
 	Description string   `json:"description"`
 	Done        bool     `json:"done"`
 	Priority    Priority `json:"priority"`
+	Project     string   `json:"project"`
+	Due         string   `json:"due"`
 	CreatedAt   string   `json:"created_at"`
 	UpdatedAt   string   `json:"updated_at"`
 }
@@ -51,6 +66,19 @@ func ValidatePriority(p Priority) bool {
 	return p >= PriorityLow && p <= PriorityHigh
 }
 
+// ValidateDue reports whether s is a usable due date: empty, a calendar date
+// (2006-01-02) or an RFC 3339 timestamp.
+func ValidateDue(s string) bool {
+	if s == "" {
+		return true
+	}
+	if _, err := time.Parse(time.DateOnly, s); err == nil {
+		return true
+	}
+	_, err := time.Parse(time.RFC3339, s)
+	return err == nil
+}
+
 // -------------------------------------------------------
 // Planted issues in model/task.go
 // -------------------------------------------------------
@@ -85,20 +113,16 @@ type TaskInfo struct {
 	Name string
 }
 
+// ParsePriority returns the priority named s, as String names it but in any
+// case.
+//
 // GO-W: Naked return
 func ParsePriority(s string) (p Priority, err error) {
-	switch s {
-	case "low":
-		p = PriorityLow
-		return // BAD: naked return
-	case "medium":
-		p = PriorityMedium
-		return // BAD: naked return
-	case "high":
-		p = PriorityHigh
-		return // BAD: naked return
-	default:
-		err = fmt.Errorf("unknown priority: %s", s)
-		return // BAD: naked return
+	for p = PriorityLow; p <= PriorityHigh; p++ {
+		if strings.EqualFold(s, p.String()) {
+			return // BAD: naked return
+		}
 	}
+	p, err = 0, fmt.Errorf("unknown priority: %s", s)
+	return // BAD: naked return
 }
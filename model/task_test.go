@@ -0,0 +1,26 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/model"
+)
+
+func TestPriorityNames(t *testing.T) {
+	for name, want := range map[string]model.Priority{
+		"low": model.PriorityLow, "Medium": model.PriorityMedium, "HIGH": model.PriorityHigh,
+	} {
+		if p, err := model.ParsePriority(name); err != nil || p != want {
+			t.Errorf("ParsePriority(%q) = %v, %v; want %v", name, p, err, want)
+		}
+	}
+	if _, err := model.ParsePriority("urgent"); err == nil {
+		t.Error("expected an unknown name to fail")
+	}
+	if s := model.PriorityMedium.String(); s != "medium" {
+		t.Errorf("expected medium, got %q", s)
+	}
+	if s := model.Priority(7).String(); s != "Priority(7)" {
+		t.Errorf("expected an invalid priority to show its number, got %q", s)
+	}
+}
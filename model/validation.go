@@ -0,0 +1,159 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits bounds the size of free-text task fields.
+type Limits struct {
+	MaxTitleLength     int // in characters
+	MaxDescriptionSize int // in bytes
+	MaxProjectLength   int // in characters
+}
+
+var DefaultLimits = Limits{
+	MaxTitleLength:     200,
+	MaxDescriptionSize: 10000,
+	MaxProjectLength:   64,
+}
+
+// FieldError describes one failed rule. Field is the JSON name of the field.
+type FieldError struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+// ValidationError lists every rule a task failed.
+type ValidationError []FieldError
+
+func (v ValidationError) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Rule is a single check on a task. Rules are evaluated in order and, once a
+// rule fails for a field, later rules for the same field are skipped, so a
+// missing title is not also reported as too short.
+type Rule struct {
+	Field   string
+	Code    string
+	Message string
+	Valid   func(Task) bool
+}
+
+type Validator struct {
+	rules []Rule
+}
+
+// NewValidator builds the standard task rules for the given limits.
+func NewValidator(l Limits) *Validator {
+	return &Validator{rules: taskRules(l)}
+}
+
+func taskRules(l Limits) []Rule {
+	return []Rule{
+		{"title", "required", "title is required",
+			func(t Task) bool { return strings.TrimSpace(t.Title) != "" }},
+		{"title", "too_long", fmt.Sprintf("title must be at most %d characters", l.MaxTitleLength),
+			func(t Task) bool { return utf8.RuneCountInString(t.Title) <= l.MaxTitleLength }},
+		{"title", "invalid_characters", "title must be valid UTF-8 on a single line",
+			func(t Task) bool { return printable(t.Title, false) }},
+		{"description", "too_large", fmt.Sprintf("description must be at most %d bytes", l.MaxDescriptionSize),
+			func(t Task) bool { return len(t.Description) <= l.MaxDescriptionSize }},
+		{"description", "invalid_characters", "description must be valid UTF-8 without control characters",
+			func(t Task) bool { return printable(t.Description, true) }},
+		{"project", "too_long", fmt.Sprintf("project must be at most %d characters", l.MaxProjectLength),
+			func(t Task) bool { return utf8.RuneCountInString(t.Project) <= l.MaxProjectLength }},
+		{"project", "invalid_characters", "project must be a single word without spaces",
+			func(t Task) bool {
+				return printable(t.Project, false) && !strings.ContainsFunc(t.Project, unicode.IsSpace)
+			}},
+		{"priority", "out_of_range", "priority must be 0 (low), 1 (medium), or 2 (high)",
+			func(t Task) bool { return ValidatePriority(t.Priority) }},
+		{"due", "invalid_format", "due must be a date (2006-01-02) or an RFC 3339 timestamp",
+			func(t Task) bool { return ValidateDue(t.Due) }},
+		{"created_at", "invalid_format", "created_at must be an RFC 3339 timestamp",
+			func(t Task) bool { return validTimestamp(t.CreatedAt) }},
+		{"updated_at", "invalid_format", "updated_at must be an RFC 3339 timestamp",
+			func(t Task) bool { return validTimestamp(t.UpdatedAt) }},
+		{"updated_at", "before_created", "updated_at must not be before created_at",
+			func(t Task) bool { return !before(t.UpdatedAt, t.CreatedAt) }},
+	}
+}
+
+// Validate checks t against every rule and returns a ValidationError listing
+// all failures, or nil.
+func (v *Validator) Validate(t Task) error {
+	var errs ValidationError
+	failed := map[string]bool{}
+	for _, r := range v.rules {
+		if failed[r.Field] || r.Valid(t) {
+			continue
+		}
+		failed[r.Field] = true
+		errs = append(errs, FieldError{Field: r.Field, Code: r.Code, Message: r.Message})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+var validator atomic.Pointer[Validator]
+
+func init() {
+	validator.Store(NewValidator(DefaultLimits))
+}
+
+// SetLimits changes the limits used by Validate. It is safe to call while
+// requests are being validated, so that limits can be reloaded live.
+func SetLimits(l Limits) {
+	validator.Store(NewValidator(l))
+}
+
+// Validate checks t with the configured limits.
+func Validate(t Task) error {
+	return validator.Load().Validate(t)
+}
+
+// printable reports whether s is valid UTF-8 free of control characters.
+// Newlines and tabs are allowed when multiline is set.
+func printable(s string, multiline bool) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if multiline && (r == '\n' || r == '\r' || r == '\t') {
+			continue
+		}
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func validTimestamp(s string) bool {
+	if s == "" {
+		return true
+	}
+	_, err := time.Parse(time.RFC3339, s)
+	return err == nil
+}
+
+// before reports whether timestamp a is earlier than b. Missing or
+// malformed timestamps never compare as before.
+func before(a, b string) bool {
+	ta, errA := time.Parse(time.RFC3339, a)
+	tb, errB := time.Parse(time.RFC3339, b)
+	return errA == nil && errB == nil && ta.Before(tb)
+}
@@ -0,0 +1,75 @@
+package model_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/model"
+)
+
+func TestValidate(t *testing.T) {
+	valid := model.Task{
+		Title:     "Write tests",
+		Priority:  model.PriorityMedium,
+		Project:   "launch",
+		Due:       "2024-03-01",
+		CreatedAt: "2024-01-01T00:00:00Z",
+		UpdatedAt: "2024-01-02T00:00:00Z",
+	}
+	tests := []struct {
+		name   string
+		modify func(*model.Task)
+		want   []string
+	}{
+		{"valid", func(*model.Task) {}, nil},
+		{"missing title", func(t *model.Task) { t.Title = "  " }, []string{"title:required"}},
+		{"long title", func(t *model.Task) { t.Title = strings.Repeat("é", 201) }, []string{"title:too_long"}},
+		{"multiline title", func(t *model.Task) { t.Title = "one\ntwo" }, []string{"title:invalid_characters"}},
+		{"invalid utf-8", func(t *model.Task) { t.Title = "bad \xff" }, []string{"title:invalid_characters"}},
+		{"multiline description", func(t *model.Task) { t.Description = "one\ntwo\tthree" }, nil},
+		{"control characters", func(t *model.Task) { t.Description = "bell\a" }, []string{"description:invalid_characters"}},
+		{"large description", func(t *model.Task) { t.Description = strings.Repeat("x", 10001) }, []string{"description:too_large"}},
+		{"project with spaces", func(t *model.Task) { t.Project = "two words" }, []string{"project:invalid_characters"}},
+		{"bad priority", func(t *model.Task) { t.Priority = 3 }, []string{"priority:out_of_range"}},
+		{"bad due", func(t *model.Task) { t.Due = "tomorrow" }, []string{"due:invalid_format"}},
+		{"due timestamp", func(t *model.Task) { t.Due = "2024-03-01T17:00:00+02:00" }, nil},
+		{"bad created_at", func(t *model.Task) { t.CreatedAt = "2024-01-01" }, []string{"created_at:invalid_format"}},
+		{"updated before created", func(t *model.Task) { t.UpdatedAt = "2023-12-31T00:00:00Z" }, []string{"updated_at:before_created"}},
+		{"several problems", func(t *model.Task) {
+			t.Title = ""
+			t.Priority = -1
+			t.UpdatedAt = "yesterday"
+		}, []string{"title:required", "priority:out_of_range", "updated_at:invalid_format"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := valid
+			tt.modify(&task)
+
+			var got []string
+			var verr model.ValidationError
+			if err := model.Validate(task); errors.As(err, &verr) {
+				for _, e := range verr {
+					got = append(got, e.Field+":"+e.Code)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidatorLimits(t *testing.T) {
+	v := model.NewValidator(model.Limits{MaxTitleLength: 5, MaxDescriptionSize: 3, MaxProjectLength: 2})
+
+	err := v.Validate(model.Task{Title: "123456", Description: "1234", Project: "abc"})
+	var verr model.ValidationError
+	if !errors.As(err, &verr) || len(verr) != 3 {
+		t.Fatalf("expected 3 limit errors, got %v", err)
+	}
+	if err := v.Validate(model.Task{Title: "12345", Description: "123", Project: "ab"}); err != nil {
+		t.Fatalf("expected values at the limits to pass, got %v", err)
+	}
+}
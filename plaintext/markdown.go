@@ -0,0 +1,109 @@
+package plaintext
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/sawez-deepsource/demo-go/model"
+)
+
+// EncodeMarkdown writes tasks as a Markdown checklist. Tasks are grouped
+// under a "## project" heading per project, with tasks that have no project
+// first, and descriptions follow as indented lines under their item:
+//
+//	## home
+//
+//	- [ ] Fix the gate
+//
+//	## launch
+//
+//	- [ ] Write release notes
+//	- [x] Book venue
+//	  Confirmed for 40 people
+func EncodeMarkdown(w io.Writer, tasks []model.Task) error {
+	bw := bufio.NewWriter(w)
+	var projects []string
+	byProject := map[string][]model.Task{}
+	for _, t := range tasks {
+		if _, ok := byProject[t.Project]; !ok && t.Project != "" {
+			projects = append(projects, t.Project)
+		}
+		byProject[t.Project] = append(byProject[t.Project], t)
+	}
+
+	writeItems := func(tasks []model.Task) {
+		for _, t := range tasks {
+			box := "[ ]"
+			if t.Done {
+				box = "[x]"
+			}
+			bw.WriteString("- " + box + " " + t.Title + "\n")
+			for _, l := range strings.Split(t.Description, "\n") {
+				if l != "" {
+					bw.WriteString("  " + l + "\n")
+				}
+			}
+		}
+	}
+	writeItems(byProject[""])
+	for i, p := range projects {
+		if i > 0 || len(byProject[""]) > 0 {
+			bw.WriteString("\n")
+		}
+		bw.WriteString("## " + p + "\n\n")
+		writeItems(byProject[p])
+	}
+	return bw.Flush()
+}
+
+var (
+	checklistItem = regexp.MustCompile(`^[-*+]\s+\[([ xX])\]\s*(.*)$`)
+	heading       = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*$`)
+)
+
+// DecodeMarkdown reads checklist items ("- [ ]", "* [x]" and so on). A
+// heading sets the project of the items below it, with spaces turned into
+// hyphens, and indented lines following an item become its description,
+// even when they look like a heading or an item themselves. Everything else
+// is ignored, so a checklist can be pulled out of a larger document.
+func DecodeMarkdown(r io.Reader) ([]Item, error) {
+	var items []Item
+	project := ""
+	var cur *Item
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		raw := sc.Text()
+		text := strings.TrimSpace(raw)
+
+		if cur != nil && text != "" && (raw[0] == ' ' || raw[0] == '\t') {
+			if cur.Task.Description != "" {
+				cur.Task.Description += "\n"
+			}
+			cur.Task.Description += text
+			continue
+		}
+		if m := checklistItem.FindStringSubmatch(text); m != nil {
+			items = append(items, Item{
+				Line: line,
+				Task: model.Task{Title: m[2], Done: m[1] != " ", Project: project},
+			})
+			cur = &items[len(items)-1]
+			if cur.Task.Title == "" {
+				cur.Errors = append(cur.Errors, "task has no text")
+			}
+			continue
+		}
+		if m := heading.FindStringSubmatch(text); m != nil {
+			// Projects are single words, as in todo.txt.
+			project = strings.Join(strings.Fields(m[1]), "-")
+			cur = nil
+			continue
+		}
+		cur = nil
+	}
+	return items, sc.Err()
+}
@@ -0,0 +1,36 @@
+// Package plaintext converts tasks to and from the plain text formats people
+// keep by hand: todo.txt lines and Markdown checklists.
+package plaintext
+
+import (
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/model"
+)
+
+// Item is one decoded task. Line is its 1-based line number in the input.
+type Item struct {
+	Line   int
+	Task   model.Task
+	Errors []string
+}
+
+// dateOf returns the calendar date of an RFC 3339 timestamp.
+func dateOf(ts string) (string, bool) {
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return "", false
+	}
+	return t.UTC().Format(time.DateOnly), true
+}
+
+func isDate(s string) bool {
+	_, err := time.Parse(time.DateOnly, s)
+	return err == nil
+}
+
+// startOfDay turns a calendar date into a midnight UTC timestamp.
+func startOfDay(date string) string {
+	t, _ := time.Parse(time.DateOnly, date)
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,113 @@
+package plaintext_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/plaintext"
+)
+
+func TestTodoTxtRoundTrip(t *testing.T) {
+	tasks := []model.Task{
+		{ID: "1", Title: "Call Bob @phone", Priority: model.PriorityHigh, Project: "launch", Due: "2024-03-01", CreatedAt: "2024-01-01T00:00:00Z"},
+		{ID: "2", Title: "Buy milk", Priority: model.PriorityLow},
+		{ID: "3", Title: "Ship it", Done: true, Priority: model.PriorityMedium, CreatedAt: "2024-01-01T00:00:00Z", UpdatedAt: "2024-01-05T00:00:00Z"},
+	}
+	var buf bytes.Buffer
+	if err := plaintext.EncodeTodoTxt(&buf, tasks); err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+	want := "(A) 2024-01-01 Call Bob @phone +launch due:2024-03-01 id:1\n" +
+		"(C) Buy milk id:2\n" +
+		"x 2024-01-05 2024-01-01 Ship it pri:B id:3\n"
+	if buf.String() != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+
+	items, err := plaintext.DecodeTodoTxt(&buf)
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(items) != len(tasks) {
+		t.Fatalf("expected %d items, got %d", len(tasks), len(items))
+	}
+	for i, item := range items {
+		if len(item.Errors) > 0 || item.Task != tasks[i] {
+			t.Fatalf("expected %+v, got %+v (errors %v)", tasks[i], item.Task, item.Errors)
+		}
+	}
+}
+
+func TestParseTodoTxt(t *testing.T) {
+	task, problems := plaintext.ParseTodoTxt("(D) Water plants +home +garden @house due:someday")
+	if task.Title != "Water plants +garden @house" || task.Project != "home" || task.Priority != model.PriorityLow {
+		t.Fatalf("unexpected task %+v", task)
+	}
+	if len(problems) != 1 {
+		t.Fatalf("expected a problem with the due date, got %v", problems)
+	}
+}
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	tasks := []model.Task{
+		{Title: "Write notes"},
+		{Title: "Book venue", Done: true, Project: "launch", Description: "Confirmed for 40\nDeposit paid"},
+		{Title: "Send invites", Project: "launch"},
+	}
+	var buf bytes.Buffer
+	if err := plaintext.EncodeMarkdown(&buf, tasks); err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+	want := "- [ ] Write notes\n\n## launch\n\n- [x] Book venue\n  Confirmed for 40\n  Deposit paid\n- [ ] Send invites\n"
+	if buf.String() != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+
+	items, err := plaintext.DecodeMarkdown(&buf)
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(items) != len(tasks) {
+		t.Fatalf("expected %d items, got %d", len(tasks), len(items))
+	}
+	for i, item := range items {
+		if item.Task != tasks[i] {
+			t.Fatalf("expected %+v, got %+v", tasks[i], item.Task)
+		}
+	}
+}
+
+func TestDecodeMarkdownIgnoresProse(t *testing.T) {
+	input := "# Sprint 4\n\nSome notes.\n\n* [X] Done thing\n+ [ ] Open thing\n- not a task\n"
+	items, err := plaintext.DecodeMarkdown(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(items) != 2 || !items[0].Task.Done || items[1].Task.Project != "Sprint-4" || items[1].Line != 6 {
+		t.Fatalf("unexpected items %+v", items)
+	}
+}
+
+func TestDecodeMarkdownKeepsIndentedLinesInDescription(t *testing.T) {
+	input := "## launch\n\n- [ ] Book venue\n  # notes\n  - [ ] ask about parking\n- [ ] Send invites\n"
+	items, err := plaintext.DecodeMarkdown(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(items) != 2 || items[0].Task.Description != "# notes\n- [ ] ask about parking" || items[1].Task.Project != "launch" {
+		t.Fatalf("unexpected items %+v", items)
+	}
+}
+
+func TestTodoTxtEscapesTagLikeTitleWords(t *testing.T) {
+	titles := []string{"2024-01-01 retro notes", "Close id:3 and +x", `Fix due: parsing in C:\ path`, `\escaped`}
+	for _, title := range titles {
+		line := plaintext.FormatTodoTxt(model.Task{ID: "9", Title: title, Project: "web"})
+		task, problems := plaintext.ParseTodoTxt(line)
+		if len(problems) > 0 || task.Title != title || task.Project != "web" || task.ID != "9" {
+			t.Errorf("%q came back from %q as %+v (problems %v)", title, line, task, problems)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package plaintext
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sawez-deepsource/demo-go/model"
+)
+
+// Priority letters used in todo.txt. Any letter below C reads as low.
+var priorityLetters = map[model.Priority]string{
+	model.PriorityHigh:   "A",
+	model.PriorityMedium: "B",
+	model.PriorityLow:    "C",
+}
+
+// FormatTodoTxt renders a task as a todo.txt line:
+//
+//	x 2024-01-05 2024-01-01 (A) Call Bob @phone +launch due:2024-01-10 id:7
+//
+// The task ID is kept in an id: tag so that an edited file can be imported
+// back onto the same tasks. Done tasks keep their priority in a pri: tag, as
+// the format does not allow a priority after the completion mark.
+// Descriptions have no place in todo.txt and are left out. Title words that
+// would read as a tag, such as +x or id:3, are escaped with a backslash.
+func FormatTodoTxt(t model.Task) string {
+	var parts []string
+	if t.Done {
+		parts = append(parts, "x")
+		if d, ok := dateOf(t.UpdatedAt); ok {
+			parts = append(parts, d)
+		}
+	} else {
+		parts = append(parts, "("+priorityLetters[t.Priority]+")")
+	}
+	if d, ok := dateOf(t.CreatedAt); ok {
+		if t.Done && len(parts) == 1 {
+			// A creation date needs a completion date before it.
+			parts = append(parts, d)
+		}
+		parts = append(parts, d)
+	}
+	parts = append(parts, escapeTitle(t.Title))
+	if t.Project != "" {
+		parts = append(parts, "+"+t.Project)
+	}
+	if t.Due != "" {
+		parts = append(parts, "due:"+t.Due)
+	}
+	if t.Done {
+		parts = append(parts, "pri:"+priorityLetters[t.Priority])
+	}
+	if t.ID != "" {
+		parts = append(parts, "id:"+t.ID)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseTodoTxt reads a single todo.txt line. The first +project becomes the
+// task's project; @contexts and any further projects stay in the title,
+// where todo.txt keeps them.
+func ParseTodoTxt(line string) (model.Task, []string) {
+	var t model.Task
+	var problems []string
+	fields := strings.Fields(line)
+
+	if len(fields) > 0 && fields[0] == "x" {
+		t.Done = true
+		fields = fields[1:]
+		if len(fields) > 0 && isDate(fields[0]) {
+			t.UpdatedAt = startOfDay(fields[0])
+			fields = fields[1:]
+		}
+	}
+	if len(fields) > 0 && !t.Done && isPriority(fields[0]) {
+		t.Priority = letterPriority(fields[0][1])
+		fields = fields[1:]
+	}
+	if len(fields) > 0 && isDate(fields[0]) {
+		t.CreatedAt = startOfDay(fields[0])
+		fields = fields[1:]
+	}
+
+	var title []string
+	for _, f := range fields {
+		switch {
+		case strings.HasPrefix(f, `\`) && len(f) > 1:
+			title = append(title, f[1:])
+		case strings.HasPrefix(f, "+") && len(f) > 1 && t.Project == "":
+			t.Project = f[1:]
+		case strings.HasPrefix(f, "due:"):
+			t.Due = strings.TrimPrefix(f, "due:")
+			if !model.ValidateDue(t.Due) {
+				problems = append(problems, fmt.Sprintf("due date %q is not a date", t.Due))
+			}
+		case strings.HasPrefix(f, "pri:") && len(f) == 5 && isLetter(f[4]):
+			t.Priority = letterPriority(f[4])
+		case strings.HasPrefix(f, "id:") && len(f) > 3:
+			t.ID = strings.TrimPrefix(f, "id:")
+		default:
+			title = append(title, f)
+		}
+	}
+	t.Title = strings.Join(title, " ")
+	if t.Title == "" {
+		problems = append(problems, "task has no text")
+	}
+	return t, problems
+}
+
+// escapeTitle prefixes the words of a title that ParseTodoTxt would take
+// for a tag, a date or an escaped word with a backslash.
+func escapeTitle(title string) string {
+	words := strings.Fields(title)
+	for i, w := range words {
+		if isTag(w) || strings.HasPrefix(w, `\`) || (i == 0 && isDate(w)) {
+			words[i] = `\` + w
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func isTag(f string) bool {
+	switch {
+	case strings.HasPrefix(f, "+") && len(f) > 1:
+		return true
+	case strings.HasPrefix(f, "due:"):
+		return true
+	case strings.HasPrefix(f, "pri:") && len(f) == 5 && isLetter(f[4]):
+		return true
+	case strings.HasPrefix(f, "id:") && len(f) > 3:
+		return true
+	}
+	return false
+}
+
+func isPriority(s string) bool {
+	return len(s) == 3 && s[0] == '(' && isLetter(s[1]) && s[2] == ')'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
+
+func letterPriority(c byte) model.Priority {
+	switch c {
+	case 'A':
+		return model.PriorityHigh
+	case 'B':
+		return model.PriorityMedium
+	default:
+		return model.PriorityLow
+	}
+}
+
+// EncodeTodoTxt writes one todo.txt line per task.
+func EncodeTodoTxt(w io.Writer, tasks []model.Task) error {
+	bw := bufio.NewWriter(w)
+	for _, t := range tasks {
+		bw.WriteString(FormatTodoTxt(t))
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// DecodeTodoTxt reads a todo.txt file, skipping blank lines.
+func DecodeTodoTxt(r io.Reader) ([]Item, error) {
+	var items []Item
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		t, problems := ParseTodoTxt(text)
+		items = append(items, Item{Line: line, Task: t, Errors: problems})
+	}
+	return items, sc.Err()
+}
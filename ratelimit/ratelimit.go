@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Result describes the outcome of a single rate limit check.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a set of token buckets keyed by client. Each bucket holds up to
+// burst tokens and refills at rate tokens per second.
+type Limiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     int
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func New(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Allow takes one token from the bucket for key if one is available.
+func (l *Limiter) Allow(key string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	res := Result{Limit: l.burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.refillTime(1 - b.tokens)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = l.refillTime(float64(l.burst) - b.tokens)
+	return res
+}
+
+func (l *Limiter) refillTime(tokens float64) time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep drops buckets that have refilled completely, since a fresh bucket
+// behaves identically. It runs at most once a minute.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= float64(l.burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Quota counts events per key and resets every day at midnight UTC.
+type Quota struct {
+	mu     sync.Mutex
+	limit  int
+	day    string
+	counts map[string]int
+	now    func() time.Time
+}
+
+func NewQuota(limit int) *Quota {
+	return &Quota{
+		limit:  limit,
+		counts: map[string]int{},
+		now:    time.Now,
+	}
+}
+
+// Take records one event for key unless the daily limit is already used up.
+func (q *Quota) Take(key string) Result {
+	return q.TakeN(key, 1)
+}
+
+// TakeN records n events for key, or none if they do not all fit in what is
+// left of the daily limit.
+func (q *Quota) TakeN(key string, n int) Result {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now().UTC()
+	if day := now.Format(time.DateOnly); day != q.day {
+		q.day = day
+		q.counts = map[string]int{}
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+
+	res := Result{Limit: q.limit, Reset: midnight.Sub(now)}
+	if q.counts[key]+n <= q.limit {
+		q.counts[key] += n
+		res.Allowed = true
+	} else {
+		res.RetryAfter = res.Reset
+	}
+	res.Remaining = q.limit - q.counts[key]
+	return res
+}
+
+// Refund gives back n events taken for key today, for work that was charged
+// but then not done. Events taken before midnight are not refunded.
+func (q *Quota) Refund(key string, n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.now().UTC().Format(time.DateOnly) != q.day {
+		return
+	}
+	q.counts[key] = max(q.counts[key]-n, 0)
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterRefills(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(1, 2)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if res := l.Allow("a"); !res.Allowed {
+			t.Fatalf("expected request %d to be allowed", i+1)
+		}
+	}
+	res := l.Allow("a")
+	if res.Allowed {
+		t.Fatal("expected third request to be limited")
+	}
+	if res.RetryAfter != time.Second {
+		t.Fatalf("expected retry after 1s, got %s", res.RetryAfter)
+	}
+	if !l.Allow("b").Allowed {
+		t.Fatal("expected other keys to have their own bucket")
+	}
+
+	now = now.Add(time.Second)
+	if !l.Allow("a").Allowed {
+		t.Fatal("expected a token to be refilled after 1s")
+	}
+}
+
+func TestQuotaResetsDaily(t *testing.T) {
+	now := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+	q := NewQuota(1)
+	q.now = func() time.Time { return now }
+
+	if !q.Take("a").Allowed {
+		t.Fatal("expected first event to be allowed")
+	}
+	res := q.Take("a")
+	if res.Allowed {
+		t.Fatal("expected second event to exceed the quota")
+	}
+	if res.RetryAfter != time.Hour {
+		t.Fatalf("expected retry after 1h, got %s", res.RetryAfter)
+	}
+
+	now = now.Add(time.Hour)
+	if !q.Take("a").Allowed {
+		t.Fatal("expected quota to reset at midnight UTC")
+	}
+}
+
+func TestQuotaTakeNIsAllOrNothing(t *testing.T) {
+	q := NewQuota(3)
+	if !q.TakeN("a", 2).Allowed {
+		t.Fatal("expected two events to fit")
+	}
+	if res := q.TakeN("a", 2); res.Allowed || res.Remaining != 1 {
+		t.Fatalf("expected two more events to be refused with one left, got %+v", res)
+	}
+	if !q.Take("a").Allowed {
+		t.Fatal("expected the last event to fit")
+	}
+}
+
+func TestQuotaRefund(t *testing.T) {
+	q := NewQuota(2)
+	q.TakeN("a", 2)
+	q.Refund("a", 2)
+	if res := q.TakeN("a", 2); !res.Allowed {
+		t.Fatalf("expected refunded events to be available again, got %+v", res)
+	}
+	q.Refund("b", 5)
+	if res := q.TakeN("b", 3); res.Allowed {
+		t.Fatalf("expected refunds not to raise the limit, got %+v", res)
+	}
+}
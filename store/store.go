@@ -1,7 +1,9 @@
 package store
 
 import (
+	"context"
 	"fmt"
+	"maps"
 	"net/url"
 	"os"
 	"regexp"
@@ -10,16 +12,102 @@ import (
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/sawez-deepsource/demo-go/model"
 )
 
 var (
-	mu     sync.RWMutex
-	tasks  = map[string]model.Task{}
-	nextID = 1
+	mu        sync.RWMutex
+	tasks     = map[string]model.Task{}
+	nextID    = 1
+	listeners []func(Change)
+
+	observersMu sync.RWMutex
+	observers   []func(op string, d time.Duration)
 )
 
+type ChangeType string
+
+const (
+	Created ChangeType = "created"
+	Updated ChangeType = "updated"
+	Deleted ChangeType = "deleted"
+)
+
+// Change describes a committed mutation. For deletions Task holds the task as
+// it was before it was removed; for updates Previous does.
+type Change struct {
+	Type     ChangeType
+	Task     model.Task
+	Previous model.Task
+}
+
+// Subscribe registers fn to be called after every committed mutation, in
+// commit order. fn runs while the store lock is held, so it must be fast and
+// must not call back into the store.
+func Subscribe(fn func(Change)) {
+	mu.Lock()
+	defer mu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+func notify(c Change) {
+	for _, fn := range listeners {
+		fn(c)
+	}
+}
+
+// ObserveOperations registers fn to be called with the duration of every
+// store call, including time spent waiting for the lock. op is the lower
+// case name of the function called, such as "add" or "filter_by_done".
+func ObserveOperations(fn func(op string, d time.Duration)) {
+	observersMu.Lock()
+	defer observersMu.Unlock()
+	observers = append(observers, fn)
+}
+
+func observe(op string, start time.Time) {
+	d := time.Since(start)
+	observersMu.RLock()
+	defer observersMu.RUnlock()
+	for _, fn := range observers {
+		fn(op, d)
+	}
+}
+
+// instrumentation names the tracer used for store spans.
+const instrumentation = "github.com/sawez-deepsource/demo-go/store"
+
+// startSpan starts a span named after op when ctx is already part of a
+// trace, so that calls outside a traced request, such as metric scrapes, do
+// not start traces of their own. The span is a no-op otherwise.
+func startSpan(ctx context.Context, op string, attrs ...attribute.KeyValue) trace.Span {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return trace.SpanFromContext(ctx)
+	}
+	_, span := otel.Tracer(instrumentation).Start(ctx, "store."+op,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...))
+	return span
+}
+
+// taskID is the span attribute identifying the task an operation touched.
+func taskID(id string) attribute.KeyValue {
+	return attribute.String("task.id", id)
+}
+
 func All() []model.Task {
+	return AllContext(context.Background())
+}
+
+// AllContext is All as part of the trace in ctx, if any. The other
+// Context variants below are likewise.
+func AllContext(ctx context.Context) []model.Task {
+	defer startSpan(ctx, "all").End()
+	defer observe("all", time.Now())
 	mu.RLock()
 	defer mu.RUnlock()
 	out := make([]model.Task, 0, len(tasks))
@@ -33,6 +121,12 @@ func All() []model.Task {
 }
 
 func Get(id string) (model.Task, bool) {
+	return GetContext(context.Background(), id)
+}
+
+func GetContext(ctx context.Context, id string) (model.Task, bool) {
+	defer startSpan(ctx, "get", taskID(id)).End()
+	defer observe("get", time.Now())
 	mu.RLock()
 	defer mu.RUnlock()
 	t, ok := tasks[id]
@@ -40,8 +134,145 @@ func Get(id string) (model.Task, bool) {
 }
 
 func Add(t model.Task) model.Task {
+	return AddContext(context.Background(), t)
+}
+
+func AddContext(ctx context.Context, t model.Task) model.Task {
+	span := startSpan(ctx, "add")
+	defer span.End()
+	defer observe("add", time.Now())
+	mu.Lock()
+	defer mu.Unlock()
+	t = add(t)
+	span.SetAttributes(taskID(t.ID))
+	notify(Change{Type: Created, Task: t})
+	return t
+}
+
+func Update(id string, updated model.Task) (model.Task, bool) {
+	return UpdateContext(context.Background(), id, updated)
+}
+
+func UpdateContext(ctx context.Context, id string, updated model.Task) (model.Task, bool) {
+	defer startSpan(ctx, "update", taskID(id)).End()
+	defer observe("update", time.Now())
+	mu.Lock()
+	defer mu.Unlock()
+	previous := tasks[id]
+	updated, ok := update(id, updated)
+	if !ok {
+		return model.Task{}, false
+	}
+	notify(Change{Type: Updated, Task: updated, Previous: previous})
+	return updated, true
+}
+
+func Delete(id string) bool {
+	return DeleteContext(context.Background(), id)
+}
+
+func DeleteContext(ctx context.Context, id string) bool {
+	defer startSpan(ctx, "delete", taskID(id)).End()
+	defer observe("delete", time.Now())
 	mu.Lock()
 	defer mu.Unlock()
+	existing, ok := remove(id)
+	if !ok {
+		return false
+	}
+	notify(Change{Type: Deleted, Task: existing})
+	return true
+}
+
+type OpKind string
+
+const (
+	OpCreate OpKind = "create"
+	OpUpdate OpKind = "update"
+	OpDelete OpKind = "delete"
+)
+
+// Op is one mutation in a batch. ID is ignored for creates and Task for
+// deletes.
+type Op struct {
+	Kind OpKind
+	ID   string
+	Task model.Task
+}
+
+// OpResult reports whether an op succeeded and the resulting task; for
+// deletes, the task that was removed.
+type OpResult struct {
+	Task model.Task
+	OK   bool
+}
+
+// Apply runs ops in order under a single lock, so no other reader or writer
+// sees a partial batch. Updates and deletes of missing tasks fail. If atomic
+// is set and any op fails, the store is rolled back and committed is false;
+// otherwise failed ops are skipped. Changes are only announced once the
+// batch has been committed.
+func Apply(ops []Op, atomic bool) (results []OpResult, committed bool) {
+	return ApplyContext(context.Background(), ops, atomic)
+}
+
+func ApplyContext(ctx context.Context, ops []Op, atomic bool) (results []OpResult, committed bool) {
+	span := startSpan(ctx, "apply",
+		attribute.Int("store.batch.operations", len(ops)),
+		attribute.Bool("store.batch.atomic", atomic))
+	defer func() {
+		span.SetAttributes(attribute.Bool("store.batch.committed", committed))
+		span.End()
+	}()
+	defer observe("apply", time.Now())
+	mu.Lock()
+	defer mu.Unlock()
+
+	var snapshot map[string]model.Task
+	snapshotID := nextID
+	if atomic {
+		snapshot = maps.Clone(tasks)
+	}
+
+	results = make([]OpResult, len(ops))
+	changes := make([]Change, 0, len(ops))
+	failed := false
+	for i, op := range ops {
+		var res OpResult
+		var typ ChangeType
+		var previous model.Task
+		switch op.Kind {
+		case OpCreate:
+			res = OpResult{Task: add(op.Task), OK: true}
+			typ = Created
+		case OpUpdate:
+			previous = tasks[op.ID]
+			res.Task, res.OK = update(op.ID, op.Task)
+			typ = Updated
+		case OpDelete:
+			res.Task, res.OK = remove(op.ID)
+			typ = Deleted
+		}
+		results[i] = res
+		if !res.OK {
+			failed = true
+			continue
+		}
+		changes = append(changes, Change{Type: typ, Task: res.Task, Previous: previous})
+	}
+
+	if atomic && failed {
+		tasks = snapshot
+		nextID = snapshotID
+		return results, false
+	}
+	for _, c := range changes {
+		notify(c)
+	}
+	return results, true
+}
+
+func add(t model.Task) model.Task {
 	t.ID = fmt.Sprintf("%d", nextID)
 	nextID++
 	now := time.Now().UTC().Format(time.RFC3339)
@@ -53,9 +284,7 @@ func Add(t model.Task) model.Task {
 	return t
 }
 
-func Update(id string, updated model.Task) (model.Task, bool) {
-	mu.Lock()
-	defer mu.Unlock()
+func update(id string, updated model.Task) (model.Task, bool) {
 	existing, ok := tasks[id]
 	if !ok {
 		return model.Task{}, false
@@ -67,17 +296,22 @@ func Update(id string, updated model.Task) (model.Task, bool) {
 	return updated, true
 }
 
-func Delete(id string) bool {
-	mu.Lock()
-	defer mu.Unlock()
-	if _, ok := tasks[id]; !ok {
-		return false
+func remove(id string) (model.Task, bool) {
+	existing, ok := tasks[id]
+	if !ok {
+		return model.Task{}, false
 	}
 	delete(tasks, id)
-	return true
+	return existing, true
 }
 
 func Count() int {
+	return CountContext(context.Background())
+}
+
+func CountContext(ctx context.Context) int {
+	defer startSpan(ctx, "count").End()
+	defer observe("count", time.Now())
 	mu.RLock()
 	defer mu.RUnlock()
 	return len(tasks)
@@ -91,6 +325,12 @@ func Clear() {
 }
 
 func FilterByDone(done bool) []model.Task {
+	return FilterByDoneContext(context.Background(), done)
+}
+
+func FilterByDoneContext(ctx context.Context, done bool) []model.Task {
+	defer startSpan(ctx, "filter_by_done", attribute.Bool("task.done", done)).End()
+	defer observe("filter_by_done", time.Now())
 	mu.RLock()
 	defer mu.RUnlock()
 	out := make([]model.Task, 0)
@@ -105,7 +345,34 @@ func FilterByDone(done bool) []model.Task {
 	return out
 }
 
+func FilterByProject(project string) []model.Task {
+	return FilterByProjectContext(context.Background(), project)
+}
+
+func FilterByProjectContext(ctx context.Context, project string) []model.Task {
+	defer startSpan(ctx, "filter_by_project", attribute.String("task.project", project)).End()
+	defer observe("filter_by_project", time.Now())
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]model.Task, 0)
+	for _, t := range tasks {
+		if t.Project == project {
+			out = append(out, t)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
 func FilterByPriority(p model.Priority) []model.Task {
+	return FilterByPriorityContext(context.Background(), p)
+}
+
+func FilterByPriorityContext(ctx context.Context, p model.Priority) []model.Task {
+	defer startSpan(ctx, "filter_by_priority", attribute.Int("task.priority", int(p))).End()
+	defer observe("filter_by_priority", time.Now())
 	mu.RLock()
 	defer mu.RUnlock()
 	out := make([]model.Task, 0)
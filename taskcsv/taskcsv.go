@@ -0,0 +1,289 @@
+package taskcsv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sawez-deepsource/demo-go/model"
+)
+
+// Columns lists the CSV columns in export order. They follow the JSON names
+// and field order of model.Task, so adding a field to the model adds a column.
+var Columns = taskColumns()
+
+func taskColumns() []string {
+	typ := reflect.TypeFor[model.Task]()
+	cols := make([]string, 0, typ.NumField())
+	for f := range typ.Fields() {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			cols = append(cols, name)
+		}
+	}
+	return cols
+}
+
+// Writer streams tasks as CSV rows under a header row.
+type Writer struct {
+	w      *csv.Writer
+	header bool
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: csv.NewWriter(w)}
+}
+
+func (w *Writer) Write(t model.Task) error {
+	if !w.header {
+		w.header = true
+		if err := w.w.Write(Columns); err != nil {
+			return err
+		}
+	}
+	v := reflect.ValueOf(t)
+	typ := v.Type()
+	record := make([]string, 0, len(Columns))
+	for i := range typ.NumField() {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		record = append(record, format(v.Field(i)))
+	}
+	return w.w.Write(record)
+}
+
+// Flush writes any buffered rows, and the header if no task was written.
+func (w *Writer) Flush() error {
+	if !w.header {
+		w.header = true
+		if err := w.w.Write(Columns); err != nil {
+			return err
+		}
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func format(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int:
+		return strconv.FormatInt(v.Int(), 10)
+	default:
+		return escape(v.String())
+	}
+}
+
+// escape prefixes cells that spreadsheets would run as a formula with a
+// single quote, which they show as text instead. Decode strips it again.
+func escape(s string) string {
+	if isFormula(s) {
+		return "'" + s
+	}
+	return s
+}
+
+func unescape(s string) string {
+	if rest, ok := strings.CutPrefix(s, "'"); ok && isFormula(rest) {
+		return rest
+	}
+	return s
+}
+
+// isFormula reports whether s starts like a formula, or is a quoted one
+// already, so that a literal leading quote survives a round trip too.
+func isFormula(s string) bool {
+	if s == "" {
+		return false
+	}
+	switch s[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return true
+	case '\'':
+		return isFormula(s[1:])
+	}
+	return false
+}
+
+type FieldError struct {
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// Row is one decoded data row. Line is the 1-based line number in the file,
+// counting the header. Columns are the columns the file has, in header
+// order, so that an update can leave the others alone. Rows with Errors
+// hold a partially filled Task.
+type Row struct {
+	Line    int
+	Task    model.Task
+	Columns []string
+	Errors  []FieldError
+}
+
+// Decode reads CSV with a header row. Header names are matched against
+// Columns case-insensitively; aliases maps additional header names (also
+// case-insensitive) to a column, for files exported by other tools. Unknown
+// headers are an error so that typos don't silently drop data. Text cells
+// (title, description and project) keep their spacing; other cells and
+// header names are trimmed.
+func Decode(r io.Reader, aliases map[string]string) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("csv is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := mapHeader(header, aliases)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		rows = append(rows, decodeRow(line, mapping, record))
+	}
+}
+
+func mapHeader(header []string, aliases map[string]string) ([]string, error) {
+	known := map[string]string{}
+	for _, c := range Columns {
+		known[c] = c
+	}
+	for from, to := range aliases {
+		if _, ok := known[strings.ToLower(to)]; !ok {
+			return nil, fmt.Errorf("mapping target %q is not a column", to)
+		}
+		known[strings.ToLower(from)] = strings.ToLower(to)
+	}
+
+	mapping := make([]string, len(header))
+	seen := map[string]bool{}
+	for i, h := range header {
+		col, ok := known[strings.ToLower(strings.TrimSpace(h))]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", h)
+		}
+		if seen[col] {
+			return nil, fmt.Errorf("column %q appears more than once", col)
+		}
+		seen[col] = true
+		mapping[i] = col
+	}
+	if !seen["title"] {
+		return nil, errors.New("a title column is required")
+	}
+	return mapping, nil
+}
+
+// textColumns hold free text, which is imported as written. Other cells
+// are trimmed, since spreadsheets tend to pad them.
+var textColumns = map[string]bool{"title": true, "description": true, "project": true}
+
+func decodeRow(line int, mapping, record []string) Row {
+	row := Row{Line: line, Columns: mapping}
+	if len(record) != len(mapping) {
+		row.Errors = append(row.Errors, FieldError{
+			Message: fmt.Sprintf("expected %d fields, got %d", len(mapping), len(record)),
+		})
+		return row
+	}
+	for i, col := range mapping {
+		value := record[i]
+		if !textColumns[col] {
+			value = strings.TrimSpace(value)
+		}
+		value = unescape(value)
+		if err := set(&row.Task, col, value); err != nil {
+			row.Errors = append(row.Errors, FieldError{Column: col, Message: err.Error()})
+		}
+	}
+	return row
+}
+
+func set(t *model.Task, col, value string) error {
+	switch col {
+	case "id":
+		t.ID = value
+	case "title":
+		t.Title = value
+	case "description":
+		t.Description = value
+	case "project":
+		t.Project = value
+	case "due":
+		if !model.ValidateDue(value) {
+			return errors.New("due must be a date (2006-01-02) or an RFC 3339 timestamp")
+		}
+		t.Due = value
+	case "done":
+		if value == "" {
+			return nil
+		}
+		done, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("done must be true or false")
+		}
+		t.Done = done
+	case "priority":
+		if value == "" {
+			return nil
+		}
+		p, err := ParsePriority(value)
+		if err != nil {
+			return err
+		}
+		t.Priority = p
+	case "created_at", "updated_at":
+		if value == "" {
+			return nil
+		}
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return fmt.Errorf("%s must be an RFC 3339 timestamp", col)
+		}
+		if col == "created_at" {
+			t.CreatedAt = value
+		} else {
+			t.UpdatedAt = value
+		}
+	default:
+		return errors.New("column cannot be imported")
+	}
+	return nil
+}
+
+// ParsePriority accepts either a priority name (low, medium, high) or its
+// number.
+func ParsePriority(s string) (model.Priority, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if !model.ValidatePriority(model.Priority(n)) {
+			return 0, errors.New("priority must be 0 (low), 1 (medium), or 2 (high)")
+		}
+		return model.Priority(n), nil
+	}
+	p, err := model.ParsePriority(s)
+	if err != nil {
+		return 0, errors.New("priority must be low, medium, high, or 0-2")
+	}
+	return p, nil
+}
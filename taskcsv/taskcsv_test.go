@@ -0,0 +1,129 @@
+package taskcsv_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/taskcsv"
+)
+
+func TestColumnsFollowModel(t *testing.T) {
+	want := "id,title,description,done,priority,project,due,created_at,updated_at"
+	if got := strings.Join(taskcsv.Columns, ","); got != want {
+		t.Fatalf("expected columns %q, got %q", want, got)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	task := model.Task{
+		ID:          "7",
+		Title:       "Write, with comma",
+		Description: "line one\nline two",
+		Done:        true,
+		Priority:    model.PriorityHigh,
+		Project:     "launch",
+		Due:         "2024-02-01",
+		CreatedAt:   "2024-01-01T00:00:00Z",
+		UpdatedAt:   "2024-01-02T00:00:00Z",
+	}
+	var buf bytes.Buffer
+	w := taskcsv.NewWriter(&buf)
+	if err := w.Write(task); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("flushing: %v", err)
+	}
+
+	rows, err := taskcsv.Decode(&buf, nil)
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(rows) != 1 || len(rows[0].Errors) > 0 {
+		t.Fatalf("expected one clean row, got %+v", rows)
+	}
+	if rows[0].Task != task {
+		t.Fatalf("expected %+v, got %+v", task, rows[0].Task)
+	}
+}
+
+func TestDecodeMapsHeadersAndReportsRowErrors(t *testing.T) {
+	input := "Summary,PRIORITY,Done\n" +
+		"Ship it,high,false\n" +
+		"Numbered,2,\n" +
+		"Broken,urgent,maybe\n"
+
+	rows, err := taskcsv.Decode(strings.NewReader(input), map[string]string{"summary": "title"})
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(rows))
+	}
+	for _, row := range rows[:2] {
+		if len(row.Errors) > 0 || row.Task.Priority != model.PriorityHigh {
+			t.Fatalf("expected high priority without errors, got %+v", row)
+		}
+	}
+	if rows[2].Line != 4 || len(rows[2].Errors) != 2 {
+		t.Fatalf("expected 2 errors on line 4, got %+v", rows[2])
+	}
+}
+
+func TestDecodeRejectsUnknownColumns(t *testing.T) {
+	if _, err := taskcsv.Decode(strings.NewReader("title,colour\nx,red\n"), nil); err == nil {
+		t.Fatal("expected an error for an unknown column")
+	}
+	if _, err := taskcsv.Decode(strings.NewReader("description\nx\n"), nil); err == nil {
+		t.Fatal("expected an error when the title column is missing")
+	}
+}
+
+func TestFormulasAreEscaped(t *testing.T) {
+	titles := []string{"=HYPERLINK(\"http://x\")", "+1", "-2", "@SUM(A1)", "'=quoted", "'plain"}
+	var buf bytes.Buffer
+	w := taskcsv.NewWriter(&buf)
+	for _, title := range titles {
+		if err := w.Write(model.Task{Title: title}); err != nil {
+			t.Fatalf("writing: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("flushing: %v", err)
+	}
+	for _, cell := range []string{`'=HYPERLINK`, "'+1", "'-2", "'@SUM", "''=quoted", ",'plain,"} {
+		if !strings.Contains(buf.String(), cell) {
+			t.Fatalf("expected %q in\n%s", cell, buf.String())
+		}
+	}
+
+	rows, err := taskcsv.Decode(&buf, nil)
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	for i, row := range rows {
+		if row.Task.Title != titles[i] {
+			t.Errorf("expected %q back, got %q", titles[i], row.Task.Title)
+		}
+	}
+}
+
+func TestDecodeKeepsTextSpacing(t *testing.T) {
+	input := "title, description ,priority\n" +
+		"Indented,\"  code block\n  second line \", high \n"
+	rows, err := taskcsv.Decode(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(rows) != 1 || len(rows[0].Errors) > 0 {
+		t.Fatalf("expected one clean row, got %+v", rows)
+	}
+	if got := rows[0].Task; got.Description != "  code block\n  second line " || got.Priority != model.PriorityHigh {
+		t.Fatalf("unexpected task %+v", got)
+	}
+	if got := strings.Join(rows[0].Columns, ","); got != "title,description,priority" {
+		t.Fatalf("expected the file's columns, got %q", got)
+	}
+}
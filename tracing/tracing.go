@@ -0,0 +1,100 @@
+// Package tracing sets up OpenTelemetry tracing: the span exporter, W3C
+// trace context propagation and a span for every HTTP request.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sawez-deepsource/demo-go/internal/statuswriter"
+)
+
+// instrumentation names the tracer used for HTTP spans.
+const instrumentation = "github.com/sawez-deepsource/demo-go/tracing"
+
+// ServiceName is reported on every span unless OTEL_SERVICE_NAME is set.
+const ServiceName = "taskapi"
+
+// Setup installs the global tracer provider and propagator. exporter
+// selects where spans go: "stdout" writes them to w as JSON, "otlp" sends
+// them over OTLP/HTTP to OTEL_EXPORTER_OTLP_ENDPOINT (localhost:4318 by
+// default), and "none" or "" drops them. Trace context from callers is
+// propagated in every case. The returned function flushes pending spans
+// and stops the exporter.
+func Setup(ctx context.Context, exporter string, w io.Writer) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exp sdktrace.SpanExporter
+	switch exporter {
+	case "none", "":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	case "otlp":
+		exp, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q; use none, stdout or otlp", exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating %s exporter: %w", exporter, err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(ServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("describing resource: %w", err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Middleware starts a server span for every request passed to next,
+// continuing the caller's trace if the request carries a traceparent
+// header. Spans are named after the pattern the request matches in mux,
+// like metrics.Middleware, and marked as errors for 5xx responses.
+func Middleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		_, route := mux.Handler(r)
+		name := route
+		if name == "" {
+			name = r.Method
+		}
+		ctx, span := otel.Tracer(instrumentation).Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.ClientAddress(r.RemoteAddr),
+			))
+		defer span.End()
+		if route != "" {
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+
+		sw := statuswriter.New(w)
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(sw.Status))
+		if sw.Status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(sw.Status))
+		}
+	})
+}
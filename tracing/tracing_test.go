@@ -0,0 +1,139 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/sawez-deepsource/demo-go/handler"
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+	"github.com/sawez-deepsource/demo-go/tracing"
+)
+
+// record installs a tracer provider that keeps finished spans in memory.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	if _, err := tracing.Setup(context.Background(), "none", nil); err != nil {
+		t.Fatal(err)
+	}
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return rec
+}
+
+func attr(span sdktrace.ReadOnlySpan, key string) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestMiddleware(t *testing.T) {
+	rec := record(t)
+	store.Clear()
+	task := store.Add(model.NewTask("Traced", "", model.PriorityLow))
+	mux := http.NewServeMux()
+	handler.Register(mux)
+	h := tracing.Middleware(mux, mux)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0736"
+	req := httptest.NewRequest(http.MethodGet, "/tasks/"+task.ID, nil)
+	req.Header.Set("traceparent", "00-"+traceID+"36-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected a store and a server span, got %d", len(spans))
+	}
+	get, server := spans[0], spans[1]
+	if server.Name() != "GET /tasks/{id}" || attr(server, "http.route").AsString() != "GET /tasks/{id}" || attr(server, "http.response.status_code").AsInt64() != 200 {
+		t.Errorf("unexpected server span %s %v", server.Name(), server.Attributes())
+	}
+	if got := server.SpanContext().TraceID().String(); got != traceID+"36" {
+		t.Errorf("expected the caller's trace to continue, got %s", got)
+	}
+	if server.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("expected the caller's span as parent, got %s", server.Parent().SpanID())
+	}
+	if get.Name() != "store.get" || get.Parent().SpanID() != server.SpanContext().SpanID() || attr(get, "task.id").AsString() != task.ID {
+		t.Errorf("expected a store.get child span for task %s, got %s %v", task.ID, get.Name(), get.Attributes())
+	}
+}
+
+func TestMiddlewareErrors(t *testing.T) {
+	rec := record(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /boom", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	h := tracing.Middleware(mux, mux)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/boom", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("expected a 500 to mark the span as an error, got %v", spans[0].Status())
+	}
+	if spans[1].Name() != "GET" || spans[1].Status().Code == codes.Error || attr(spans[1], "http.response.status_code").AsInt64() != 404 {
+		t.Errorf("expected an unmatched request to be named after its method, got %s %v", spans[1].Name(), spans[1].Attributes())
+	}
+}
+
+func TestStoreSpansNeedATrace(t *testing.T) {
+	rec := record(t)
+	store.Clear()
+	store.Add(model.NewTask("Untraced", "", model.PriorityLow))
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "batch")
+	store.ApplyContext(ctx, []store.Op{{Kind: store.OpDelete, ID: "1"}, {Kind: store.OpDelete, ID: "9"}}, true)
+	span.End()
+
+	spans := rec.Ended()
+	if len(spans) != 2 || spans[0].Name() != "store.apply" {
+		t.Fatalf("expected only the traced apply and its parent, got %d spans", len(spans))
+	}
+	if attr(spans[0], "store.batch.operations").AsInt64() != 2 || attr(spans[0], "store.batch.committed").AsBool() {
+		t.Errorf("unexpected apply attributes %v", spans[0].Attributes())
+	}
+}
+
+func TestSetup(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	var buf bytes.Buffer
+	shutdown, err := tracing.Setup(context.Background(), "stdout", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := otel.Tracer("test").Start(context.Background(), "exported")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"Name":"exported"`) || !strings.Contains(buf.String(), `"Value":"taskapi"`) {
+		t.Errorf("expected the span on stdout with the service name, got %s", buf.String())
+	}
+
+	if _, err := tracing.Setup(context.Background(), "zipkin", nil); err == nil {
+		t.Error("expected an unknown exporter to fail")
+	}
+}
@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// AllowPrivate lets webhooks target loopback, private and link-local
+// addresses. It is off so that subscribers cannot make the server reach
+// internal services or cloud metadata endpoints; tests turn it on to use
+// local receivers.
+var AllowPrivate = false
+
+// newClient returns the client deliveries are made with. It connects only
+// to public addresses, whatever a host name resolves to at the time, and
+// does not follow redirects: a 3xx response is a failed attempt.
+func newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: checkDial}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func checkDial(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	return checkAddr(ap.Addr())
+}
+
+func checkAddr(addr netip.Addr) error {
+	addr = addr.Unmap()
+	if AllowPrivate || !(addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified()) {
+		return nil
+	}
+	return fmt.Errorf("%s is not a public address", addr)
+}
+
+// ValidateURL checks that raw is an absolute http or https URL whose host
+// is not, and does not resolve to, a private address. Hosts that do not
+// resolve yet are accepted; every delivery checks the address it connects
+// to again.
+func ValidateURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	host := u.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return checkAddr(addr)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if err := checkAddr(addr); err != nil {
+			return fmt.Errorf("url host %s resolves to %w", host, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,576 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sawez-deepsource/demo-go/events"
+	"github.com/sawez-deepsource/demo-go/store"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Delivery tuning. Retries back off exponentially from BaseBackoff up to
+// MaxBackoff; after MaxAttempts the delivery is moved to the dead-letter
+// list with StatusFailed.
+var (
+	BaseBackoff  = time.Second
+	MaxBackoff   = time.Hour
+	MaxAttempts  = 8
+	PollInterval = time.Second
+	Client       = newClient()
+)
+
+// instrumentation names the tracer used for delivery spans.
+const instrumentation = "github.com/sawez-deepsource/demo-go/webhook"
+
+// maxHistory is how many delivered deliveries are kept per subscription.
+// Failed ones are dead letters and are kept until the subscription is
+// deleted, however many deliveries succeed after them.
+const maxHistory = 100
+
+type Subscription struct {
+	ID        string             `json:"id"`
+	URL       string             `json:"url"`
+	Events    []store.ChangeType `json:"events"`
+	Secret    string             `json:"secret,omitempty"`
+	CreatedAt string             `json:"created_at"`
+}
+
+func (s Subscription) wants(typ store.ChangeType) bool {
+	return len(s.Events) == 0 || slices.Contains(s.Events, typ)
+}
+
+type Attempt struct {
+	At         string `json:"at"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type Delivery struct {
+	ID          string       `json:"id"`
+	WebhookID   string       `json:"webhook_id"`
+	Event       events.Event `json:"event"`
+	Status      string       `json:"status"`
+	Attempts    []Attempt    `json:"attempts"`
+	NextAttempt string       `json:"next_attempt,omitempty"`
+}
+
+// state is everything that is persisted between restarts.
+type state struct {
+	Subscriptions map[string]Subscription `json:"subscriptions"`
+	Deliveries    map[string]*Delivery    `json:"deliveries"`
+	NextID        int                     `json:"next_id"`
+}
+
+var (
+	mu   sync.Mutex
+	path string
+	st   = newState()
+	// dirty is set when deliveries changed since the last save. Run saves
+	// them in batches, since they change with every event and attempt;
+	// subscription changes are saved at once.
+	dirty bool
+	// saveErr is the result of the last save.
+	saveErr error
+	// busy holds the subscriptions whose deliveries are in progress.
+	busy = map[string]bool{}
+	wake = make(chan struct{}, 1)
+)
+
+func newState() state {
+	return state{
+		Subscriptions: map[string]Subscription{},
+		Deliveries:    map[string]*Delivery{},
+		NextID:        1,
+	}
+}
+
+var ErrNotFound = errors.New("webhook not found")
+
+// Load restores subscriptions and queued deliveries from the file at p and
+// persists every later change there. An empty p keeps state in memory only.
+func Load(p string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	path = p
+	st = newState()
+	saveErr = nil
+	if p == "" {
+		return nil
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("reading webhook state %s: %w", p, err)
+	}
+	return nil
+}
+
+// save writes the state atomically, logging any failure and keeping it for
+// Err. Callers must hold mu.
+func save() {
+	dirty = false
+	saveErr = write()
+	if saveErr != nil {
+		slog.Error("failed to save webhook state", "path", path, "error", saveErr)
+	}
+}
+
+// saveIfDirty saves the state if deliveries changed since the last save.
+func saveIfDirty() {
+	mu.Lock()
+	defer mu.Unlock()
+	if dirty {
+		save()
+	}
+}
+
+func write() error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".webhooks-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Err returns the error from the last attempt to persist state, or nil if
+// it succeeded or nothing has been saved since Load.
+func Err() error {
+	mu.Lock()
+	defer mu.Unlock()
+	return saveErr
+}
+
+// Flush writes the state to the file given to Load, as the last step of a
+// shutdown, and returns the number of deliveries left pending for the next
+// start.
+func Flush() (pending int, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, d := range st.Deliveries {
+		if d.Status == StatusPending {
+			pending++
+		}
+	}
+	dirty = false
+	saveErr = write()
+	return pending, saveErr
+}
+
+func newID() string {
+	id := strconv.Itoa(st.NextID)
+	st.NextID++
+	return id
+}
+
+// Create registers a subscription. When no secret is given a random one is
+// generated; the returned subscription is the only place it is revealed.
+func Create(s Subscription) (Subscription, error) {
+	if s.Secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return Subscription{}, err
+		}
+		s.Secret = hex.EncodeToString(b)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	s.ID = newID()
+	s.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	st.Subscriptions[s.ID] = s
+	save()
+	return s, nil
+}
+
+func Get(id string) (Subscription, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	s, ok := st.Subscriptions[id]
+	return s, ok
+}
+
+func All() []Subscription {
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]Subscription, 0, len(st.Subscriptions))
+	for _, s := range st.Subscriptions {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return less(out[i].ID, out[j].ID)
+	})
+	return out
+}
+
+// Delete removes a subscription together with its queued and past
+// deliveries.
+func Delete(id string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := st.Subscriptions[id]; !ok {
+		return false
+	}
+	delete(st.Subscriptions, id)
+	for did, d := range st.Deliveries {
+		if d.WebhookID == id {
+			delete(st.Deliveries, did)
+		}
+	}
+	save()
+	return true
+}
+
+// Deliveries lists the deliveries of a subscription, oldest first, optionally
+// restricted to one status.
+func Deliveries(id, status string) ([]Delivery, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := st.Subscriptions[id]; !ok {
+		return nil, ErrNotFound
+	}
+	out := make([]Delivery, 0)
+	for _, d := range st.Deliveries {
+		if d.WebhookID == id && (status == "" || d.Status == status) {
+			out = append(out, *d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return less(out[i].ID, out[j].ID)
+	})
+	return out, nil
+}
+
+// less orders numeric IDs numerically.
+func less(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+// Enqueue queues a delivery of e to every subscription interested in it.
+func Enqueue(e events.Event) {
+	mu.Lock()
+	defer mu.Unlock()
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	queued := false
+	for _, s := range st.Subscriptions {
+		if !s.wants(e.Type) {
+			continue
+		}
+		d := &Delivery{
+			ID:          newID(),
+			WebhookID:   s.ID,
+			Event:       e,
+			Status:      StatusPending,
+			Attempts:    []Attempt{},
+			NextAttempt: now,
+		}
+		st.Deliveries[d.ID] = d
+		queued = true
+	}
+	if !queued {
+		return
+	}
+	dirty = true
+	signal()
+}
+
+// signal wakes Run to look for due deliveries.
+func signal() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Sign returns the value of the X-Webhook-Signature header for a payload
+// sent at timestamp (Unix seconds). Receivers should recompute it with the
+// shared secret and compare with hmac.Equal.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run feeds task changes into the queue and delivers due webhooks until ctx
+// is cancelled, saving the queue after each round. Each subscription's
+// deliveries are made in order, concurrently with other subscriptions', so
+// a slow receiver only delays itself. Run returns once the deliveries in
+// progress, if any, have finished and the events published before
+// cancellation are queued.
+func Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Go(func() { consume(ctx) })
+	defer func() {
+		wg.Wait()
+		saveIfDirty()
+	}()
+
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+	for {
+		deliverDue(ctx, &wg)
+		saveIfDirty()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wake:
+		}
+	}
+}
+
+// consume enqueues every published event until ctx is cancelled, then the
+// events still buffered, so that none are lost to a shutdown.
+func consume(ctx context.Context) {
+	after := events.LastID()
+	for ctx.Err() == nil {
+		after = drain(ctx, after)
+	}
+	backlog, sub := events.Subscribe(after)
+	events.Cancel(sub)
+	for _, e := range backlog {
+		Enqueue(e)
+	}
+}
+
+// drain enqueues events published after the given ID until ctx is cancelled
+// or the subscription is dropped for falling behind, and returns the ID of
+// the last event handled so the caller can resume from there.
+func drain(ctx context.Context, after uint64) uint64 {
+	backlog, sub := events.Subscribe(after)
+	defer events.Cancel(sub)
+	if sub.Missed {
+		slog.Warn("webhook events lost while falling behind", "after", after)
+	}
+	for _, e := range backlog {
+		Enqueue(e)
+		after = e.ID
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return after
+		case e, ok := <-sub.C:
+			if !ok {
+				return after
+			}
+			Enqueue(e)
+			after = e.ID
+		}
+	}
+}
+
+type job struct {
+	delivery Delivery
+	sub      Subscription
+}
+
+// deliverDue starts delivering the due deliveries of every subscription
+// that has none in progress.
+func deliverDue(ctx context.Context, wg *sync.WaitGroup) {
+	now := time.Now().UTC()
+	mu.Lock()
+	due := map[string][]job{}
+	for _, d := range st.Deliveries {
+		if d.Status != StatusPending || busy[d.WebhookID] {
+			continue
+		}
+		next, err := time.Parse(time.RFC3339Nano, d.NextAttempt)
+		if err == nil && next.After(now) {
+			continue
+		}
+		due[d.WebhookID] = append(due[d.WebhookID], job{delivery: *d, sub: st.Subscriptions[d.WebhookID]})
+	}
+	for id := range due {
+		busy[id] = true
+	}
+	mu.Unlock()
+
+	// A delivery in progress is finished rather than cut off by
+	// cancellation, which would count as a failed attempt; Client.Timeout
+	// bounds it.
+	deliveryCtx := context.WithoutCancel(ctx)
+	for id, jobs := range due {
+		sort.Slice(jobs, func(i, j int) bool {
+			return less(jobs[i].delivery.ID, jobs[j].delivery.ID)
+		})
+		wg.Go(func() {
+			defer func() {
+				mu.Lock()
+				delete(busy, id)
+				mu.Unlock()
+				signal()
+			}()
+			for _, j := range jobs {
+				if ctx.Err() != nil {
+					return
+				}
+				record(j.delivery.ID, attempt(deliveryCtx, j.sub, j.delivery))
+			}
+		})
+	}
+}
+
+// attempt makes one delivery under a client span, whose trace context is
+// passed to the receiver in the traceparent header.
+func attempt(ctx context.Context, s Subscription, d Delivery) (a Attempt) {
+	ctx, span := otel.Tracer(instrumentation).Start(ctx, "webhook.deliver",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("webhook.id", s.ID),
+			attribute.String("webhook.delivery.id", d.ID),
+			attribute.String("webhook.event", string(d.Event.Type)),
+			attribute.Int("webhook.attempt", len(d.Attempts)+1),
+			attribute.String("task.id", d.Event.Task.ID),
+			semconv.HTTPRequestMethodPost,
+			semconv.URLFull(s.URL),
+		))
+	defer func() {
+		if a.StatusCode != 0 {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(a.StatusCode))
+		}
+		if a.Error != "" {
+			span.SetStatus(codes.Error, a.Error)
+		}
+		span.End()
+	}()
+
+	a = Attempt{At: time.Now().UTC().Format(time.RFC3339)}
+	body, err := json.Marshal(d.Event)
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", s.ID)
+	req.Header.Set("X-Webhook-Delivery", d.ID)
+	req.Header.Set("X-Webhook-Event", string(d.Event.Type))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", Sign(s.Secret, timestamp, body))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := Client.Do(req)
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	resp.Body.Close()
+	a.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		a.Error = resp.Status
+	}
+	return a
+}
+
+func record(id string, a Attempt) {
+	mu.Lock()
+	defer mu.Unlock()
+	d, ok := st.Deliveries[id]
+	if !ok {
+		// The subscription was deleted while the delivery was in flight.
+		return
+	}
+	d.Attempts = append(d.Attempts, a)
+	switch {
+	case a.Error == "":
+		d.Status = StatusDelivered
+		d.NextAttempt = ""
+	case len(d.Attempts) >= MaxAttempts:
+		d.Status = StatusFailed
+		d.NextAttempt = ""
+		slog.Warn("webhook delivery failed permanently", "webhook", d.WebhookID, "delivery", d.ID, "attempts", len(d.Attempts), "error", a.Error)
+	default:
+		d.NextAttempt = time.Now().UTC().Add(backoff(len(d.Attempts))).Format(time.RFC3339Nano)
+	}
+	if d.Status == StatusDelivered {
+		prune(d.WebhookID)
+	}
+	dirty = true
+}
+
+// backoff returns the delay before the next try after n failed attempts.
+func backoff(n int) time.Duration {
+	d := BaseBackoff
+	for i := 1; i < n && d < MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, MaxBackoff)
+}
+
+// prune drops the oldest delivered deliveries of a subscription beyond
+// maxHistory. Callers must hold mu.
+func prune(webhookID string) {
+	var finished []string
+	for id, d := range st.Deliveries {
+		if d.WebhookID == webhookID && d.Status == StatusDelivered {
+			finished = append(finished, id)
+		}
+	}
+	if len(finished) <= maxHistory {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return less(finished[i], finished[j])
+	})
+	for _, id := range finished[:len(finished)-maxHistory] {
+		delete(st.Deliveries, id)
+	}
+}
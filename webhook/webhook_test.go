@@ -0,0 +1,361 @@
+package webhook_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/sawez-deepsource/demo-go/model"
+	"github.com/sawez-deepsource/demo-go/store"
+	"github.com/sawez-deepsource/demo-go/webhook"
+)
+
+func startWorker(t *testing.T) {
+	t.Helper()
+	allowLocal(t)
+	webhook.BaseBackoff = 10 * time.Millisecond
+	webhook.PollInterval = 10 * time.Millisecond
+	if err := webhook.Load(""); err != nil {
+		t.Fatalf("resetting webhooks: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		webhook.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	// Let the worker subscribe before tasks change.
+	time.Sleep(20 * time.Millisecond)
+}
+
+// allowLocal lets webhooks reach the test's local receivers.
+func allowLocal(t *testing.T) {
+	webhook.AllowPrivate = true
+	t.Cleanup(func() { webhook.AllowPrivate = false })
+}
+
+func waitForStatus(t *testing.T, webhookID, status string) webhook.Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		deliveries, err := webhook.Deliveries(webhookID, status)
+		if err != nil {
+			t.Fatalf("listing deliveries: %v", err)
+		}
+		if len(deliveries) > 0 {
+			return deliveries[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for a %s delivery", status)
+	return webhook.Delivery{}
+}
+
+func TestDeliverySignedAndRetried(t *testing.T) {
+	store.Clear()
+	var calls atomic.Int32
+	verified := make(chan bool, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		want := webhook.Sign("s3cret", r.Header.Get("X-Webhook-Timestamp"), body)
+		verified <- hmac.Equal([]byte(want), []byte(r.Header.Get("X-Webhook-Signature")))
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	startWorker(t)
+
+	sub, err := webhook.Create(webhook.Subscription{URL: receiver.URL, Secret: "s3cret", Events: []store.ChangeType{store.Created}})
+	if err != nil {
+		t.Fatalf("creating webhook: %v", err)
+	}
+	task := store.Add(model.NewTask("Hooked", "desc", model.PriorityLow))
+	store.Delete(task.ID)
+
+	d := waitForStatus(t, sub.ID, webhook.StatusDelivered)
+	if len(d.Attempts) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(d.Attempts))
+	}
+	if d.Event.Type != store.Created || d.Event.Task.ID != task.ID {
+		t.Fatalf("expected created event for task %s, got %+v", task.ID, d.Event)
+	}
+	if !<-verified {
+		t.Fatal("expected a valid signature")
+	}
+	all, _ := webhook.Deliveries(sub.ID, "")
+	if len(all) != 1 {
+		t.Fatalf("expected deleted event to be skipped, got %d deliveries", len(all))
+	}
+}
+
+func TestDeliveryDeadLettered(t *testing.T) {
+	store.Clear()
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+	webhook.MaxAttempts = 2
+	defer func() { webhook.MaxAttempts = 8 }()
+	startWorker(t)
+
+	sub, _ := webhook.Create(webhook.Subscription{URL: receiver.URL})
+	store.Add(model.NewTask("Doomed", "desc", model.PriorityLow))
+
+	d := waitForStatus(t, sub.ID, webhook.StatusFailed)
+	if len(d.Attempts) != 2 || d.Attempts[1].StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected 2 failed attempts, got %+v", d.Attempts)
+	}
+}
+
+func TestDeadLettersOutliveHistory(t *testing.T) {
+	store.Clear()
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	webhook.MaxAttempts = 1
+	defer func() { webhook.MaxAttempts = 8 }()
+	startWorker(t)
+
+	sub, _ := webhook.Create(webhook.Subscription{URL: receiver.URL})
+	store.Add(model.NewTask("Doomed", "desc", model.PriorityLow))
+	waitForStatus(t, sub.ID, webhook.StatusFailed)
+	for i := 0; i < 110; i++ {
+		store.Add(model.NewTask("Fine", "desc", model.PriorityLow))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && calls.Load() < 111 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	delivered, _ := webhook.Deliveries(sub.ID, webhook.StatusDelivered)
+	failed, _ := webhook.Deliveries(sub.ID, webhook.StatusFailed)
+	if len(delivered) != 100 || len(failed) != 1 {
+		t.Fatalf("expected 100 delivered and the dead letter kept, got %d and %d", len(delivered), len(failed))
+	}
+}
+
+func TestStatePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	if err := webhook.Load(path); err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+	sub, _ := webhook.Create(webhook.Subscription{URL: "http://example.com/hook"})
+
+	if err := webhook.Load(path); err != nil {
+		t.Fatalf("reloading: %v", err)
+	}
+	got, ok := webhook.Get(sub.ID)
+	if !ok || got.Secret != sub.Secret {
+		t.Fatalf("expected subscription %s to survive a reload", sub.ID)
+	}
+	webhook.Load("")
+}
+
+func TestDeliveryTraced(t *testing.T) {
+	store.Clear()
+	rec := tracetest.NewSpanRecorder()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	traceparent := make(chan string, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent <- r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	startWorker(t)
+
+	sub, _ := webhook.Create(webhook.Subscription{URL: receiver.URL})
+	task := store.Add(model.NewTask("Traced", "desc", model.PriorityLow))
+	waitForStatus(t, sub.ID, webhook.StatusDelivered)
+
+	spans := rec.Ended()
+	if len(spans) != 1 || spans[0].Name() != "webhook.deliver" {
+		t.Fatalf("expected one delivery span, got %d", len(spans))
+	}
+	span := spans[0]
+	want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	if got := <-traceparent; got != want {
+		t.Errorf("expected traceparent %q, got %q", want, got)
+	}
+	attrs := map[string]string{}
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["webhook.id"] != sub.ID || attrs["task.id"] != task.ID || attrs["http.response.status_code"] != "204" {
+		t.Errorf("unexpected span attributes %v", attrs)
+	}
+}
+
+func TestErrReportsFailedSaves(t *testing.T) {
+	defer webhook.Load("")
+	if err := webhook.Load(filepath.Join(t.TempDir(), "missing", "webhooks.json")); err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+	if webhook.Err() != nil {
+		t.Fatal("expected no error before anything is saved")
+	}
+	webhook.Create(webhook.Subscription{URL: "http://example.com/hook"})
+	if webhook.Err() == nil {
+		t.Fatal("expected the failed save to be reported")
+	}
+}
+
+func TestRunFinishesWorkOnCancel(t *testing.T) {
+	store.Clear()
+	defer webhook.Load("")
+	allowLocal(t)
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	if err := webhook.Load(path); err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	sub, _ := webhook.Create(webhook.Subscription{URL: receiver.URL})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		webhook.Run(ctx)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	store.Add(model.NewTask("In flight", "desc", model.PriorityLow))
+	<-started
+
+	// Cancelling waits for the delivery in progress instead of cutting it
+	// off, and still queues the event published just before.
+	store.Add(model.NewTask("Queued", "desc", model.PriorityLow))
+	cancel()
+	close(release)
+	<-done
+
+	delivered, _ := webhook.Deliveries(sub.ID, webhook.StatusDelivered)
+	if len(delivered) != 1 || len(delivered[0].Attempts) != 1 {
+		t.Fatalf("expected the delivery in progress to complete, got %+v", delivered)
+	}
+	pending, err := webhook.Flush()
+	if err != nil || pending != 1 {
+		t.Fatalf("expected one delivery left pending, got %d (%v)", pending, err)
+	}
+	if err := webhook.Load(path); err != nil {
+		t.Fatalf("reloading: %v", err)
+	}
+	if queued, _ := webhook.Deliveries(sub.ID, webhook.StatusPending); len(queued) != 1 || queued[0].Event.Task.Title != "Queued" {
+		t.Fatalf("expected the queued delivery to be persisted, got %+v", queued)
+	}
+}
+
+func TestPrivateTargetsRefused(t *testing.T) {
+	ctx := context.Background()
+	for _, u := range []string{"http://127.0.0.1/hook", "http://[::1]/hook", "http://169.254.169.254/latest/meta-data", "http://10.0.0.5/hook", "http://localhost:8080/hook"} {
+		if err := webhook.ValidateURL(ctx, u); err == nil {
+			t.Errorf("expected %s to be refused", u)
+		}
+	}
+	if err := webhook.ValidateURL(ctx, "https://203.0.113.9/hook"); err != nil {
+		t.Errorf("expected a public address to be accepted, got %v", err)
+	}
+
+	// Deliveries check the address they connect to, whatever was
+	// registered.
+	store.Clear()
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected the local receiver not to be reached")
+	}))
+	defer receiver.Close()
+	webhook.MaxAttempts = 1
+	defer func() { webhook.MaxAttempts = 8 }()
+	startWorker(t)
+	webhook.AllowPrivate = false
+
+	sub, _ := webhook.Create(webhook.Subscription{URL: receiver.URL})
+	store.Add(model.NewTask("Blocked", "desc", model.PriorityLow))
+	d := waitForStatus(t, sub.ID, webhook.StatusFailed)
+	if !strings.Contains(d.Attempts[0].Error, "not a public address") {
+		t.Errorf("expected the dial to be refused, got %+v", d.Attempts)
+	}
+}
+
+func TestRedirectsNotFollowed(t *testing.T) {
+	store.Clear()
+	var followed atomic.Bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed.Store(true)
+	}))
+	defer target.Close()
+	receiver := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer receiver.Close()
+	webhook.MaxAttempts = 1
+	defer func() { webhook.MaxAttempts = 8 }()
+	startWorker(t)
+
+	sub, _ := webhook.Create(webhook.Subscription{URL: receiver.URL})
+	store.Add(model.NewTask("Redirected", "desc", model.PriorityLow))
+	d := waitForStatus(t, sub.ID, webhook.StatusFailed)
+	if followed.Load() || d.Attempts[0].StatusCode != http.StatusTemporaryRedirect {
+		t.Errorf("expected the redirect to fail the attempt, got %+v", d.Attempts)
+	}
+}
+
+func TestSlowReceiverDoesNotDelayOthers(t *testing.T) {
+	store.Clear()
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fast.Close()
+	startWorker(t)
+
+	webhook.Create(webhook.Subscription{URL: slow.URL})
+	sub, _ := webhook.Create(webhook.Subscription{URL: fast.URL})
+	store.Add(model.NewTask("First", "desc", model.PriorityLow))
+	store.Add(model.NewTask("Second", "desc", model.PriorityLow))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if delivered, _ := webhook.Deliveries(sub.ID, webhook.StatusDelivered); len(delivered) == 2 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("expected the fast receiver to get both deliveries while the slow one hangs")
+}